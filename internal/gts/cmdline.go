@@ -0,0 +1,95 @@
+package gts
+
+// #cgo pkg-config: gio-2.0
+// #include <stdlib.h>
+// #include <gio/gio.h>
+//
+// static void command_line_print(GApplicationCommandLine *cmdline, const char *str) {
+// 	g_application_command_line_print(cmdline, "%s", str);
+// }
+//
+// static void command_line_printerr(GApplicationCommandLine *cmdline, const char *str) {
+// 	g_application_command_line_printerr(cmdline, "%s", str);
+// }
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/gotk3/gotk3/glib"
+)
+
+// CommandLiner is an optional interface that a MainApplication could implement
+// to handle the command line. Launching the application again forwards the
+// command line to the running instance, so the command line may be remote.
+type CommandLiner interface {
+	// CommandLine handles the command line after the application is activated.
+	// It returns the exit status, which is ignored if the command line is held.
+	CommandLine(cmd *CommandLine) int
+}
+
+// CommandLine is a wrapper around GApplicationCommandLine. Its methods must be
+// called in the main thread.
+type CommandLine struct {
+	obj *glib.Object
+}
+
+func wrapCommandLine(obj *glib.Object) *CommandLine {
+	return &CommandLine{obj}
+}
+
+func (c *CommandLine) native() *C.GApplicationCommandLine {
+	return (*C.GApplicationCommandLine)(unsafe.Pointer(c.obj.GObject))
+}
+
+// Args returns the arguments of the command line, including the program name.
+func (c *CommandLine) Args() []string {
+	var argc C.int
+	var argv = C.g_application_command_line_get_arguments(c.native(), &argc)
+	defer C.g_strfreev(argv)
+
+	var args = make([]string, int(argc))
+	var cargs = (*[1 << 28]*C.gchar)(unsafe.Pointer(argv))[:argc:argc]
+
+	for i, arg := range cargs {
+		args[i] = C.GoString((*C.char)(arg))
+	}
+
+	return args
+}
+
+// IsRemote returns true if the command line comes from another instance.
+func (c *CommandLine) IsRemote() bool {
+	return C.g_application_command_line_get_is_remote(c.native()) != 0
+}
+
+// Print prints the string to the standard output of the command line's
+// instance.
+func (c *CommandLine) Print(str string) {
+	cstr := C.CString(str)
+	defer C.free(unsafe.Pointer(cstr))
+
+	C.command_line_print(c.native(), cstr)
+}
+
+// PrintErr prints the string to the standard error of the command line's
+// instance.
+func (c *CommandLine) PrintErr(str string) {
+	cstr := C.CString(str)
+	defer C.free(unsafe.Pointer(cstr))
+
+	C.command_line_printerr(c.native(), cstr)
+}
+
+// Hold keeps the command line alive after the handler returns, which makes a
+// remote instance wait until Done is called. This is used for commands that
+// finish asynchronously.
+func (c *CommandLine) Hold() {
+	c.obj.Ref()
+}
+
+// Done sets the exit status and releases the command line held by Hold.
+func (c *CommandLine) Done(status int) {
+	C.g_application_command_line_set_exit_status(c.native(), C.int(status))
+	c.obj.Unref()
+}
@@ -84,7 +84,7 @@ func AddAppAction(name string, call func()) {
 
 func init() {
 	gtk.Init(&Args)
-	App.Application, _ = gtk.ApplicationNew(AppID, 0)
+	App.Application, _ = gtk.ApplicationNew(AppID, glib.APPLICATION_HANDLES_COMMAND_LINE)
 	Clipboard, _ = gtk.ClipboardGet(gdk.SELECTION_CLIPBOARD)
 
 	// Limit the TPS of the main loop on window unfocus.
@@ -99,7 +99,16 @@ type MainApplication interface {
 }
 
 func Main(wfn func() MainApplication) {
+	var mainApp MainApplication
+
 	App.Application.Connect("activate", func(*gtk.Application) {
+		// Launching the application again activates the running instance, so
+		// we only need to present the existing window.
+		if App.Window != nil {
+			App.Window.Present()
+			return
+		}
+
 		handy.Init()
 
 		// Load all CSS onto the default screen.
@@ -118,9 +127,13 @@ func Main(wfn func() MainApplication) {
 
 		App.Throttler.Connect(&App.Window.Window)
 
+		// Watch the main loop for freezes now that it's running.
+		go watchdog()
+
 		// Execute the function later, because we need it to run after
 		// initialization.
 		w := wfn()
+		mainApp = w
 		App.Window.Add(w)
 		App.Window.SetIcon(w.Icon())
 
@@ -145,6 +158,22 @@ func Main(wfn func() MainApplication) {
 		AddAppAction("quit", App.Window.Destroy)
 	})
 
+	// The command line of both this instance and later launches is handled
+	// here. Later launches don't present the window unless the command line
+	// handler activates the application.
+	App.Application.Connect("command-line", func(_ *gtk.Application, obj *glib.Object) int {
+		if App.Window == nil {
+			App.Activate()
+		}
+
+		if liner, ok := mainApp.(CommandLiner); ok {
+			return liner.CommandLine(wrapCommandLine(obj))
+		}
+
+		App.Activate()
+		return 0
+	})
+
 	// Use a special function to run the application. Exit with the appropriate
 	// exit code if necessary.
 	if code := App.Run(Args); code > 0 {
@@ -155,6 +184,8 @@ func Main(wfn func() MainApplication) {
 // Async runs fn asynchronously, then runs the function it returns in the Gtk
 // main thread.
 func Async(fn func() (func(), error)) {
+	var site = callerSite(0)
+
 	go func() {
 		f, err := fn()
 		if err != nil {
@@ -163,19 +194,19 @@ func Async(fn func() (func(), error)) {
 
 		// Attempt to run the callback if it's there.
 		if f != nil {
-			ExecAsync(f)
+			glib.IdleAddPriority(glib.PRIORITY_HIGH, instrument(site, f))
 		}
 	}()
 }
 
 // ExecLater executes the function asynchronously with a low priority.
 func ExecLater(fn func()) {
-	glib.IdleAddPriority(glib.PRIORITY_DEFAULT_IDLE, fn)
+	glib.IdleAddPriority(glib.PRIORITY_DEFAULT_IDLE, instrument(callerSite(0), fn))
 }
 
 // ExecAsync executes function asynchronously in the Gtk main thread.
 func ExecAsync(fn func()) {
-	glib.IdleAddPriority(glib.PRIORITY_HIGH, fn)
+	glib.IdleAddPriority(glib.PRIORITY_HIGH, instrument(callerSite(0), fn))
 }
 
 // ExecSync executes the function asynchronously, but returns a channel that
@@ -183,10 +214,10 @@ func ExecAsync(fn func()) {
 func ExecSync(fn func()) <-chan struct{} {
 	var ch = make(chan struct{})
 
-	glib.IdleAddPriority(glib.PRIORITY_HIGH, func() {
+	glib.IdleAddPriority(glib.PRIORITY_HIGH, instrument(callerSite(0), func() {
 		fn()
 		close(ch)
-	})
+	}))
 
 	return ch
 }
@@ -0,0 +1,216 @@
+package httputil
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gotk3/gotk3/gdk"
+)
+
+// ImageCacheSize is the maximum number of bytes of decoded images to keep
+// around for reuse, on top of the ones held by widgets.
+var ImageCacheSize int64 = 32 << 20
+
+// imageKey identifies a decoded image. Processors are told apart by their
+// keys.
+type imageKey struct {
+	url   string
+	w, h  int
+	scale int
+	procs string
+}
+
+func newImageKey(url string, w, h, scale int, procs []Processor) imageKey {
+	var b strings.Builder
+	for _, proc := range procs {
+		fmt.Fprintf(&b, "%q,", proc.Key)
+	}
+
+	return imageKey{url, w, h, scale, b.String()}
+}
+
+// imageWaiter is a container waiting for an image.
+type imageWaiter struct {
+	ctx    context.Context
+	img    ImageContainer
+	scale  int
+	reload func()
+	// seen is true if the container was on screen while it was waiting.
+	seen bool
+}
+
+// set sets the decoded image, which is either a *gdk.Pixbuf or a
+// *gdk.PixbufAnimation, into the container. It must be called in the main
+// loop.
+func (w *imageWaiter) set(v interface{}) {
+	switch v := v.(type) {
+	case *gdk.Pixbuf:
+		var img = w.img
+		var key interface{} = v.Native()
+
+		// Only bother with this if we even have HiDPI. Each container then
+		// has its own surface, so it's not shared.
+		if surfaceContainer, ok := img.(SurfaceContainer); ok && w.scale > 1 {
+			img = &surfaceWrapper{surfaceContainer, w.scale}
+			key = nil
+		}
+
+		img.SetFromPixbuf(v)
+		trackImage(w.img, key, pixbufSize(v), w.reload)
+
+	case *gdk.PixbufAnimation:
+		w.img.SetFromAnimation(v)
+		// Only the current frame is counted, since the others are decoded as
+		// they're shown.
+		trackImage(w.img, v.Native(), pixbufSize(v.GetStaticImage()), w.reload)
+	}
+}
+
+// deliver sets the image into the container in the main loop if the
+// container's context isn't done.
+func (w *imageWaiter) deliver(v interface{}) {
+	execIfCtx(w.ctx, func() { w.set(v) })
+}
+
+type cachedImage struct {
+	key   imageKey
+	value interface{}
+	size  int64
+}
+
+// images is the cache of decoded images along with the images being loaded.
+var images = struct {
+	sync.Mutex
+	cache   map[imageKey]*list.Element // of *cachedImage
+	lru     *list.List                 // most recently used first
+	size    int64
+	loading map[imageKey]*imageJob
+	// running is the number of jobs being downloaded.
+	running int
+	// dispatching is true if a dispatch is scheduled, and rechecking is true if
+	// one is scheduled to check the running jobs.
+	dispatching bool
+	rechecking  bool
+	// seq is the number of jobs made so far.
+	seq uint64
+}{
+	cache:   map[imageKey]*list.Element{},
+	lru:     list.New(),
+	loading: map[imageKey]*imageJob{},
+}
+
+// waitImage gives the waiter the cached image or adds it to the waiters of the
+// image being loaded. If the image isn't being loaded yet, a job that calls
+// load is queued.
+func waitImage(key imageKey, waiter *imageWaiter, load loadFunc) {
+	images.Lock()
+	defer images.Unlock()
+
+	if elem, ok := images.cache[key]; ok {
+		images.lru.MoveToFront(elem)
+		waiter.deliver(elem.Value.(*cachedImage).value)
+		return
+	}
+
+	job, ok := images.loading[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		images.seq++
+
+		job = &imageJob{
+			key:    key,
+			seq:    images.seq,
+			load:   load,
+			ctx:    ctx,
+			cancel: cancel,
+		}
+
+		images.loading[key] = job
+		scheduleDispatch()
+	}
+
+	job.waiters = append(job.waiters, waiter)
+	go job.watch(waiter)
+}
+
+// updateImage gives the partially loaded image to the job's waiters.
+func updateImage(job *imageJob, v interface{}) {
+	images.Lock()
+	defer images.Unlock()
+
+	for _, waiter := range job.waiters {
+		waiter.deliver(v)
+	}
+}
+
+// finishImage gives the loaded image to the job's waiters and caches it. A nil
+// value means that the image couldn't be loaded.
+func finishImage(job *imageJob, v interface{}) {
+	images.Lock()
+	defer images.Unlock()
+
+	images.running--
+	scheduleDispatch()
+
+	// Nobody is waiting for a cancelled job.
+	var cancelled = job.ctx.Err() != nil
+
+	// Stop the watchers.
+	job.cancel()
+
+	if images.loading[job.key] == job {
+		delete(images.loading, job.key)
+	}
+
+	if cancelled {
+		return
+	}
+
+	var size int64
+
+	switch v := v.(type) {
+	case *gdk.Pixbuf:
+		if v == nil {
+			return
+		}
+		size = pixbufSize(v)
+	case *gdk.PixbufAnimation:
+		if v == nil {
+			return
+		}
+		size = pixbufSize(v.GetStaticImage())
+	default:
+		return
+	}
+
+	for _, waiter := range job.waiters {
+		waiter.deliver(v)
+	}
+
+	if size > ImageCacheSize {
+		return
+	}
+
+	images.cache[job.key] = images.lru.PushFront(&cachedImage{job.key, v, size})
+	images.size += size
+
+	for images.size > ImageCacheSize {
+		oldest := images.lru.Back()
+		cached := oldest.Value.(*cachedImage)
+
+		images.lru.Remove(oldest)
+		delete(images.cache, cached.key)
+		images.size -= cached.size
+	}
+}
+
+// cachedSize returns the number of bytes of decoded images in the cache.
+func cachedSize() int64 {
+	images.Lock()
+	defer images.Unlock()
+
+	return images.size
+}
@@ -0,0 +1,334 @@
+package httputil
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/peterbourgon/diskv"
+	"github.com/pkg/errors"
+)
+
+// maxDiskSize is the maximum size of the media cache on disk. The least
+// recently used files are removed when it's exceeded. 0 means no limit.
+var maxDiskSize int64 = 256 << 20 // atomic
+
+// oldCacheDir is where the media cache used to be.
+var oldCacheDir = filepath.Join(os.TempDir(), "cchat-gtk-caching-is-hard")
+
+// trimDelay is how long to wait after a write before trimming the disk cache,
+// so that bursts of writes are trimmed once.
+const trimDelay = 10 * time.Second
+
+var diskSizeOptions = []int64{64 << 20, 256 << 20, 1 << 30, 4 << 30, 0}
+
+var diskSizeIndex = 1
+
+func init() {
+	config.MediaAdd("Maximum Cache Size", config.Combo(
+		&diskSizeIndex,
+		[]string{"64 MiB", "256 MiB", "1 GiB", "4 GiB", "Unlimited"},
+		func(i int) {
+			if i >= 0 && i < len(diskSizeOptions) {
+				atomic.StoreInt64(&maxDiskSize, diskSizeOptions[i])
+				mediaCache.scheduleTrim()
+			}
+		},
+	))
+	config.MediaAdd("Media Cache", cacheEntry{})
+
+	// The old cache isn't moved, since it's only a cache.
+	go func() {
+		if err := os.RemoveAll(oldCacheDir); err != nil {
+			log.Error(errors.Wrap(err, "Failed to remove old media cache"))
+		}
+	}()
+}
+
+// cacheDir returns the directory of the media cache, which is in the user's
+// cache directory if there's one.
+func cacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		log.Error(errors.Wrap(err, "Failed to get cache dir, using temp dir"))
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "cchat-gtk", "media")
+}
+
+// CacheStats contains the statistics of the media cache.
+type CacheStats struct {
+	// Hits and Misses count the responses since the start.
+	Hits   uint64
+	Misses uint64
+	// Size is the number of bytes on disk.
+	Size int64
+	// Files is the number of files on disk.
+	Files int
+}
+
+// diskCache is a httpcache.Cache that stores responses with diskv and keeps the
+// disk usage under maxDiskSize.
+type diskCache struct {
+	d *diskv.Diskv
+
+	hits   uint64 // atomic
+	misses uint64 // atomic
+
+	mutex    sync.Mutex
+	trimming bool
+}
+
+func newDiskCache(dir string) *diskCache {
+	return &diskCache{
+		d: diskv.New(diskv.Options{
+			BasePath:     dir,
+			TempDir:      filepath.Join(dir, "tmp"),
+			PathPerm:     0750,
+			FilePerm:     0750,
+			Compression:  diskv.NewZlibCompressionLevel(4),
+			CacheSizeMax: 25 * 1024 * 1024, // 25 MiB in memory
+		}),
+	}
+}
+
+var mediaCache = newDiskCache(cacheDir())
+
+// keyToFilename hashes the key the same way httpcache's diskcache does.
+func keyToFilename(key string) string {
+	h := md5.New()
+	io.WriteString(h, key)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached response. The file's modification time is updated, so
+// that it's the last to be removed.
+func (c *diskCache) Get(key string) ([]byte, bool) {
+	name := keyToFilename(key)
+
+	b, err := c.d.Read(name)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(filepath.Join(c.d.BasePath, name), now, now)
+
+	return b, true
+}
+
+func (c *diskCache) Set(key string, resp []byte) {
+	c.d.WriteStream(keyToFilename(key), bytes.NewReader(resp), true)
+	c.scheduleTrim()
+}
+
+func (c *diskCache) Delete(key string) {
+	c.d.Erase(keyToFilename(key))
+}
+
+// countResponse counts the response as a hit if it's from the cache.
+func (c *diskCache) countResponse(fromCache bool) {
+	if fromCache {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+}
+
+type cacheFile struct {
+	name string
+	size int64
+	time time.Time
+}
+
+// files returns the cached files, least recently used first.
+func (c *diskCache) files() ([]cacheFile, error) {
+	infos, err := ioutil.ReadDir(c.d.BasePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var files = make([]cacheFile, 0, len(infos))
+
+	for _, info := range infos {
+		// Skip the temporary directory.
+		if info.IsDir() {
+			continue
+		}
+
+		files = append(files, cacheFile{info.Name(), info.Size(), info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].time.Before(files[j].time)
+	})
+
+	return files, nil
+}
+
+// scheduleTrim trims the cache after trimDelay, unless a trim is already
+// scheduled.
+func (c *diskCache) scheduleTrim() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.trimming {
+		return
+	}
+
+	c.trimming = true
+
+	time.AfterFunc(trimDelay, func() {
+		if err := c.trim(); err != nil {
+			log.Error(errors.Wrap(err, "Failed to trim media cache"))
+		}
+
+		c.mutex.Lock()
+		c.trimming = false
+		c.mutex.Unlock()
+	})
+}
+
+// trim removes the least recently used files until the cache fits in
+// maxDiskSize.
+func (c *diskCache) trim() error {
+	var max = atomic.LoadInt64(&maxDiskSize)
+	if max <= 0 {
+		return nil
+	}
+
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, file := range files {
+		size += file.size
+	}
+
+	for _, file := range files {
+		if size <= max {
+			break
+		}
+
+		if err := c.d.Erase(file.name); err != nil {
+			return errors.Wrapf(err, "Failed to remove %s", file.name)
+		}
+
+		size -= file.size
+	}
+
+	return nil
+}
+
+// CacheStatistics returns the statistics of the media cache. It reads the
+// cache directory, so it shouldn't be called in the main loop.
+func CacheStatistics() (CacheStats, error) {
+	var stats = CacheStats{
+		Hits:   atomic.LoadUint64(&mediaCache.hits),
+		Misses: atomic.LoadUint64(&mediaCache.misses),
+	}
+
+	files, err := mediaCache.files()
+	if err != nil {
+		return stats, errors.Wrap(err, "Failed to read cache dir")
+	}
+
+	stats.Files = len(files)
+	for _, file := range files {
+		stats.Size += file.size
+	}
+
+	return stats, nil
+}
+
+// ClearCache removes all cached media from the disk.
+func ClearCache() error {
+	return errors.Wrap(mediaCache.d.EraseAll(), "Failed to clear media cache")
+}
+
+// cacheEntry is the preferences entry that shows the cache statistics and a
+// button to clear the cache. It has nothing to save.
+type cacheEntry struct{}
+
+var _ config.WideEntryValue = cacheEntry{}
+
+func (cacheEntry) Wide() {}
+
+func (cacheEntry) MarshalJSON() ([]byte, error) { return json.Marshal(nil) }
+
+func (cacheEntry) UnmarshalJSON([]byte) error { return nil }
+
+func (cacheEntry) Construct() gtk.IWidget {
+	label, _ := gtk.LabelNew("")
+	label.SetXAlign(0)
+	label.SetHExpand(true)
+	label.Show()
+
+	clear, _ := gtk.ButtonNewWithLabel("Clear Media Cache")
+	clear.Show()
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 8)
+	box.PackStart(label, true, true, 0)
+	box.PackStart(clear, false, false, 0)
+	box.Show()
+
+	var update = func() {
+		go func() {
+			stats, err := CacheStatistics()
+			gts.ExecAsync(func() {
+				if err != nil {
+					label.SetText(err.Error())
+					return
+				}
+
+				var total = stats.Hits + stats.Misses
+				var ratio float64
+				if total > 0 {
+					ratio = float64(stats.Hits) / float64(total) * 100
+				}
+
+				label.SetMarkup(fmt.Sprintf(
+					"<small>%.1f MiB in %d files\n%.0f%% hits (%d of %d)</small>",
+					float64(stats.Size)/(1<<20), stats.Files, ratio, stats.Hits, total,
+				))
+			})
+		}()
+	}
+
+	clear.Connect("clicked", func(*gtk.Button) {
+		clear.SetSensitive(false)
+
+		go func() {
+			err := ClearCache()
+			if err != nil {
+				log.Error(err)
+			}
+
+			gts.ExecAsync(func() {
+				clear.SetSensitive(true)
+				update()
+			})
+		}()
+	})
+
+	update()
+
+	return box
+}
@@ -77,90 +77,148 @@ func (wrapper *surfaceWrapper) SetFromPixbuf(pb *gdk.Pixbuf) {
 }
 
 // AsyncImage loads an image. This method uses the cache. It prefers loading
-// SetFromSurface over SetFromPixbuf, but will fallback if needed be.
+// SetFromSurface over SetFromPixbuf, but will fallback if needed be. Decoded
+// images are shared, and containers asking for the same image while it's
+// being loaded wait for the same download, which is cancelled once they're all
+// destroyed. Downloads are queued by priority; see MaxDownloads. Media that's
+// blocked by the privacy settings is only loaded once it's allowed.
 func AsyncImage(ctx context.Context,
-	img ImageContainer, imageURL string, procs ...imgutil.Processor) {
+	img ImageContainer, imageURL string, procs ...Processor) {
 
 	if imageURL == "" {
 		return
 	}
 
+	asyncImage(primitives.HandleDestroyCtx(ctx, img), img, imageURL, procs)
+}
+
+// asyncImage is AsyncImage with a context that's already cancelled once the
+// container is destroyed, which is reused to load the image again.
+func asyncImage(ctx context.Context,
+	img ImageContainer, imageURL string, procs []Processor) {
+
 	w, h := img.GetSizeRequest()
 	scale := 1
 
-	surfaceContainer, canSurface := img.(SurfaceContainer)
-	if canSurface {
+	if surfaceContainer, ok := img.(SurfaceContainer); ok {
 		scale = surfaceContainer.GetScaleFactor()
 	}
 
-	ctx = primitives.HandleDestroyCtx(ctx, img)
+	reload := func() { asyncImage(ctx, img, imageURL, procs) }
+
+	if !MediaAllowed(imageURL) {
+		blockImage(img, imageURL, reload)
+		return
+	}
+
+	waiter := &imageWaiter{
+		ctx:    ctx,
+		img:    img,
+		scale:  scale,
+		reload: reload,
+	}
 
-	go func() {
-		// Try and guess the MIME type from the URL.
-		mimeType := mime.TypeByExtension(urlExt(imageURL))
+	key := newImageKey(imageURL, w, h, scale, procs)
 
-		r, err := get(ctx, imageURL, true)
-		if err != nil {
+	waitImage(key, waiter, func(ctx context.Context, job *imageJob) interface{} {
+		return loadImage(ctx, job, imageURL, w, h, scale, procs)
+	})
+}
+
+// loadImage downloads and decodes the image, giving the partially loaded image
+// to the job's waiters along the way. The loaded image is returned, or nil if
+// it couldn't be loaded. The context is cancelled once nobody waits for the
+// image anymore.
+func loadImage(ctx context.Context, job *imageJob,
+	imageURL string, w, h, scale int, procs []Processor) (pixbuf interface{}) {
+
+	// Try and guess the MIME type from the URL.
+	mimeType := mime.TypeByExtension(urlExt(imageURL))
+
+	r, err := get(ctx, imageURL, true)
+	if err != nil {
+		// Don't bother logging cancelled downloads.
+		if ctx.Err() == nil {
 			log.Error(errors.Wrap(err, "failed to GET"))
-			return
 		}
-		defer r.Body.Close()
+		return
+	}
+	defer r.Body.Close()
 
-		// Try and use the image type from the MIME header over the type from
-		// the URL, as it is more reliable.
-		if mime := mimeFromHeaders(r.Header); mime != "" {
-			mimeType = mime
-		}
+	// Try and use the image type from the MIME header over the type from
+	// the URL, as it is more reliable.
+	if mime := mimeFromHeaders(r.Header); mime != "" {
+		mimeType = mime
+	}
 
-		_, fileType := path.Split(mimeType) // abuse split "a/b" to get b
+	_, fileType := path.Split(mimeType) // abuse split "a/b" to get b
 
-		isGIF := fileType == "gif"
-		if isGIF {
-			canSurface = false
-			scale = 1
-		}
+	// We can't use a Surface for a GIF.
+	isGIF := fileType == "gif"
+	if isGIF {
+		scale = 1
+	}
 
-		// Only bother with this if we even have HiDPI. We also can't use a
-		// Surface for a GIF.
-		if canSurface && scale > 1 {
-			img = &surfaceWrapper{surfaceContainer, scale}
-		}
+	l, err := gdk.PixbufLoaderNewWithType(fileType)
+	if err != nil {
+		log.Error(errors.Wrapf(err, "failed to make PixbufLoader type %q", fileType))
+		return
+	}
 
-		l, err := gdk.PixbufLoaderNewWithType(fileType)
-		if err != nil {
-			log.Error(errors.Wrapf(err, "failed to make PixbufLoader type %q", fileType))
-			return
+	l.Connect("size-prepared", func(l *gdk.PixbufLoader, imgW, imgH int) {
+		w, h = imgutil.MaxSize(imgW, imgH, w, h)
+		if w != imgW || h != imgH || scale > 1 {
+			l.SetSize(w*scale, h*scale)
 		}
+	})
 
-		l.Connect("size-prepared", func(l *gdk.PixbufLoader, imgW, imgH int) {
-			w, h = imgutil.MaxSize(imgW, imgH, w, h)
-			if w != imgW || h != imgH || scale > 1 {
-				l.SetSize(w*scale, h*scale)
+	load := func(l *gdk.PixbufLoader) {
+		if pixbuf == nil {
+			if !isGIF {
+				pixbuf, _ = l.GetPixbuf()
+			} else {
+				pixbuf, _ = l.GetAnimation()
 			}
-		})
+		}
 
-		load := loadFn(ctx, img, isGIF)
-		l.Connect("area-prepared", load)
-		l.Connect("area-updated", load)
+		updateImage(job, pixbuf)
+	}
 
-		// Borrow a buffered writer and return it at the end.
-		bufWriter := bufferedWriter(l)
-		defer returnBufferedWriter(bufWriter)
+	l.Connect("area-prepared", load)
+	l.Connect("area-updated", load)
 
-		if err := downloadImage(r.Body, bufWriter, procs, isGIF); err != nil {
+	// Borrow a buffered writer and return it at the end.
+	bufWriter := bufferedWriter(l)
+	defer returnBufferedWriter(bufWriter)
+
+	var failed bool
+
+	if err := downloadImage(r.Body, bufWriter, processFuncs(procs), isGIF); err != nil {
+		if ctx.Err() == nil {
 			log.Error(errors.Wrapf(err, "failed to download %q", imageURL))
-			// Force close after downloading.
 		}
+		failed = true
+		// Force close after downloading.
+	}
 
-		if err := bufWriter.Flush(); err != nil {
-			log.Error(errors.Wrapf(err, "failed to flush writer for %q", imageURL))
-			// Force close after downloading.
-		}
+	if err := bufWriter.Flush(); err != nil {
+		log.Error(errors.Wrapf(err, "failed to flush writer for %q", imageURL))
+		failed = true
+		// Force close after downloading.
+	}
 
-		if err := l.Close(); err != nil {
-			log.Error(errors.Wrapf(err, "failed to close pixbuf loader for %q", imageURL))
-		}
-	}()
+	if err := l.Close(); err != nil {
+		log.Error(errors.Wrapf(err, "failed to close pixbuf loader for %q", imageURL))
+		failed = true
+	}
+
+	// Don't share a partially loaded image. The waiters that were given it
+	// already keep it.
+	if failed {
+		pixbuf = nil
+	}
+
+	return
 }
 
 func urlExt(anyURL string) string {
@@ -184,27 +242,6 @@ func mimeFromHeaders(headers http.Header) string {
 	return media
 }
 
-func loadFn(ctx context.Context, img ImageContainer, isGIF bool) func(l *gdk.PixbufLoader) {
-	var pixbuf interface{}
-
-	return func(l *gdk.PixbufLoader) {
-		if pixbuf == nil {
-			if !isGIF {
-				pixbuf, _ = l.GetPixbuf()
-			} else {
-				pixbuf, _ = l.GetAnimation()
-			}
-		}
-
-		switch pixbuf := pixbuf.(type) {
-		case *gdk.Pixbuf:
-			execIfCtx(ctx, func() { img.SetFromPixbuf(pixbuf) })
-		case *gdk.PixbufAnimation:
-			execIfCtx(ctx, func() { img.SetFromAnimation(pixbuf) })
-		}
-	}
-}
-
 func execIfCtx(ctx context.Context, fn func()) {
 	gts.ExecLater(func() {
 		if ctx.Err() == nil {
@@ -0,0 +1,306 @@
+package httputil
+
+import (
+	"container/list"
+	"runtime"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// MemoryBudget is the number of bytes of decoded images to keep before images
+// that aren't on screen are released. Released images are loaded again from
+// the disk cache once they're shown. 0 means no limit.
+var MemoryBudget int64 = 128 << 20
+
+// memoryInterval is how often the budget is checked, since scrolling moves
+// images off screen without loading new ones.
+const memoryInterval = 15 * time.Second
+
+var budgetOptions = []int64{64 << 20, 128 << 20, 256 << 20, 512 << 20, 0}
+
+var budgetIndex = 1
+
+func init() {
+	config.DeveloperAdd("Image Memory Budget", config.Combo(
+		&budgetIndex,
+		[]string{"64 MiB", "128 MiB", "256 MiB", "512 MiB", "Unlimited"},
+		func(i int) {
+			if i >= 0 && i < len(budgetOptions) {
+				MemoryBudget = budgetOptions[i]
+				enforceBudget()
+			}
+		},
+	))
+}
+
+// MemoryStats describes the decoded images that are tracked.
+type MemoryStats struct {
+	// Used is the number of bytes of decoded images held by widgets. Images
+	// shared by several widgets are only counted once.
+	Used int64
+	// Cached is the number of bytes of decoded images kept for reuse.
+	Cached int64
+	// Budget is MemoryBudget.
+	Budget int64
+	// Images is the number of widgets holding an image.
+	Images int
+	// Released is the number of times images were released since the start.
+	Released int
+}
+
+// trackedImage is an image held by a widget.
+type trackedImage struct {
+	img    ImageContainer
+	reload func()
+	// held is the image that the widget holds, or nil if it holds none.
+	held *heldImage
+	// released is true if the image was taken out of the widget, in which case
+	// it's reloaded when the widget is drawn again.
+	released bool
+}
+
+// heldImage is a decoded image held by one or more widgets.
+type heldImage struct {
+	key  interface{}
+	size int64
+	refs int
+}
+
+// memory tracks the decoded images. It must only be used in the main loop.
+var memory = struct {
+	images   map[ImageContainer]*list.Element // of *trackedImage
+	lru      *list.List                       // most recently set first
+	held     map[interface{}]*heldImage
+	used     int64
+	released int
+	started  bool
+}{
+	images: map[ImageContainer]*list.Element{},
+	lru:    list.New(),
+	held:   map[interface{}]*heldImage{},
+}
+
+// Memory returns the current memory statistics. It must be called in the main
+// loop.
+func Memory() MemoryStats {
+	return MemoryStats{
+		Used:     memory.used,
+		Cached:   cachedSize(),
+		Budget:   MemoryBudget,
+		Images:   len(memory.images) - countReleased(),
+		Released: memory.released,
+	}
+}
+
+func countReleased() (n int) {
+	for e := memory.lru.Front(); e != nil; e = e.Next() {
+		if e.Value.(*trackedImage).released {
+			n++
+		}
+	}
+	return
+}
+
+func pixbufSize(pb *gdk.Pixbuf) int64 {
+	if pb == nil {
+		return 0
+	}
+	return int64(pb.GetRowstride()) * int64(pb.GetHeight())
+}
+
+// holdImage adds a reference to the image with the given key, which is counted
+// once no matter how many widgets hold it.
+func holdImage(key interface{}, size int64) *heldImage {
+	held, ok := memory.held[key]
+	if !ok {
+		held = &heldImage{key: key}
+		memory.held[key] = held
+	}
+
+	// Partially loaded images may grow.
+	memory.used += size - held.size
+	held.size = size
+	held.refs++
+
+	return held
+}
+
+// unholdImage removes a reference to the image. The image isn't counted
+// anymore once nothing holds it.
+func unholdImage(held *heldImage) {
+	if held == nil {
+		return
+	}
+
+	held.refs--
+
+	if held.refs <= 0 {
+		memory.used -= held.size
+		delete(memory.held, held.key)
+	}
+}
+
+// trackImage records that the widget now holds an image of the given size.
+// Widgets holding the same key share the image. A nil key means that the
+// image isn't shared. Reload is called to load the image again after it's
+// released.
+func trackImage(img ImageContainer, key interface{}, size int64, reload func()) {
+	if !memory.started {
+		memory.started = true
+		gts.AfterFunc(memoryInterval, enforceBudget)
+	}
+
+	if elem, ok := memory.images[img]; ok {
+		tracked := elem.Value.(*trackedImage)
+		if key == nil {
+			key = tracked
+		}
+
+		// Hold the new image before letting go of the old one, since it may be
+		// the same one.
+		held := holdImage(key, size)
+		unholdImage(tracked.held)
+
+		tracked.held = held
+		tracked.reload = reload
+		tracked.released = false
+		memory.lru.MoveToFront(elem)
+	} else {
+		tracked := &trackedImage{img: img, reload: reload}
+		if key == nil {
+			key = tracked
+		}
+
+		tracked.held = holdImage(key, size)
+		memory.images[img] = memory.lru.PushFront(tracked)
+
+		img.Connect("destroy", func(interface{}) { untrackImage(img) })
+		// Released images are loaded again once they're drawn, which is only
+		// done when they're on screen.
+		img.Connect("draw", func() bool {
+			if tracked.released {
+				tracked.released = false
+				tracked.reload()
+			}
+			return false
+		})
+	}
+
+	enforceBudget()
+}
+
+func untrackImage(img ImageContainer) {
+	elem, ok := memory.images[img]
+	if !ok {
+		return
+	}
+
+	unholdImage(elem.Value.(*trackedImage).held)
+	memory.lru.Remove(elem)
+	delete(memory.images, img)
+}
+
+// enforceBudget releases the least recently loaded images that aren't on
+// screen until the memory used is within the budget.
+func enforceBudget() {
+	if MemoryBudget <= 0 || memory.used <= MemoryBudget {
+		return
+	}
+
+	var released bool
+
+	for e := memory.lru.Back(); e != nil && memory.used > MemoryBudget; e = e.Prev() {
+		tracked := e.Value.(*trackedImage)
+		if tracked.released || onScreen(tracked.img) {
+			continue
+		}
+
+		releaseImage(tracked)
+		released = true
+	}
+
+	if released {
+		collect()
+	}
+}
+
+// ReleaseHidden releases the images of all widgets that aren't mapped, such as
+// the ones in views that were switched away from. It must be called in the
+// main loop.
+func ReleaseHidden() {
+	var released bool
+
+	for e := memory.lru.Front(); e != nil; e = e.Next() {
+		tracked := e.Value.(*trackedImage)
+		if tracked.released || isMapped(tracked.img) {
+			continue
+		}
+
+		releaseImage(tracked)
+		released = true
+	}
+
+	if released {
+		collect()
+	}
+}
+
+func releaseImage(tracked *trackedImage) {
+	tracked.img.SetFromPixbuf(nil)
+	tracked.released = true
+
+	unholdImage(tracked.held)
+	tracked.held = nil
+	memory.released++
+}
+
+// collect runs the garbage collector in the background. Pixbufs are only
+// unreferenced by their finalizers, so released images aren't freed until then.
+func collect() {
+	go runtime.GC()
+}
+
+func isMapped(img ImageContainer) bool {
+	w, ok := img.(interface{ ToWidget() *gtk.Widget })
+	return ok && w.ToWidget().GetMapped()
+}
+
+// onScreen returns true if the widget is mapped and within the bounds of all
+// of its parents, which is not the case if it's scrolled away.
+func onScreen(img ImageContainer) bool {
+	w, ok := img.(interface{ ToWidget() *gtk.Widget })
+	if !ok {
+		// Assume that unknown widgets are visible.
+		return true
+	}
+
+	widget := w.ToWidget()
+	if !widget.GetMapped() {
+		return false
+	}
+
+	var width = widget.GetAllocatedWidth()
+	var height = widget.GetAllocatedHeight()
+
+	for parent, _ := widget.GetParent(); parent != nil; {
+		p := parent.ToWidget()
+
+		x, y, err := widget.TranslateCoordinates(p, 0, 0)
+		if err != nil {
+			return true
+		}
+
+		if x+width < 0 || y+height < 0 ||
+			x > p.GetAllocatedWidth() || y > p.GetAllocatedHeight() {
+			return false
+		}
+
+		parent, _ = p.GetParent()
+	}
+
+	return true
+}
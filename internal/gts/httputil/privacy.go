@@ -0,0 +1,207 @@
+package httputil
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/pkg/errors"
+)
+
+// Remote media policies, in the order of the preferences combo box.
+const (
+	LoadAllMedia = iota
+	LoadAllowedMedia
+	LoadNoMedia
+)
+
+// BlockedContainer is an ImageContainer that can show a placeholder for media
+// that's blocked. SetBlocked is called with the URL when the media is blocked
+// and with an empty string right before it's loaded.
+type BlockedContainer interface {
+	ImageContainer
+	SetBlocked(url string)
+}
+
+var privacy = struct {
+	sync.Mutex
+	policy   int
+	domains  string
+	allowed  bypassList
+	loaded   map[string]struct{} // URLs loaded for this session
+	blocked  map[primitives.Connector]*blockedMedia
+	watching map[primitives.Connector]struct{}
+}{
+	loaded:   map[string]struct{}{},
+	blocked:  map[primitives.Connector]*blockedMedia{},
+	watching: map[primitives.Connector]struct{}{},
+}
+
+type blockedMedia struct {
+	url  string
+	load func()
+}
+
+func init() {
+	config.MediaAdd("Remote Media", config.Combo(
+		&privacy.policy,
+		[]string{"Load All", "Load from Allowed Domains", "Never Load"},
+		func(int) { loadAllowed() },
+	))
+	config.MediaAdd("Allowed Domains", config.InputEntry(&privacy.domains, func(v string) error {
+		privacy.Lock()
+		privacy.allowed = newBypassList(splitList(v))
+		privacy.Unlock()
+
+		loadAllowed()
+		return nil
+	}))
+}
+
+// MediaAllowed returns true if the media at the given URL can be loaded
+// without asking.
+func MediaAllowed(mediaURL string) bool {
+	privacy.Lock()
+	defer privacy.Unlock()
+
+	return mediaAllowed(mediaURL)
+}
+
+func mediaAllowed(mediaURL string) bool {
+	switch privacy.policy {
+	case LoadAllMedia:
+		return true
+	case LoadAllowedMedia:
+		if privacy.allowed.match(MediaHost(mediaURL)) {
+			return true
+		}
+	}
+
+	_, loaded := privacy.loaded[mediaURL]
+	return loaded
+}
+
+// MediaHost returns the host name of the URL, or an empty string if it's
+// invalid.
+func MediaHost(mediaURL string) string {
+	u, err := url.Parse(mediaURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// OnUnblock calls load in the main loop once the blocked media at the given
+// URL is allowed, unless the widget is destroyed before then. A widget only
+// waits for one URL at a time.
+func OnUnblock(w primitives.Connector, mediaURL string, load func()) {
+	privacy.Lock()
+	defer privacy.Unlock()
+
+	privacy.blocked[w] = &blockedMedia{mediaURL, load}
+
+	if _, ok := privacy.watching[w]; ok {
+		return
+	}
+
+	privacy.watching[w] = struct{}{}
+
+	w.Connect("destroy", func(interface{}) {
+		privacy.Lock()
+		delete(privacy.blocked, w)
+		delete(privacy.watching, w)
+		privacy.Unlock()
+	})
+}
+
+// blockImage shows the placeholder in the container and loads the image once
+// it's allowed.
+func blockImage(img ImageContainer, imageURL string, reload func()) {
+	blocked, ok := img.(BlockedContainer)
+	if ok {
+		blocked.SetBlocked(imageURL)
+	}
+
+	OnUnblock(img, imageURL, func() {
+		if ok {
+			blocked.SetBlocked("")
+		}
+		reload()
+	})
+}
+
+// LoadBlocked loads the blocked media at the given URL for this session.
+func LoadBlocked(mediaURL string) {
+	privacy.Lock()
+	privacy.loaded[mediaURL] = struct{}{}
+	privacy.Unlock()
+
+	loadAllowed()
+}
+
+// AllowDomain adds the domain of the URL to the allowed domains and loads the
+// media from it. If all media was blocked, the policy is changed to load media
+// from allowed domains, since the list would be ignored otherwise.
+func AllowDomain(mediaURL string) {
+	host := MediaHost(mediaURL)
+	if host == "" {
+		return
+	}
+
+	privacy.Lock()
+
+	domains := append(splitList(privacy.domains), host)
+	privacy.domains = strings.Join(domains, ", ")
+	privacy.allowed = newBypassList(domains)
+
+	if privacy.policy == LoadNoMedia {
+		privacy.policy = LoadAllowedMedia
+	}
+
+	privacy.Unlock()
+
+	if err := config.Save(); err != nil {
+		log.Error(errors.Wrap(err, "Failed to save allowed domains"))
+	}
+
+	loadAllowed()
+}
+
+// loadAllowed loads the blocked media that's now allowed. It must be called in
+// the main loop. Nothing is blocked yet when the config is restored, so it's a
+// no-op then.
+func loadAllowed() {
+	var loads []func()
+
+	privacy.Lock()
+
+	for w, blocked := range privacy.blocked {
+		if mediaAllowed(blocked.url) {
+			loads = append(loads, blocked.load)
+			delete(privacy.blocked, w)
+		}
+	}
+
+	privacy.Unlock()
+
+	for _, load := range loads {
+		load()
+	}
+}
+
+// BlockedMenuItems returns the menu items to load the blocked media at the
+// given URL.
+func BlockedMenuItems(mediaURL string) []gtk.IMenuItem {
+	return []gtk.IMenuItem{
+		primitives.MenuItem("Load", func() { LoadBlocked(mediaURL) }),
+		primitives.MenuItem(
+			fmt.Sprintf("Always Load from %s", MediaHost(mediaURL)),
+			func() { AllowDomain(mediaURL) },
+		),
+	}
+}
@@ -0,0 +1,45 @@
+package httputil
+
+import (
+	"fmt"
+
+	"github.com/diamondburned/imgutil"
+)
+
+// Processor processes a downloaded image. Its key identifies it along with its
+// parameters in the image cache, so processors with the same key must give the
+// same image.
+type Processor struct {
+	Key     string
+	Process imgutil.Processor
+}
+
+// Round returns a Processor that renders a round image; see imgutil.Round.
+func Round(antialias bool) Processor {
+	return Processor{
+		Key:     fmt.Sprintf("round(%t)", antialias),
+		Process: imgutil.Round(antialias),
+	}
+}
+
+// Resize returns a Processor that fits the image into the given size; see
+// imgutil.Resize.
+func Resize(maxW, maxH int) Processor {
+	return Processor{
+		Key:     fmt.Sprintf("resize(%d,%d)", maxW, maxH),
+		Process: imgutil.Resize(maxW, maxH),
+	}
+}
+
+func processFuncs(procs []Processor) []imgutil.Processor {
+	if len(procs) == 0 {
+		return nil
+	}
+
+	var funcs = make([]imgutil.Processor, len(procs))
+	for i, proc := range procs {
+		funcs[i] = proc.Process
+	}
+
+	return funcs
+}
@@ -0,0 +1,30 @@
+package httputil
+
+import "testing"
+
+func TestImageKeyProcessors(t *testing.T) {
+	const url = "https://example.com/image.png"
+
+	var tests = []struct {
+		name  string
+		a, b  []Processor
+		equal bool
+	}{
+		{"same", []Processor{Resize(10, 10)}, []Processor{Resize(10, 10)}, true},
+		{"sizes", []Processor{Resize(10, 10)}, []Processor{Resize(20, 20)}, false},
+		{"options", []Processor{Round(true)}, []Processor{Round(false)}, false},
+		{"order", []Processor{Round(true), Resize(10, 10)}, []Processor{Resize(10, 10), Round(true)}, false},
+		{"none", nil, []Processor{Round(true)}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newImageKey(url, 10, 10, 1, test.a)
+			b := newImageKey(url, 10, 10, 1, test.b)
+
+			if (a == b) != test.equal {
+				t.Fatalf("Keys %v and %v, expected equal = %v", a, b, test.equal)
+			}
+		})
+	}
+}
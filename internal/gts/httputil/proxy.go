@@ -0,0 +1,251 @@
+package httputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/pkg/errors"
+)
+
+// NetworkSettings describes how media is fetched.
+type NetworkSettings struct {
+	// Proxy is the URL of the proxy to use, with the scheme http, https or
+	// socks5. If it's empty, the proxy is taken from the environment.
+	Proxy string
+	// NoProxy is the list of hosts that are connected to directly. Hosts also
+	// match their subdomains, and a leading dot only matches subdomains. CIDR
+	// ranges and "*" for all hosts are also accepted. It also applies to the
+	// proxy from the environment, on top of its NO_PROXY.
+	NoProxy []string
+	// CAFiles is the list of PEM files of extra certificate authorities to
+	// trust along with the system ones.
+	CAFiles []string
+}
+
+var network = struct {
+	sync.Mutex
+	proxy   string
+	noProxy string
+	caFiles string
+}{}
+
+func init() {
+	config.NetworkAdd("Proxy", config.LazyInputEntry(&network.proxy, func(v string) error {
+		return updateNetwork(func() { network.proxy = v })
+	}))
+	config.NetworkAdd("No Proxy", config.LazyInputEntry(&network.noProxy, func(v string) error {
+		return updateNetwork(func() { network.noProxy = v })
+	}))
+	config.NetworkAdd("CA Certificates", config.LazyInputEntry(&network.caFiles, func(v string) error {
+		return updateNetwork(func() { network.caFiles = v })
+	}))
+}
+
+// updateNetwork applies the network preferences after calling fn, which
+// changes them.
+func updateNetwork(fn func()) error {
+	network.Lock()
+	defer network.Unlock()
+
+	fn()
+
+	return SetNetwork(NetworkSettings{
+		Proxy:   strings.TrimSpace(network.proxy),
+		NoProxy: splitList(network.noProxy),
+		CAFiles: splitList(network.caFiles),
+	})
+}
+
+// splitList splits a comma-separated list and drops empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// transport is the http.RoundTripper used by the cached client. It forwards
+// requests to the current http.Transport, which is replaced when the network
+// settings change.
+type transport struct {
+	v atomic.Value // *http.Transport
+}
+
+var mediaTransport = newTransport()
+
+func newTransport() *transport {
+	var t transport
+	t.v.Store(newHTTPTransport(http.ProxyFromEnvironment, nil))
+	return &t
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return t.v.Load().(*http.Transport).RoundTrip(r)
+}
+
+func (t *transport) set(h *http.Transport) {
+	old := t.v.Load().(*http.Transport)
+	t.v.Store(h)
+	old.CloseIdleConnections()
+}
+
+func newHTTPTransport(proxy func(*http.Request) (*url.URL, error), tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+		// Be generous: use a 128KB buffer instead of 4KB to hopefully
+		// reduce cgo calls.
+		WriteBufferSize: 128 * 1024,
+		ReadBufferSize:  128 * 1024,
+	}
+}
+
+// SetNetwork changes how media is fetched. The previous settings are kept if
+// the given ones are invalid.
+func SetNetwork(s NetworkSettings) error {
+	proxy, err := proxyFunc(s.Proxy, s.NoProxy)
+	if err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+
+	if len(s.CAFiles) > 0 {
+		pool, err := certPool(s.CAFiles)
+		if err != nil {
+			return err
+		}
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
+
+	mediaTransport.set(newHTTPTransport(proxy, tlsConfig))
+	return nil
+}
+
+// proxyFunc returns the proxy function for the given proxy URL, which uses the
+// environment if the URL is empty. The hosts in noProxy are never proxied.
+func proxyFunc(proxyURL string, noProxy []string) (func(*http.Request) (*url.URL, error), error) {
+	var bypass = newBypassList(noProxy)
+
+	if proxyURL == "" {
+		return func(r *http.Request) (*url.URL, error) {
+			if bypass.match(r.URL.Hostname()) {
+				return nil, nil
+			}
+			return http.ProxyFromEnvironment(r)
+		}, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid proxy URL")
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.Errorf("Unsupported proxy scheme %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, errors.New("Proxy URL has no host")
+	}
+
+	return func(r *http.Request) (*url.URL, error) {
+		if bypass.match(r.URL.Hostname()) {
+			return nil, nil
+		}
+		return u, nil
+	}, nil
+}
+
+// bypassList is the list of hosts that shouldn't go through the proxy.
+type bypassList struct {
+	all     bool
+	domains []string
+	nets    []*net.IPNet
+}
+
+func newBypassList(hosts []string) bypassList {
+	var list bypassList
+
+	for _, host := range hosts {
+		if host == "*" {
+			list.all = true
+			continue
+		}
+
+		if _, ipnet, err := net.ParseCIDR(host); err == nil {
+			list.nets = append(list.nets, ipnet)
+			continue
+		}
+
+		list.domains = append(list.domains, strings.ToLower(host))
+	}
+
+	return list
+}
+
+func (list bypassList) match(host string) bool {
+	if list.all {
+		return true
+	}
+
+	host = strings.ToLower(host)
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, ipnet := range list.nets {
+			if ipnet.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	for _, domain := range list.domains {
+		if strings.HasPrefix(domain, ".") {
+			if strings.HasSuffix(host, domain) {
+				return true
+			}
+			continue
+		}
+
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// certPool returns the system certificate pool with the certificates in the
+// given PEM files added.
+func certPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read CA file")
+		}
+
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("No certificates found in %s", file)
+		}
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,103 @@
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	// The stand-in proxy answers every request itself and sends the host that
+	// was asked for.
+	var requested = make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.URL.Host
+		w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+
+	defer SetNetwork(NetworkSettings{})
+
+	err := SetNetwork(NetworkSettings{
+		Proxy:   proxy.URL,
+		NoProxy: []string{"direct.invalid"},
+	})
+	if err != nil {
+		t.Fatal("Failed to set network:", err)
+	}
+
+	client := http.Client{Transport: mediaTransport}
+
+	r, err := client.Get("http://media.invalid/image.png")
+	if err != nil {
+		t.Fatal("Failed to get through proxy:", err)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+
+	if host := <-requested; string(b) != "proxied" || host != "media.invalid" {
+		t.Fatalf("Unexpected response %q for host %q", b, host)
+	}
+
+	// Bypassed hosts are dialed directly, which fails for .invalid.
+	if _, err := client.Get("http://cdn.direct.invalid/image.png"); err == nil {
+		t.Fatal("Unexpected success for bypassed host")
+	}
+
+	select {
+	case host := <-requested:
+		t.Fatalf("Bypassed host %q went through the proxy", host)
+	default:
+	}
+}
+
+func TestProxyInvalid(t *testing.T) {
+	var tests = []string{
+		"ftp://localhost:21",
+		"socks5://",
+		"://",
+	}
+
+	for _, test := range tests {
+		if err := SetNetwork(NetworkSettings{Proxy: test}); err == nil {
+			t.Errorf("Unexpected success for proxy %q", test)
+		}
+	}
+}
+
+func TestProxyEnvironmentBypass(t *testing.T) {
+	proxy, err := proxyFunc("", []string{"*"})
+	if err != nil {
+		t.Fatal("Failed to make proxy func:", err)
+	}
+
+	r, _ := http.NewRequest("GET", "http://media.invalid/image.png", nil)
+
+	if u, err := proxy(r); err != nil || u != nil {
+		t.Fatalf("Bypassed host went through proxy %v, error %v", u, err)
+	}
+}
+
+func TestBypassList(t *testing.T) {
+	list := newBypassList([]string{"example.com", ".internal", "10.0.0.0/8"})
+
+	var tests = []struct {
+		host  string
+		match bool
+	}{
+		{"example.com", true},
+		{"cdn.Example.com", true},
+		{"notexample.com", false},
+		{"internal", false},
+		{"media.internal", true},
+		{"10.1.2.3", true},
+		{"192.168.1.1", false},
+	}
+
+	for _, test := range tests {
+		if match := list.match(test.host); match != test.match {
+			t.Errorf("match(%q) = %v, expected %v", test.host, match, test.match)
+		}
+	}
+}
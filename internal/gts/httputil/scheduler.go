@@ -0,0 +1,218 @@
+package httputil
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/gotk3/gotk3/glib"
+)
+
+// Priority is the priority of an image download. Downloads with a higher
+// priority are started first.
+type Priority int
+
+const (
+	PriorityOffscreen Priority = iota
+	PriorityMemberList
+	PriorityVisible
+)
+
+// MaxDownloads is the maximum number of images downloaded at once.
+var MaxDownloads = 6
+
+// PriorityHinter is an optional interface for an ImageContainer to give the
+// priority of its image while it's not on screen. Images on screen always come
+// first.
+type PriorityHinter interface {
+	ImageContainer
+	DownloadPriority() Priority
+}
+
+type loadFunc = func(ctx context.Context, job *imageJob) interface{}
+
+// imageJob is the download of an image, which is shared by its waiters. It's
+// cancelled once all of its waiters are gone.
+type imageJob struct {
+	key     imageKey
+	seq     uint64 // order of the request, to break ties
+	load    loadFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters []*imageWaiter
+	started bool
+}
+
+// watch removes the waiter from the job once its container is destroyed. It
+// returns once the job is done.
+func (job *imageJob) watch(waiter *imageWaiter) {
+	select {
+	case <-waiter.ctx.Done():
+		images.Lock()
+		job.remove(waiter)
+		images.Unlock()
+	case <-job.ctx.Done():
+	}
+}
+
+// remove removes the waiter from the job and cancels the job if it was the
+// last one. It must be called with images locked.
+func (job *imageJob) remove(waiter *imageWaiter) {
+	for i, w := range job.waiters {
+		if w == waiter {
+			job.waiters = append(job.waiters[:i], job.waiters[i+1:]...)
+			break
+		}
+	}
+
+	if len(job.waiters) > 0 {
+		return
+	}
+
+	job.cancel()
+
+	if images.loading[job.key] == job {
+		delete(images.loading, job.key)
+	}
+}
+
+// priority returns the highest priority of the job's waiters. Waiters whose
+// containers were on screen but were scrolled away are taken out of the job
+// and wait until they're drawn again, and the job is cancelled if they were the
+// last ones. False is returned if no waiters are left. It must be called in the
+// main loop with images locked.
+func (job *imageJob) priority() (Priority, bool) {
+	var priority = PriorityOffscreen
+
+	// Copy the waiters, since they may be removed.
+	for _, waiter := range append([]*imageWaiter(nil), job.waiters...) {
+		// The watcher will remove the waiter.
+		if waiter.ctx.Err() != nil {
+			continue
+		}
+
+		p, visible := waiter.priority()
+
+		switch {
+		case visible:
+			waiter.seen = true
+		case waiter.seen:
+			job.remove(waiter)
+			waiter.deferLoad()
+			continue
+		}
+
+		if p > priority {
+			priority = p
+		}
+	}
+
+	return priority, len(job.waiters) > 0
+}
+
+func (w *imageWaiter) priority() (p Priority, visible bool) {
+	if onScreen(w.img) {
+		return PriorityVisible, true
+	}
+
+	if hinter, ok := w.img.(PriorityHinter); ok {
+		return hinter.DownloadPriority(), false
+	}
+
+	return PriorityOffscreen, false
+}
+
+// deferLoad requests the image again once the container is drawn, which is
+// only done when it's back on screen.
+func (w *imageWaiter) deferLoad() {
+	var handle glib.SignalHandle
+	handle = w.img.Connect("draw", func() bool {
+		w.img.HandlerDisconnect(handle)
+		w.reload()
+		return false
+	})
+}
+
+// recheckInterval is how often running downloads are checked for containers
+// that were scrolled away, since scrolling doesn't dispatch anything.
+const recheckInterval = 500 * time.Millisecond
+
+// scheduleDispatch starts the queued jobs in the main loop. This is done after
+// the containers are laid out, so that it's known which ones are on screen. It
+// must be called with images locked.
+func scheduleDispatch() {
+	if images.dispatching {
+		return
+	}
+
+	images.dispatching = true
+	gts.ExecLater(dispatch)
+}
+
+// scheduleRecheck dispatches again after recheckInterval. It must be called
+// with images locked.
+func scheduleRecheck() {
+	if images.rechecking {
+		return
+	}
+
+	images.rechecking = true
+	gts.DoAfter(recheckInterval, func() {
+		images.Lock()
+		images.rechecking = false
+		images.Unlock()
+
+		dispatch()
+	})
+}
+
+// dispatch starts the queued jobs with the highest priorities until
+// MaxDownloads jobs are running. Waiters that were scrolled away are taken out
+// of all jobs, including the running ones, which are cancelled once nobody
+// waits for them.
+func dispatch() {
+	images.Lock()
+	defer images.Unlock()
+
+	images.dispatching = false
+
+	// The priorities are only worked out once per dispatch, since looking up
+	// whether a container is on screen walks up its parents.
+	var queued []*imageJob
+	var priorities = map[*imageJob]Priority{}
+
+	for _, job := range images.loading {
+		priority, ok := job.priority()
+		if !ok || job.started {
+			continue
+		}
+
+		queued = append(queued, job)
+		priorities[job] = priority
+	}
+
+	sort.Slice(queued, func(i, j int) bool {
+		pi, pj := priorities[queued[i]], priorities[queued[j]]
+		if pi != pj {
+			return pi > pj
+		}
+		return queued[i].seq < queued[j].seq
+	})
+
+	for _, job := range queued {
+		if images.running >= MaxDownloads {
+			break
+		}
+
+		job := job
+		job.started = true
+		images.running++
+
+		go func() { finishImage(job, job.load(job.ctx, job)) }()
+	}
+
+	if images.running > 0 {
+		scheduleRecheck()
+	}
+}
@@ -0,0 +1,231 @@
+package gts
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/pkg/errors"
+)
+
+// CallbackBudget is how long a callback can block the main loop before it's
+// logged as slow.
+var CallbackBudget = 50 * time.Millisecond
+
+// StallTimeout is how long the main loop can be unresponsive before the
+// watchdog logs it.
+var StallTimeout = time.Second
+
+// LatencyBuckets are the upper bounds of the histogram buckets. Latencies
+// above the last bound go into an extra bucket.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// maxSlowSites is the number of call sites returned in PerfStats.
+const maxSlowSites = 20
+
+// Histogram counts latencies into LatencyBuckets.
+type Histogram struct {
+	Counts []int // len(LatencyBuckets)+1
+	Total  int
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+func newHistogram() Histogram {
+	return Histogram{Counts: make([]int, len(LatencyBuckets)+1)}
+}
+
+func (h *Histogram) add(d time.Duration) {
+	var i = sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+	h.Counts[i]++
+	h.Total++
+	h.Sum += d
+
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+func (h Histogram) copy() Histogram {
+	h.Counts = append([]int(nil), h.Counts...)
+	return h
+}
+
+// SlowSite is a call site whose callbacks went over the budget.
+type SlowSite struct {
+	Site  string
+	Count int
+	Max   time.Duration
+}
+
+// PerfStats is a snapshot of the main loop callback statistics.
+type PerfStats struct {
+	// Wait is the time callbacks wait in the queue before they're run.
+	Wait Histogram
+	// Run is the time callbacks block the main loop.
+	Run Histogram
+	// Slow is the list of call sites whose callbacks went over the budget,
+	// slowest first.
+	Slow []SlowSite
+}
+
+var perf struct {
+	sync.Mutex
+	wait Histogram
+	run  Histogram
+	slow map[string]*SlowSite
+
+	// running is the site of the running callback, if any.
+	running string
+}
+
+func init() {
+	ResetPerf()
+}
+
+// Perf returns a snapshot of the callback statistics.
+func Perf() PerfStats {
+	perf.Lock()
+	defer perf.Unlock()
+
+	var stats = PerfStats{
+		Wait: perf.wait.copy(),
+		Run:  perf.run.copy(),
+		Slow: make([]SlowSite, 0, len(perf.slow)),
+	}
+
+	for _, site := range perf.slow {
+		stats.Slow = append(stats.Slow, *site)
+	}
+
+	sort.Slice(stats.Slow, func(i, j int) bool {
+		return stats.Slow[i].Max > stats.Slow[j].Max
+	})
+
+	if len(stats.Slow) > maxSlowSites {
+		stats.Slow = stats.Slow[:maxSlowSites]
+	}
+
+	return stats
+}
+
+// ResetPerf clears the callback statistics.
+func ResetPerf() {
+	perf.Lock()
+	defer perf.Unlock()
+
+	perf.wait = newHistogram()
+	perf.run = newHistogram()
+	perf.slow = map[string]*SlowSite{}
+}
+
+// callerSite returns the file and line of the caller, skipping the given
+// number of frames above the function calling this.
+func callerSite(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 2)
+	if !ok {
+		return "unknown"
+	}
+
+	// Keep the package directory for context.
+	file = filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// instrument wraps the callback to measure it. The site is the caller that
+// scheduled the callback.
+func instrument(site string, fn func()) func() {
+	var queued = time.Now()
+
+	return func() {
+		var start = time.Now()
+
+		perf.Lock()
+		perf.running = site
+		perf.Unlock()
+
+		fn()
+
+		var run = time.Since(start)
+
+		perf.Lock()
+		perf.running = ""
+		perf.wait.add(start.Sub(queued))
+		perf.run.add(run)
+
+		if run > CallbackBudget {
+			slow, ok := perf.slow[site]
+			if !ok {
+				slow = &SlowSite{Site: site}
+				perf.slow[site] = slow
+			}
+			slow.Count++
+			if run > slow.Max {
+				slow.Max = run
+			}
+		}
+
+		perf.Unlock()
+
+		if run > CallbackBudget {
+			log.Warn(errors.Errorf("Main loop callback from %s took %v", site, run))
+		}
+	}
+}
+
+// watchdog periodically pings the main loop and logs when it's been
+// unresponsive for longer than StallTimeout.
+func watchdog() {
+	var pong = make(chan struct{}, 1)
+
+	for {
+		var sent = time.Now()
+		var reported bool
+
+		glib.IdleAddPriority(glib.PRIORITY_HIGH, func() { pong <- struct{}{} })
+
+	wait:
+		for {
+			select {
+			case <-pong:
+				break wait
+			case <-time.After(StallTimeout / 2):
+			}
+
+			if stalled := time.Since(sent); !reported && stalled > StallTimeout {
+				reported = true
+
+				perf.Lock()
+				var where = "an unknown callback"
+				if perf.running != "" {
+					where = "a callback from " + perf.running
+				}
+				perf.Unlock()
+
+				log.Warn(errors.Errorf("Main loop stalled for %v in %s", stalled, where))
+			}
+		}
+
+		if reported {
+			log.Warn(errors.Errorf("Main loop recovered after %v", time.Since(sent)))
+		}
+
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// fileName is the name of the current log file. Rotated files have a
+	// number appended.
+	fileName = "cchat-gtk.log"
+	// maxFileSize is the size that a log file is rotated at.
+	maxFileSize = 4 * 1024 * 1024
+	// maxFiles is the number of rotated log files to keep.
+	maxFiles = 4
+)
+
+var logFile struct {
+	sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Dir returns the directory of log files, which is in the XDG state
+// directory.
+func Dir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "cchat-gtk")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "cchat-gtk")
+	}
+
+	return filepath.Join(home, ".local", "state", "cchat-gtk")
+}
+
+// Files returns the paths of all log files from the newest.
+func Files() []string {
+	var files []string
+
+	for i := 0; i <= maxFiles; i++ {
+		path := filePath(i)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+
+	return files
+}
+
+func filePath(n int) string {
+	if n == 0 {
+		return filepath.Join(Dir(), fileName)
+	}
+	return filepath.Join(Dir(), fileName+"."+strconv.Itoa(n))
+}
+
+// StartFile starts writing all entries into a new log file. The log file of
+// the last run is rotated away first.
+func StartFile() error {
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return errors.Wrap(err, "Failed to make log dir")
+	}
+
+	logFile.Lock()
+	defer logFile.Unlock()
+
+	if err := rotate(); err != nil {
+		return err
+	}
+
+	AddEntryHandler(writeFile)
+	return nil
+}
+
+// rotate shifts all log files by one and opens a new one. The lock must be
+// acquired.
+func rotate() error {
+	if logFile.file != nil {
+		logFile.file.Close()
+		logFile.file = nil
+	}
+
+	os.Remove(filePath(maxFiles))
+
+	for i := maxFiles - 1; i >= 0; i-- {
+		if err := os.Rename(filePath(i), filePath(i+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "Failed to rotate log file")
+		}
+	}
+
+	f, err := os.OpenFile(filePath(0), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open log file")
+	}
+
+	logFile.file = f
+	logFile.size = 0
+	return nil
+}
+
+func writeFile(entry Entry) {
+	logFile.Lock()
+	defer logFile.Unlock()
+
+	if logFile.file == nil {
+		return
+	}
+
+	if logFile.size >= maxFileSize {
+		if err := rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
+
+	n, _ := fmt.Fprintln(logFile.file, entry)
+	logFile.size += int64(n)
+}
@@ -4,36 +4,144 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Level is the severity of a log entry.
+type Level uint8
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Levels contains all levels from the least severe.
+var Levels = []Level{LevelDebug, LevelInfo, LevelWarn, LevelError}
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "Debug"
+	case LevelInfo:
+		return "Info"
+	case LevelWarn:
+		return "Warn"
+	case LevelError:
+		return "Error"
+	default:
+		return fmt.Sprintf("Level(%d)", l)
+	}
+}
+
+// MaxEntries is the number of entries kept in memory. Older entries are
+// dropped.
+const MaxEntries = 2000
+
 var globalBuffer struct {
 	sync.Mutex
-	entries  []Entry
-	handlers []func(Entry)
+	entries  []Entry // ring buffer
+	next     int     // index of the next entry once the buffer is full
+	seq      uint64  // sequence of the last entry
+	handlers map[int]func(Entry)
+	handlerN int
 }
 
+// queue is where entries wait to be given to handlers, which keeps them in
+// order without blocking the writer. Entries are dropped for handlers if the
+// queue is full, though they're still kept in memory.
+var queue = make(chan Entry, 256)
+
+// dropped is the number of entries dropped since the last one was given to the
+// handlers.
+var dropped uint64
+
 func init() {
+	globalBuffer.handlers = map[int]func(Entry){}
+
 	AddEntryHandler(func(entry Entry) {
-		fmt.Fprintln(os.Stderr, entry)
+		if entry.Level >= LevelInfo {
+			fmt.Fprintln(os.Stderr, entry)
+		}
 	})
+
+	go func() {
+		for entry := range queue {
+			if n := atomic.SwapUint64(&dropped, 0); n > 0 {
+				handle(Entry{
+					Time:  entry.Time,
+					Level: LevelWarn,
+					Msg:   fmt.Sprintf("Dropped %d log entries, see the log viewer", n),
+				})
+			}
+
+			handle(entry)
+		}
+	}()
+}
+
+func handle(entry Entry) {
+	globalBuffer.Lock()
+	var handlers = make([]func(Entry), 0, len(globalBuffer.handlers))
+	for i := 0; i <= globalBuffer.handlerN; i++ {
+		if fn, ok := globalBuffer.handlers[i]; ok {
+			handlers = append(handlers, fn)
+		}
+	}
+	globalBuffer.Unlock()
+
+	for _, fn := range handlers {
+		fn(entry)
+	}
 }
 
 type Entry struct {
-	Time time.Time
-	Msg  string
+	Time  time.Time
+	Level Level
+	Msg   string
+
+	// Seq is the order of the entry, which starts from 1. It's set when the
+	// entry is written.
+	Seq uint64
 }
 
 func (entry Entry) String() string {
-	return entry.Time.Format(time.Stamp) + ": " + entry.Msg
+	return entry.Time.Format(time.Stamp) + ": " + entry.Level.String() + ": " + entry.Msg
 }
 
-// AddEntryHandler adds a handler, which will run asynchronously.
-func AddEntryHandler(fn func(Entry)) {
-	globalBuffer.handlers = append(globalBuffer.handlers, fn)
+// AddEntryHandler adds a handler, which will run asynchronously. Handlers are
+// called in the order that they're added. The returned callback removes the
+// handler.
+func AddEntryHandler(fn func(Entry)) (remove func()) {
+	globalBuffer.Lock()
+	defer globalBuffer.Unlock()
+
+	globalBuffer.handlerN++
+	var id = globalBuffer.handlerN
+	globalBuffer.handlers[id] = fn
+
+	return func() {
+		globalBuffer.Lock()
+		delete(globalBuffer.handlers, id)
+		globalBuffer.Unlock()
+	}
+}
+
+// Entries returns a copy of the entries in memory from the oldest.
+func Entries() []Entry {
+	globalBuffer.Lock()
+	defer globalBuffer.Unlock()
+
+	var entries = make([]Entry, 0, len(globalBuffer.entries))
+	entries = append(entries, globalBuffer.entries[globalBuffer.next:]...)
+	entries = append(entries, globalBuffer.entries[:globalBuffer.next]...)
+
+	return entries
 }
 
 func Error(err error) {
@@ -47,41 +155,61 @@ func Error(err error) {
 		return
 	}
 
-	Write("Error: " + err.Error())
+	Write(LevelError, err.Error())
 }
 
-// Warn calls Info().
 func Warn(err error) {
-	Info(err)
+	Write(LevelWarn, err.Error())
 }
 
 func Info(err error) {
-	Write("Info: " + err.Error())
+	Write(LevelInfo, err.Error())
+}
+
+// Debugf writes a formatted debug entry, which is only kept in memory and in
+// log files.
+func Debugf(f string, v ...interface{}) {
+	Write(LevelDebug, fmt.Sprintf(f, v...))
 }
 
-func Write(msg string) {
+func Write(level Level, msg string) {
 	WriteEntry(Entry{
-		Time: time.Now(),
-		Msg:  msg,
+		Time:  time.Now(),
+		Level: level,
+		Msg:   msg,
 	})
 }
 
+// WriteEntry writes the entry. It never blocks on handlers: if they're too far
+// behind, the entry is dropped for them and only kept in memory.
 func WriteEntry(entry Entry) {
-	go func() {
-		globalBuffer.Lock()
+	globalBuffer.Lock()
+
+	globalBuffer.seq++
+	entry.Seq = globalBuffer.seq
+
+	if len(globalBuffer.entries) < MaxEntries {
 		globalBuffer.entries = append(globalBuffer.entries, entry)
-		globalBuffer.Unlock()
+	} else {
+		globalBuffer.entries[globalBuffer.next] = entry
+		globalBuffer.next = (globalBuffer.next + 1) % MaxEntries
+	}
 
-		for _, fn := range globalBuffer.handlers {
-			fn(entry)
-		}
-	}()
+	// Queue while locked to keep the queue in the same order, which is fine
+	// since this doesn't block.
+	select {
+	case queue <- entry:
+	default:
+		atomic.AddUint64(&dropped, 1)
+	}
+
+	globalBuffer.Unlock()
 }
 
 func Println(v ...interface{}) {
-	log.Println(v...)
+	Write(LevelInfo, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 }
 
 func Printlnf(f string, v ...interface{}) {
-	log.Printf(f+"\n", v...)
+	Write(LevelInfo, fmt.Sprintf(f, v...))
 }
@@ -0,0 +1,127 @@
+package log
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetBuffer clears the entries in memory and the ones still queued for the
+// handlers, so that a full queue from one test doesn't drop the next test's
+// entries.
+func resetBuffer() {
+	globalBuffer.Lock()
+	defer globalBuffer.Unlock()
+
+	globalBuffer.entries = nil
+	globalBuffer.next = 0
+
+	for {
+		select {
+		case <-queue:
+			continue
+		default:
+		}
+		break
+	}
+
+	atomic.StoreUint64(&dropped, 0)
+}
+
+func TestEntries(t *testing.T) {
+	resetBuffer()
+	defer resetBuffer()
+
+	for i := 0; i < 3; i++ {
+		Write(LevelDebug, strconv.Itoa(i))
+	}
+
+	entries := Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	for i, entry := range entries {
+		if entry.Msg != strconv.Itoa(i) {
+			t.Fatalf("Entry %d has message %q", i, entry.Msg)
+		}
+	}
+}
+
+func TestEntriesRing(t *testing.T) {
+	resetBuffer()
+	defer resetBuffer()
+
+	const extra = 10
+
+	for i := 0; i < MaxEntries+extra; i++ {
+		Write(LevelDebug, strconv.Itoa(i))
+	}
+
+	entries := Entries()
+	if len(entries) != MaxEntries {
+		t.Fatalf("Expected %d entries, got %d", MaxEntries, len(entries))
+	}
+
+	// The oldest entries are dropped, and the rest are from the oldest.
+	for i, entry := range entries {
+		if entry.Msg != strconv.Itoa(i+extra) {
+			t.Fatalf("Entry %d has message %q", i, entry.Msg)
+		}
+		if i > 0 && entry.Seq != entries[i-1].Seq+1 {
+			t.Fatalf("Entry %d has sequence %d after %d", i, entry.Seq, entries[i-1].Seq)
+		}
+	}
+}
+
+func TestWriteNonBlocking(t *testing.T) {
+	resetBuffer()
+	defer resetBuffer()
+
+	var entered = make(chan struct{}, 1)
+	var release = make(chan struct{})
+	defer close(release)
+
+	remove := AddEntryHandler(func(entry Entry) {
+		if entry.Msg == "stuck" {
+			entered <- struct{}{}
+			<-release
+		}
+	})
+	defer remove()
+
+	// Get the handler stuck first.
+	Write(LevelDebug, "stuck")
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handler wasn't called")
+	}
+
+	var done = make(chan struct{})
+	var n = cap(queue) + 10
+
+	// Write more than the queue can hold while the handler is stuck.
+	go func() {
+		for i := 0; i < n; i++ {
+			Write(LevelDebug, strconv.Itoa(i))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked on a stuck handler")
+	}
+
+	if atomic.LoadUint64(&dropped) == 0 {
+		t.Fatal("Expected dropped entries")
+	}
+
+	if len(Entries()) != n+1 {
+		t.Fatal("Dropped entries aren't kept in memory")
+	}
+}
@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat/text"
+	"github.com/pkg/errors"
+)
+
+// CommandTimeout is the maximum duration to wait for a plugin to run a command.
+const CommandTimeout = 30 * time.Second
+
+// command is a command registered by a plugin.
+type command struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	conn *Conn
+}
+
+var commands = struct {
+	sync.Mutex
+	m map[string]command
+}{
+	m: map[string]command{},
+}
+
+func init() {
+	Handle("commands.register", func(c *Conn, params json.RawMessage) (interface{}, error) {
+		var cmd command
+		if err := DecodeParams(params, &cmd); err != nil {
+			return nil, err
+		}
+
+		if cmd.Name == "" || strings.ContainsAny(cmd.Name, " \t\n") {
+			return nil, &Error{CodeInvalidParams, "invalid command name"}
+		}
+
+		cmd.conn = c
+
+		commands.Lock()
+		defer commands.Unlock()
+
+		if old, ok := commands.m[cmd.Name]; ok && old.conn != c {
+			return nil, &Error{CodeInvalidParams, "command already registered: " + cmd.Name}
+		}
+
+		commands.m[cmd.Name] = cmd
+		return nil, nil
+	})
+
+	Handle("commands.unregister", func(c *Conn, params json.RawMessage) (interface{}, error) {
+		var cmd command
+		if err := DecodeParams(params, &cmd); err != nil {
+			return nil, err
+		}
+
+		commands.Lock()
+		defer commands.Unlock()
+
+		if old, ok := commands.m[cmd.Name]; ok && old.conn == c {
+			delete(commands.m, cmd.Name)
+		}
+
+		return nil, nil
+	})
+}
+
+// unregisterCommands removes all commands registered by the given connection.
+func unregisterCommands(c *Conn) {
+	commands.Lock()
+	defer commands.Unlock()
+
+	for name, cmd := range commands.m {
+		if cmd.conn == c {
+			delete(commands.m, name)
+		}
+	}
+}
+
+// sortedCommands returns all registered commands sorted by name.
+func sortedCommands() []command {
+	commands.Lock()
+	defer commands.Unlock()
+
+	var cmds = make([]command, 0, len(commands.m))
+	for _, cmd := range commands.m {
+		cmds = append(cmds, cmd)
+	}
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+
+	return cmds
+}
+
+// Commander runs commands registered by plugins. The "help" command lists all
+// commands.
+var Commander cchat.Commander = commander{}
+
+type commander struct{}
+
+var (
+	_ cchat.Commander = commander{}
+	_ cchat.Completer = commander{}
+)
+
+// Run calls the plugin that registered the command with the "commands.run"
+// method, which should return an object with an "output" string. An empty
+// command line gives the help text.
+func (commander) Run(words []string) ([]byte, error) {
+	if len(words) == 0 || words[0] == "help" {
+		var builder strings.Builder
+		for _, cmd := range sortedCommands() {
+			fmt.Fprintf(&builder, "%s\t%s\n", cmd.Name, cmd.Description)
+		}
+		if builder.Len() == 0 {
+			return []byte("No plugin commands."), nil
+		}
+		return []byte(builder.String()), nil
+	}
+
+	commands.Lock()
+	cmd, ok := commands.m[words[0]]
+	commands.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown command %q, try help", words[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+	defer cancel()
+
+	var result struct {
+		Output string `json:"output"`
+	}
+
+	params := map[string]interface{}{
+		"name": cmd.Name,
+		"args": words[1:],
+	}
+
+	if err := cmd.conn.Call(ctx, "commands.run", params, &result); err != nil {
+		return nil, errors.Wrap(err, "Failed to run command")
+	}
+
+	return []byte(result.Output), nil
+}
+
+func (c commander) AsCompleter() cchat.Completer { return c }
+
+// Complete completes the command names.
+func (commander) Complete(words []string, current int64) []cchat.CompletionEntry {
+	if current != 0 || len(words) == 0 {
+		return nil
+	}
+
+	var entries []cchat.CompletionEntry
+
+	for _, cmd := range sortedCommands() {
+		if strings.HasPrefix(cmd.Name, words[0]) {
+			entries = append(entries, cchat.CompletionEntry{
+				Raw:       cmd.Name,
+				Text:      text.Plain(cmd.Name),
+				Secondary: text.Plain(cmd.Description),
+			})
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,280 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/pkg/errors"
+)
+
+// Handler handles a request from a plugin. The returned value is marshaled as
+// the result. Handlers are called in their own goroutines.
+type Handler func(c *Conn, params json.RawMessage) (interface{}, error)
+
+var handlers = struct {
+	sync.RWMutex
+	m map[string]Handler
+}{
+	m: map[string]Handler{},
+}
+
+// Handle registers the handler for the given method. Existing handlers with the
+// same method are replaced.
+func Handle(method string, h Handler) {
+	handlers.Lock()
+	handlers.m[method] = h
+	handlers.Unlock()
+}
+
+func handler(method string) Handler {
+	handlers.RLock()
+	defer handlers.RUnlock()
+
+	return handlers.m[method]
+}
+
+const (
+	// QueueSize is the number of outgoing messages queued for each plugin.
+	// Plugins that fall this far behind are disconnected, so that a stuck
+	// plugin can't block the callers.
+	QueueSize = 256
+	// WriteTimeout is the maximum time to write a single message.
+	WriteTimeout = 10 * time.Second
+)
+
+var (
+	errQueueFull = errors.New("plugin is not reading its messages")
+	errClosed    = errors.New("connection closed")
+)
+
+// Conn is a single connection from a plugin.
+type Conn struct {
+	conn net.Conn
+
+	// queue is drained by the writer goroutine until done is closed.
+	queue     chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	nextID  uint64
+}
+
+func newConn(conn net.Conn) *Conn {
+	c := &Conn{
+		conn:    conn,
+		queue:   make(chan []byte, QueueSize),
+		done:    make(chan struct{}),
+		pending: map[string]chan *message{},
+	}
+
+	go c.write()
+
+	return c
+}
+
+// write writes the queued messages until the connection is closed, then
+// flushes the rest and closes the socket.
+func (c *Conn) write() {
+	defer c.conn.Close()
+
+	for {
+		select {
+		case b := <-c.queue:
+			if !c.writeMessage(b) {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			for {
+				select {
+				case b := <-c.queue:
+					if !c.writeMessage(b) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *Conn) writeMessage(b []byte) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+
+	if _, err := c.conn.Write(b); err != nil {
+		log.Error(errors.Wrap(err, "Failed to write to plugin"))
+		return false
+	}
+
+	return true
+}
+
+// serve reads messages until the connection is closed.
+func (c *Conn) serve() {
+	defer c.Close()
+
+	var dec = json.NewDecoder(c.conn)
+
+	for {
+		var msg message
+
+		if err := dec.Decode(&msg); err != nil {
+			// The stream can't be recovered from invalid JSON, so the plugin is
+			// told and disconnected. Other errors mean that the connection is
+			// closed.
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				c.send(&message{Error: &Error{CodeParseError, err.Error()}})
+				log.Error(errors.Wrap(err, "Failed to decode plugin message"))
+			}
+			return
+		}
+
+		if msg.Method == "" {
+			c.deliver(&msg)
+			continue
+		}
+
+		go c.handle(&msg)
+	}
+}
+
+func (c *Conn) handle(msg *message) {
+	var result interface{}
+	var err error
+
+	if h := handler(msg.Method); h != nil {
+		result, err = h(c, msg.Params)
+	} else {
+		err = &Error{CodeMethodNotFound, "method not found: " + msg.Method}
+	}
+
+	// Notifications don't get a response, so errors are only logged.
+	if msg.ID == nil {
+		log.Error(errors.Wrap(err, "Plugin notification "+msg.Method+" failed"))
+		return
+	}
+
+	reply := message{ID: msg.ID}
+
+	if err == nil {
+		reply.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		reply.Result = nil
+		reply.Error = toError(err)
+	}
+
+	c.send(&reply)
+}
+
+// deliver delivers the response to the pending call.
+func (c *Conn) deliver(msg *message) {
+	if msg.ID == nil {
+		return
+	}
+
+	c.mu.Lock()
+	ch, ok := c.pending[string(*msg.ID)]
+	delete(c.pending, string(*msg.ID))
+	c.mu.Unlock()
+
+	if ok {
+		ch <- msg
+	}
+}
+
+// send queues the message without blocking. The plugin is disconnected if its
+// queue is full.
+func (c *Conn) send(msg *message) error {
+	msg.JSONRPC = "2.0"
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal message")
+	}
+	b = append(b, '\n')
+
+	select {
+	case <-c.done:
+		return errClosed
+	default:
+	}
+
+	select {
+	case c.queue <- b:
+		return nil
+	default:
+		c.Close()
+		return errQueueFull
+	}
+}
+
+// Notify sends a notification to the plugin.
+func (c *Conn) Notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal params")
+	}
+
+	return c.send(&message{Method: method, Params: b})
+}
+
+// Call sends a request to the plugin and waits for its response, which is
+// unmarshaled into result if it's not nil.
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal params")
+	}
+
+	var ch = make(chan *message, 1)
+
+	c.mu.Lock()
+	c.nextID++
+	id := json.RawMessage(strconv.FormatUint(c.nextID, 10))
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(&message{ID: &id, Method: method, Params: b}); err != nil {
+		return errors.Wrap(err, "Failed to send request")
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil && len(msg.Result) > 0 {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the connection and unregisters the plugin's commands. Messages
+// that are already queued are still written. It can be called more than once.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		removeConn(c)
+		unregisterCommands(c)
+		close(c.done)
+	})
+	return nil
+}
@@ -0,0 +1,263 @@
+// Package plugin provides the opt-in plugin bridge. Plugins are executables in
+// the plugins directory inside the config directory, which are started as child
+// processes when the bridge is enabled. They talk to the client over a Unix
+// socket using newline-delimited JSON-RPC 2.0; the socket path is given in the
+// CCHAT_PLUGIN_SOCKET environment variable.
+//
+// The client publishes events as notifications to every connected plugin, and
+// plugins call methods registered with Handle.
+package plugin
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/pkg/errors"
+)
+
+// SocketEnv is the environment variable that contains the socket path for
+// plugins.
+const SocketEnv = "CCHAT_PLUGIN_SOCKET"
+
+// Enabled is true if the plugin bridge is enabled. It is changed by the
+// preferences.
+var Enabled bool
+
+func init() {
+	config.PluginsAdd("Enable Plugins", config.Switch(&Enabled, func(enabled bool) {
+		if enabled {
+			log.Error(errors.Wrap(Start(), "Failed to start the plugin bridge"))
+		} else {
+			Stop()
+		}
+	}))
+}
+
+var bridge struct {
+	sync.Mutex
+	listener net.Listener
+	path     string
+	conns    map[*Conn]struct{}
+	procs    []*exec.Cmd
+}
+
+// Dir returns the directory that plugin executables are loaded from.
+func Dir() string {
+	return filepath.Join(config.DirPath(), "plugins")
+}
+
+// socketPath returns the path to the socket. The runtime directory is preferred
+// over the config directory, and the PID is used to allow multiple instances.
+func socketPath() string {
+	var name = "plugins-" + strconv.Itoa(os.Getpid()) + ".sock"
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "cchat-gtk-"+name)
+	}
+
+	return filepath.Join(config.DirPath(), name)
+}
+
+// Start starts listening on the socket, then starts all plugins. It does
+// nothing if the bridge is already started.
+func Start() error {
+	bridge.Lock()
+	defer bridge.Unlock()
+
+	if bridge.listener != nil {
+		return nil
+	}
+
+	path := socketPath()
+
+	// Remove the stale socket of a crashed instance, if any.
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return errors.Wrap(err, "Failed to listen")
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return errors.Wrap(err, "Failed to chmod the socket")
+	}
+
+	bridge.listener = l
+	bridge.path = path
+	bridge.conns = map[*Conn]struct{}{}
+
+	go accept(l)
+
+	log.Printlnf("Plugin bridge listening at %s", path)
+
+	bridge.procs = startPlugins(path)
+	return nil
+}
+
+// Stop stops all plugins and closes the socket. It does nothing if the bridge
+// is not started.
+func Stop() {
+	bridge.Lock()
+
+	if bridge.listener == nil {
+		bridge.Unlock()
+		return
+	}
+
+	bridge.listener.Close()
+	bridge.listener = nil
+	os.Remove(bridge.path)
+
+	var conns = bridge.conns
+	bridge.conns = nil
+
+	for _, cmd := range bridge.procs {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+	bridge.procs = nil
+
+	bridge.Unlock()
+
+	// Close outside the lock, since Close removes the connection.
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+func accept(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		conn := newConn(c)
+
+		bridge.Lock()
+		// Drop the connection if the bridge has been stopped in the meantime.
+		if bridge.conns == nil {
+			bridge.Unlock()
+			c.Close()
+			return
+		}
+		bridge.conns[conn] = struct{}{}
+		bridge.Unlock()
+
+		go conn.serve()
+	}
+}
+
+func removeConn(c *Conn) {
+	bridge.Lock()
+	delete(bridge.conns, c)
+	bridge.Unlock()
+}
+
+// Publish queues the event as a notification to all connected plugins without
+// blocking. It does nothing if the bridge is not started, and it is
+// thread-safe.
+func Publish(event string, params interface{}) {
+	bridge.Lock()
+	var conns = make([]*Conn, 0, len(bridge.conns))
+	for conn := range bridge.conns {
+		conns = append(conns, conn)
+	}
+	bridge.Unlock()
+
+	for _, conn := range conns {
+		if err := conn.Notify(event, params); err != nil {
+			log.Error(errors.Wrap(err, "Failed to publish "+event))
+		}
+	}
+}
+
+// Connected returns true if there's at least one plugin connected. Callers can
+// use this to skip building events.
+func Connected() bool {
+	bridge.Lock()
+	defer bridge.Unlock()
+
+	return len(bridge.conns) > 0
+}
+
+// startPlugins starts all executables in the plugins directory.
+func startPlugins(socket string) []*exec.Cmd {
+	dir := Dir()
+
+	// Make the directory, so users know where to put plugins.
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Error(errors.Wrap(err, "Failed to make the plugins directory"))
+		return nil
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		log.Error(errors.Wrap(err, "Failed to open the plugins directory"))
+		return nil
+	}
+	defer f.Close()
+
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		log.Error(errors.Wrap(err, "Failed to read the plugins directory"))
+		return nil
+	}
+
+	var procs []*exec.Cmd
+
+	for _, info := range infos {
+		// Only start executable files.
+		if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+
+		name := info.Name()
+
+		cmd := exec.Command(filepath.Join(dir, name))
+		cmd.Env = append(os.Environ(), SocketEnv+"="+socket)
+
+		r, w := io.Pipe()
+		cmd.Stdout = w
+		cmd.Stderr = w
+
+		if err := cmd.Start(); err != nil {
+			log.Error(errors.Wrapf(err, "Failed to start plugin %s", name))
+			continue
+		}
+
+		log.Printlnf("Started plugin %s", name)
+
+		go logOutput(name, r)
+
+		go func() {
+			// Plugins are stopped with SIGTERM, so exit errors are expected and
+			// not logged as errors.
+			err := cmd.Wait()
+			w.Close()
+			log.Printlnf("Plugin %s exited: %v", name, err)
+		}()
+
+		procs = append(procs, cmd)
+	}
+
+	return procs
+}
+
+// logOutput writes each line from the plugin's output into the log, prefixed
+// with the plugin name. It returns when the plugin exits.
+func logOutput(name string, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		log.Printlnf("[plugin %s] %s", name, scanner.Text())
+	}
+}
@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// message is a single JSON-RPC 2.0 message. Both the client and the plugins can
+// send requests, so the same type is used for requests, notifications and
+// responses. Requests without an ID are notifications, and messages without a
+// method are responses.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *Error           `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object. Handlers can return it to control the error
+// code; other errors are sent as internal errors.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("plugin error %d: %s", err.Code, err.Message)
+}
+
+// DecodeParams unmarshals the request parameters into v. It returns an invalid
+// params error if that fails.
+func DecodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return &Error{CodeInvalidParams, "missing params"}
+	}
+
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{CodeInvalidParams, err.Error()}
+	}
+
+	return nil
+}
+
+// toError converts the given error into an error object.
+func toError(err error) *Error {
+	if rpcErr, ok := err.(*Error); ok {
+		return rpcErr
+	}
+	return &Error{CodeInternalError, err.Error()}
+}
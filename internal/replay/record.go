@@ -0,0 +1,730 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat/text"
+	"github.com/pkg/errors"
+)
+
+// Recorder records what the services it wraps do. It writes a trace if it's
+// given a file, and it calls its handlers with every entry. Nothing is
+// recorded if it has neither. It is safe to use concurrently.
+type Recorder struct {
+	mutex    sync.Mutex
+	file     *os.File
+	enc      *json.Encoder
+	start    time.Time
+	calls    int
+	handlers map[int]Handler
+	handlerN int
+}
+
+// Handler is called with every entry along with the entry that started its
+// call. Both are the same for call entries. Handlers are called in the
+// goroutine that the backend calls the container in.
+type Handler func(call, entry Entry)
+
+// Default is the recorder that the application wraps all services with.
+var Default = NewRecorder()
+
+// NewRecorder creates a new recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		start:    time.Now(),
+		handlers: map[int]Handler{},
+	}
+}
+
+// Open starts writing a trace into the file at the given path. The file is
+// truncated if it exists.
+func (r *Recorder) Open(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create trace")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file != nil {
+		r.file.Close()
+	}
+
+	r.file = f
+	r.enc = json.NewEncoder(f)
+	return nil
+}
+
+// Close closes the trace file. Calls after this are not written.
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// AddHandler adds a handler. The returned callback removes it.
+func (r *Recorder) AddHandler(fn Handler) (remove func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handlerN++
+	var id = r.handlerN
+	r.handlers[id] = fn
+
+	return func() {
+		r.mutex.Lock()
+		delete(r.handlers, id)
+		r.mutex.Unlock()
+	}
+}
+
+// Wrap wraps the service so that it's recorded.
+func (r *Recorder) Wrap(svc cchat.Service) cchat.Service {
+	return recordService{svc, r}
+}
+
+// active returns true if the recorder has anything to record into. Callers
+// check this to skip taking snapshots.
+func (r *Recorder) active() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.file != nil || len(r.handlers) > 0
+}
+
+func (r *Recorder) write(call, entry Entry) {
+	r.mutex.Lock()
+
+	entry.Time = time.Since(r.start)
+
+	if r.file != nil {
+		if err := r.enc.Encode(entry); err != nil {
+			log.Error(errors.Wrap(err, "Failed to write trace"))
+		}
+	}
+
+	var handlers = make([]Handler, 0, len(r.handlers))
+	for _, fn := range r.handlers {
+		handlers = append(handlers, fn)
+	}
+
+	r.mutex.Unlock()
+
+	for _, fn := range handlers {
+		if entry.Kind != "" {
+			fn(entry, entry)
+		} else {
+			fn(call, entry)
+		}
+	}
+}
+
+// begin records the start of a call. The given entry has its call ID and kind
+// overridden.
+func (r *Recorder) begin(kind string, entry Entry) *recordCall {
+	r.mutex.Lock()
+	r.calls++
+	entry.Call = r.calls
+	r.mutex.Unlock()
+
+	entry.Kind = kind
+	r.write(entry, entry)
+
+	return &recordCall{r, entry}
+}
+
+// recordCall records the events of a single call.
+type recordCall struct {
+	rec   *Recorder
+	start Entry
+}
+
+func (c *recordCall) event(event string, entry Entry) {
+	entry.Call = c.start.Call
+	entry.Event = event
+	c.rec.write(c.start, entry)
+}
+
+// end records the end of the call and returns the error as-is.
+func (c *recordCall) end(err error) error {
+	var entry Entry
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	c.event(EventReturn, entry)
+	return err
+}
+
+// appendPath returns a copy of the path with the ID appended, so that paths
+// of sibling servers don't share the same backing array.
+func appendPath(path []cchat.ID, id cchat.ID) []cchat.ID {
+	var newPath = make([]cchat.ID, len(path), len(path)+1)
+	copy(newPath, path)
+	return append(newPath, id)
+}
+
+type recordService struct {
+	cchat.Service
+	rec *Recorder
+}
+
+func (s recordService) path() []cchat.ID {
+	return []cchat.ID{s.Name().Content}
+}
+
+func (s recordService) Authenticate() []cchat.Authenticator {
+	return wrapAuthenticators(s.Service.Authenticate(), s)
+}
+
+func (s recordService) AsSessionRestorer() cchat.SessionRestorer {
+	if restorer := s.Service.AsSessionRestorer(); restorer != nil {
+		return recordRestorer{restorer, s}
+	}
+	return nil
+}
+
+// wrapSession records the new session and wraps it.
+func (s recordService) wrapSession(ses cchat.Session, err error) (cchat.Session, error) {
+	var entry = Entry{Path: s.path()}
+	if err == nil {
+		entry.Path = appendPath(entry.Path, ses.ID())
+		entry.Name = ses.Name().Content
+	}
+
+	s.rec.begin(KindSession, entry).end(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recordSession{ses, s.rec, entry.Path}, nil
+}
+
+func wrapAuthenticators(auths []cchat.Authenticator, svc recordService) []cchat.Authenticator {
+	var wrapped = make([]cchat.Authenticator, len(auths))
+	for i, auth := range auths {
+		wrapped[i] = recordAuthenticator{auth, svc}
+	}
+	return wrapped
+}
+
+type recordAuthenticator struct {
+	cchat.Authenticator
+	svc recordService
+}
+
+func (a recordAuthenticator) Authenticate(values []string) (cchat.Session, cchat.AuthenticateError) {
+	ses, authErr := a.Authenticator.Authenticate(values)
+	if authErr != nil {
+		// Record the error without the stages, which need user input.
+		a.svc.wrapSession(nil, authErr)
+		return nil, recordAuthError{authErr, a.svc}
+	}
+
+	ses, _ = a.svc.wrapSession(ses, nil)
+	return ses, nil
+}
+
+type recordAuthError struct {
+	cchat.AuthenticateError
+	svc recordService
+}
+
+func (err recordAuthError) NextStage() []cchat.Authenticator {
+	return wrapAuthenticators(err.AuthenticateError.NextStage(), err.svc)
+}
+
+type recordRestorer struct {
+	cchat.SessionRestorer
+	svc recordService
+}
+
+func (r recordRestorer) RestoreSession(data map[string]string) (cchat.Session, error) {
+	return r.svc.wrapSession(r.SessionRestorer.RestoreSession(data))
+}
+
+type recordSession struct {
+	cchat.Session
+	rec  *Recorder
+	path []cchat.ID
+}
+
+func (s recordSession) Servers(container cchat.ServersContainer) error {
+	return recordServers(s.Session, container, s.rec, s.path)
+}
+
+func (s recordSession) Disconnect() error {
+	return s.rec.begin(KindDisconnect, Entry{Path: s.path}).end(s.Session.Disconnect())
+}
+
+// recordServers records the call to Servers on the given lister.
+func recordServers(
+	lister cchat.Lister, container cchat.ServersContainer, rec *Recorder, path []cchat.ID) error {
+
+	var call = rec.begin(KindServers, Entry{Path: path})
+	var err = lister.Servers(recordServersContainer{container, call, path})
+	return call.end(err)
+}
+
+type recordServersContainer struct {
+	cchat.ServersContainer
+	call *recordCall
+	path []cchat.ID
+}
+
+func (c recordServersContainer) wrap(server cchat.Server) recordServer {
+	return recordServer{server, c.call.rec, appendPath(c.path, server.ID())}
+}
+
+func (c recordServersContainer) SetServers(servers []cchat.Server) {
+	if c.call.rec.active() {
+		var recorded = make([]Server, len(servers))
+		for i, server := range servers {
+			recorded[i] = snapshotServer(server)
+		}
+
+		c.call.event(EventSetServers, Entry{Servers: recorded})
+	}
+
+	var wrapped = make([]cchat.Server, len(servers))
+	for i, server := range servers {
+		wrapped[i] = c.wrap(server)
+	}
+
+	c.ServersContainer.SetServers(wrapped)
+}
+
+func (c recordServersContainer) UpdateServer(update cchat.ServerUpdate) {
+	if c.call.rec.active() {
+		prevID, replace := update.PreviousID()
+
+		c.call.event(EventUpdateServer, Entry{
+			ID:      prevID,
+			Replace: replace,
+			Servers: []Server{snapshotServer(update)},
+		})
+	}
+
+	c.ServersContainer.UpdateServer(recordServerUpdate{c.wrap(update), update})
+}
+
+func snapshotServer(server cchat.Server) Server {
+	var recorded = Server{
+		ID:     server.ID(),
+		Name:   server.Name().Content,
+		Lister: server.AsLister() != nil,
+	}
+
+	if messenger := server.AsMessenger(); messenger != nil {
+		recorded.Messenger = true
+		recorded.Backlogger = messenger.AsBacklogger() != nil
+		recorded.MemberLister = messenger.AsMemberLister() != nil
+		recorded.UnreadIndicator = messenger.AsUnreadIndicator() != nil
+
+		if typing := messenger.AsTypingIndicator(); typing != nil {
+			recorded.TypingIndicator = true
+			recorded.TypingTimeout = typing.TypingTimeout()
+		}
+	}
+
+	return recorded
+}
+
+type recordServer struct {
+	cchat.Server
+	rec  *Recorder
+	path []cchat.ID
+}
+
+func (s recordServer) AsLister() cchat.Lister {
+	if lister := s.Server.AsLister(); lister != nil {
+		return recordLister{lister, s}
+	}
+	return nil
+}
+
+func (s recordServer) AsMessenger() cchat.Messenger {
+	if messenger := s.Server.AsMessenger(); messenger != nil {
+		return recordMessenger{messenger, s}
+	}
+	return nil
+}
+
+type recordServerUpdate struct {
+	recordServer
+	update cchat.ServerUpdate
+}
+
+func (u recordServerUpdate) PreviousID() (cchat.ID, bool) {
+	return u.update.PreviousID()
+}
+
+type recordLister struct {
+	cchat.Lister
+	server recordServer
+}
+
+func (l recordLister) Servers(container cchat.ServersContainer) error {
+	return recordServers(l.Lister, container, l.server.rec, l.server.path)
+}
+
+type recordMessenger struct {
+	cchat.Messenger
+	server recordServer
+}
+
+func (m recordMessenger) begin(kind string, entry Entry) *recordCall {
+	entry.Path = m.server.path
+	return m.server.rec.begin(kind, entry)
+}
+
+func (m recordMessenger) JoinServer(
+	ctx context.Context, container cchat.MessagesContainer) (func(), error) {
+
+	var call = m.begin(KindJoin, Entry{})
+	stop, err := m.Messenger.JoinServer(ctx, recordMessagesContainer{container, call})
+	return stop, call.end(err)
+}
+
+func (m recordMessenger) AsSender() cchat.Sender {
+	if sender := m.Messenger.AsSender(); sender != nil {
+		return recordSender{sender, m}
+	}
+	return nil
+}
+
+func (m recordMessenger) AsEditor() cchat.Editor {
+	if editor := m.Messenger.AsEditor(); editor != nil {
+		return recordEditor{editor, m}
+	}
+	return nil
+}
+
+func (m recordMessenger) AsActioner() cchat.Actioner {
+	if actioner := m.Messenger.AsActioner(); actioner != nil {
+		return recordActioner{actioner, m}
+	}
+	return nil
+}
+
+func (m recordMessenger) AsBacklogger() cchat.Backlogger {
+	if backlogger := m.Messenger.AsBacklogger(); backlogger != nil {
+		return recordBacklogger{backlogger, m}
+	}
+	return nil
+}
+
+func (m recordMessenger) AsMemberLister() cchat.MemberLister {
+	if lister := m.Messenger.AsMemberLister(); lister != nil {
+		return recordMemberLister{lister, m}
+	}
+	return nil
+}
+
+func (m recordMessenger) AsUnreadIndicator() cchat.UnreadIndicator {
+	if indicator := m.Messenger.AsUnreadIndicator(); indicator != nil {
+		return recordUnreadIndicator{indicator, m}
+	}
+	return nil
+}
+
+func (m recordMessenger) AsTypingIndicator() cchat.TypingIndicator {
+	if indicator := m.Messenger.AsTypingIndicator(); indicator != nil {
+		return recordTypingIndicator{indicator, m}
+	}
+	return nil
+}
+
+type recordSender struct {
+	cchat.Sender
+	messenger recordMessenger
+}
+
+func (s recordSender) Send(msg cchat.SendableMessage) error {
+	var call = s.messenger.begin(KindSend, Entry{Content: msg.Content()})
+	return call.end(s.Sender.Send(msg))
+}
+
+type recordEditor struct {
+	cchat.Editor
+	messenger recordMessenger
+}
+
+func (e recordEditor) Edit(id cchat.ID, content string) error {
+	var call = e.messenger.begin(KindEdit, Entry{ID: id, Content: content})
+	return call.end(e.Editor.Edit(id, content))
+}
+
+type recordActioner struct {
+	cchat.Actioner
+	messenger recordMessenger
+}
+
+func (a recordActioner) Do(action string, id cchat.ID) error {
+	var call = a.messenger.begin(KindAction, Entry{ID: id, Content: action})
+	return call.end(a.Actioner.Do(action, id))
+}
+
+type recordBacklogger struct {
+	cchat.Backlogger
+	messenger recordMessenger
+}
+
+func (b recordBacklogger) Backlog(
+	ctx context.Context, before cchat.ID, container cchat.MessagesContainer) error {
+
+	var call = b.messenger.begin(KindBacklog, Entry{ID: before})
+	var err = b.Backlogger.Backlog(ctx, before, recordMessagesContainer{container, call})
+	return call.end(err)
+}
+
+type recordMessagesContainer struct {
+	cchat.MessagesContainer
+	call *recordCall
+}
+
+func snapshotAuthor(author cchat.Author) *Author {
+	if author == nil {
+		return nil
+	}
+
+	return &Author{
+		ID:     author.ID(),
+		Name:   author.Name().Content,
+		Avatar: author.Avatar(),
+	}
+}
+
+// snapshotSegments records the formats of each segment.
+func snapshotSegments(segments []text.Segment) []Segment {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	var recorded = make([]Segment, len(segments))
+
+	for i, segment := range segments {
+		var r = &recorded[i]
+		r.Start, r.End = segment.Bounds()
+
+		if colorer := segment.AsColorer(); colorer != nil {
+			color := colorer.Color()
+			r.Color = &color
+		}
+		if linker := segment.AsLinker(); linker != nil {
+			r.Link = linker.Link()
+		}
+		if imager := segment.AsImager(); imager != nil {
+			r.Image = &Image{URL: imager.Image(), Text: imager.ImageText()}
+			r.Image.Width, r.Image.Height = imager.ImageSize()
+		}
+		if avatarer := segment.AsAvatarer(); avatarer != nil {
+			r.Avatar = &Avatar{
+				URL:  avatarer.Avatar(),
+				Text: avatarer.AvatarText(),
+				Size: avatarer.AvatarSize(),
+			}
+		}
+		if mentioner := segment.AsMentioner(); mentioner != nil {
+			info := mentioner.MentionInfo()
+			r.Mention = &Rich{info.Content, snapshotSegments(info.Segments)}
+		}
+		if attributor := segment.AsAttributor(); attributor != nil {
+			r.Attribute = attributor.Attribute()
+		}
+		if codeblocker := segment.AsCodeblocker(); codeblocker != nil {
+			language := codeblocker.CodeblockLanguage()
+			r.Codeblock = &language
+		}
+		if quoteblocker := segment.AsQuoteblocker(); quoteblocker != nil {
+			prefix := quoteblocker.QuotePrefix()
+			r.Quote = &prefix
+		}
+		if referencer := segment.AsMessageReferencer(); referencer != nil {
+			r.MessageID = referencer.MessageID()
+		}
+	}
+
+	return recorded
+}
+
+func (c recordMessagesContainer) CreateMessage(msg cchat.MessageCreate) {
+	if c.call.rec.active() {
+		content := msg.Content()
+
+		c.call.event(EventCreateMessage, Entry{Message: &Message{
+			ID:        msg.ID(),
+			Time:      msg.Time(),
+			Nonce:     msg.Nonce(),
+			Author:    snapshotAuthor(msg.Author()),
+			Content:   content.Content,
+			Segments:  snapshotSegments(content.Segments),
+			Mentioned: msg.Mentioned(),
+		}})
+	}
+
+	c.MessagesContainer.CreateMessage(msg)
+}
+
+func (c recordMessagesContainer) UpdateMessage(msg cchat.MessageUpdate) {
+	if c.call.rec.active() {
+		content := msg.Content()
+
+		c.call.event(EventUpdateMessage, Entry{Message: &Message{
+			ID:       msg.ID(),
+			Time:     msg.Time(),
+			Author:   snapshotAuthor(msg.Author()),
+			Content:  content.Content,
+			Segments: snapshotSegments(content.Segments),
+		}})
+	}
+
+	c.MessagesContainer.UpdateMessage(msg)
+}
+
+func (c recordMessagesContainer) DeleteMessage(msg cchat.MessageDelete) {
+	if c.call.rec.active() {
+		c.call.event(EventDeleteMessage, Entry{Message: &Message{
+			ID:   msg.ID(),
+			Time: msg.Time(),
+		}})
+	}
+
+	c.MessagesContainer.DeleteMessage(msg)
+}
+
+type recordMemberLister struct {
+	cchat.MemberLister
+	messenger recordMessenger
+}
+
+func (l recordMemberLister) ListMembers(
+	ctx context.Context, container cchat.MemberListContainer) (func(), error) {
+
+	var call = l.messenger.begin(KindMembers, Entry{})
+	stop, err := l.MemberLister.ListMembers(ctx, recordMemberListContainer{container, call})
+	return stop, call.end(err)
+}
+
+type recordMemberListContainer struct {
+	cchat.MemberListContainer
+	call *recordCall
+}
+
+func (c recordMemberListContainer) SetSections(sections []cchat.MemberSection) {
+	if c.call.rec.active() {
+		var recorded = make([]Section, len(sections))
+		for i, section := range sections {
+			recorded[i] = Section{
+				ID:    section.ID(),
+				Name:  section.Name().Content,
+				Total: section.Total(),
+			}
+		}
+
+		c.call.event(EventSetSections, Entry{Sections: recorded})
+	}
+
+	c.MemberListContainer.SetSections(sections)
+}
+
+func (c recordMemberListContainer) SetMember(sectionID cchat.ID, member cchat.ListMember) {
+	if c.call.rec.active() {
+		c.call.event(EventSetMember, Entry{
+			ID: sectionID,
+			Member: &Member{
+				ID:        member.ID(),
+				Name:      member.Name().Content,
+				Secondary: member.Secondary().Content,
+				Status:    member.Status(),
+			},
+		})
+	}
+
+	c.MemberListContainer.SetMember(sectionID, member)
+}
+
+func (c recordMemberListContainer) RemoveMember(sectionID, memberID cchat.ID) {
+	if c.call.rec.active() {
+		c.call.event(EventRemoveMember, Entry{
+			ID:     sectionID,
+			Member: &Member{ID: memberID},
+		})
+	}
+
+	c.MemberListContainer.RemoveMember(sectionID, memberID)
+}
+
+type recordUnreadIndicator struct {
+	cchat.UnreadIndicator
+	messenger recordMessenger
+}
+
+func (i recordUnreadIndicator) UnreadIndicate(container cchat.UnreadContainer) (func(), error) {
+	var call = i.messenger.begin(KindUnread, Entry{})
+	stop, err := i.UnreadIndicator.UnreadIndicate(recordUnreadContainer{container, call})
+	return stop, call.end(err)
+}
+
+type recordUnreadContainer struct {
+	cchat.UnreadContainer
+	call *recordCall
+}
+
+func (c recordUnreadContainer) SetUnread(unread, mentioned bool) {
+	if c.call.rec.active() {
+		c.call.event(EventSetUnread, Entry{Unread: unread, Mentioned: mentioned})
+	}
+
+	c.UnreadContainer.SetUnread(unread, mentioned)
+}
+
+type recordTypingIndicator struct {
+	cchat.TypingIndicator
+	messenger recordMessenger
+}
+
+func (i recordTypingIndicator) TypingSubscribe(container cchat.TypingContainer) (func(), error) {
+	var call = i.messenger.begin(KindTyping, Entry{})
+	stop, err := i.TypingIndicator.TypingSubscribe(recordTypingContainer{container, call})
+	return stop, call.end(err)
+}
+
+type recordTypingContainer struct {
+	cchat.TypingContainer
+	call *recordCall
+}
+
+func (c recordTypingContainer) AddTyper(typer cchat.Typer) {
+	if c.call.rec.active() {
+		var author = snapshotAuthor(typer)
+		author.Time = typer.Time()
+
+		c.call.event(EventAddTyper, Entry{Typer: author})
+	}
+
+	c.TypingContainer.AddTyper(typer)
+}
+
+func (c recordTypingContainer) RemoveTyper(typerID cchat.ID) {
+	if c.call.rec.active() {
+		c.call.event(EventRemoveTyper, Entry{ID: typerID})
+	}
+
+	c.TypingContainer.RemoveTyper(typerID)
+}
@@ -0,0 +1,723 @@
+package replay
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat/services"
+	"github.com/diamondburned/cchat/text"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	services.RegisterService(Service{})
+}
+
+// Service is the service that plays back traces. Each session plays back a
+// recorded session. Calls made by the frontend play back the matching
+// recorded calls, so the same actions show the same things regardless of
+// when they're done.
+type Service struct{}
+
+var (
+	_ cchat.Service         = Service{}
+	_ cchat.SessionRestorer = Service{}
+)
+
+func (Service) Name() text.Rich {
+	return text.Rich{Content: "Replay"}
+}
+
+func (Service) AsIconer() cchat.Iconer             { return nil }
+func (Service) AsConfigurator() cchat.Configurator { return nil }
+
+func (svc Service) AsSessionRestorer() cchat.SessionRestorer { return svc }
+
+func (Service) Authenticate() []cchat.Authenticator {
+	return []cchat.Authenticator{authenticator{}}
+}
+
+// RestoreSession restores the session saved by Session.SaveSession.
+func (Service) RestoreSession(data map[string]string) (cchat.Session, error) {
+	return NewSession(data["trace"], data["session"], data["speed"])
+}
+
+type authenticator struct{}
+
+func (authenticator) Name() text.Rich {
+	return text.Rich{Content: "Trace"}
+}
+
+func (authenticator) Description() text.Rich {
+	return text.Rich{Content: "Play back a recorded trace."}
+}
+
+func (authenticator) AuthenticateForm() []cchat.AuthenticateEntry {
+	return []cchat.AuthenticateEntry{{
+		Name:        "Trace",
+		Placeholder: "/path/to/trace.jsonl",
+		Description: "The trace file written with CCHAT_RECORD.",
+	}, {
+		Name:        "Session",
+		Description: "The ID of the recorded session. The first one is used if empty.",
+	}, {
+		Name:        "Speed",
+		Placeholder: "1",
+		Description: "How many times faster to play back. 0 plays back instantly.",
+	}}
+}
+
+func (authenticator) Authenticate(values []string) (cchat.Session, cchat.AuthenticateError) {
+	ses, err := NewSession(values[0], values[1], values[2])
+	if err != nil {
+		return nil, authError{err}
+	}
+	return ses, nil
+}
+
+type authError struct{ error }
+
+func (authError) NextStage() []cchat.Authenticator { return nil }
+
+// Session is a recorded session being played back.
+type Session struct {
+	player *player
+	path   []cchat.ID
+	name   string
+	data   map[string]string
+}
+
+var (
+	_ cchat.Session      = (*Session)(nil)
+	_ cchat.SessionSaver = (*Session)(nil)
+)
+
+// NewSession loads the trace at the given path and plays back the session with
+// the given ID, or the first session if the ID is empty. The speed is a
+// multiplier, where 0 means no delays; it defaults to 1 if empty.
+func NewSession(tracePath, sessionID, speed string) (*Session, error) {
+	var multiplier = 1.0
+
+	if speed != "" {
+		s, err := strconv.ParseFloat(speed, 64)
+		if err != nil || s < 0 {
+			return nil, errors.Errorf("Invalid speed %q", speed)
+		}
+		multiplier = s
+	}
+
+	entries, err := ReadTrace(tracePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var player = newPlayer(entries, multiplier)
+
+	for _, call := range player.sessions {
+		if call.err() != nil || len(call.start.Path) != 2 {
+			continue
+		}
+		if sessionID != "" && call.start.Path[1] != sessionID {
+			continue
+		}
+
+		return &Session{
+			player: player,
+			path:   call.start.Path,
+			name:   call.start.Name,
+			data: map[string]string{
+				"trace":   tracePath,
+				"session": call.start.Path[1],
+				"speed":   speed,
+			},
+		}, nil
+	}
+
+	if sessionID != "" {
+		return nil, errors.Errorf("Session %q not found in trace", sessionID)
+	}
+
+	return nil, errors.New("No sessions found in trace")
+}
+
+func (s *Session) ID() cchat.ID { return s.path[1] }
+
+func (s *Session) Name() text.Rich {
+	return text.Rich{Content: s.name}
+}
+
+func (s *Session) AsIconer() cchat.Iconer                 { return nil }
+func (s *Session) AsCommander() cchat.Commander           { return nil }
+func (s *Session) AsSessionSaver() cchat.SessionSaver     { return s }
+func (s *Session) SaveSession() map[string]string         { return s.data }
+func (s *Session) Servers(c cchat.ServersContainer) error { return s.player.servers(s.path, c) }
+
+// Disconnect stops everything that's being played back.
+func (s *Session) Disconnect() error {
+	s.player.cancel()
+	return nil
+}
+
+// playerCall is a recorded call along with its events.
+type playerCall struct {
+	start  Entry
+	events []Entry
+}
+
+// err returns the error returned by the call, if any.
+func (c *playerCall) err() error {
+	for _, event := range c.events {
+		if event.Event == EventReturn && event.Error != "" {
+			return errors.New(event.Error)
+		}
+	}
+	return nil
+}
+
+// player plays back recorded calls.
+type player struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	speed  float64
+
+	sessions []*playerCall
+	calls    map[string][]*playerCall
+
+	mutex sync.Mutex
+	used  map[string]int
+}
+
+func newPlayer(entries []Entry, speed float64) *player {
+	var calls = map[int]*playerCall{}
+	var p = &player{
+		speed: speed,
+		calls: map[string][]*playerCall{},
+		used:  map[string]int{},
+	}
+
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	for _, entry := range entries {
+		if entry.Kind != "" {
+			var call = &playerCall{start: entry}
+			calls[entry.Call] = call
+
+			if entry.Kind == KindSession {
+				p.sessions = append(p.sessions, call)
+			}
+
+			key := callKey(entry.Kind, entry.Path, entry.ID)
+			p.calls[key] = append(p.calls[key], call)
+			continue
+		}
+
+		if call, ok := calls[entry.Call]; ok {
+			call.events = append(call.events, entry)
+		}
+	}
+
+	return p
+}
+
+func callKey(kind string, path []cchat.ID, id cchat.ID) string {
+	// Only backlog calls are told apart by their ID.
+	if kind != KindBacklog {
+		id = ""
+	}
+	return kind + "\x00" + strings.Join(path, "\x00") + "\x00" + id
+}
+
+// next returns the recorded call to play back for the given call. The nth call
+// plays back the nth recorded call, and calls after the last recorded one play
+// back the last one again. Nil is returned if there's no recorded call.
+func (p *player) next(kind string, path []cchat.ID, id cchat.ID) *playerCall {
+	var key = callKey(kind, path, id)
+
+	var calls = p.calls[key]
+	if len(calls) == 0 {
+		return nil
+	}
+
+	p.mutex.Lock()
+	var n = p.used[key]
+	p.used[key]++
+	p.mutex.Unlock()
+
+	if n >= len(calls) {
+		n = len(calls) - 1
+	}
+
+	return calls[n]
+}
+
+// play plays back the events of the call with the recorded delays until the
+// context is done.
+func (p *player) play(ctx context.Context, call *playerCall, fn func(Entry)) error {
+	var start = time.Now()
+
+	for _, event := range call.events {
+		if event.Event == EventReturn {
+			continue
+		}
+
+		if p.speed > 0 {
+			delay := time.Duration(float64(event.Time-call.start.Time) / p.speed)
+
+			select {
+			case <-time.After(delay - time.Since(start)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		fn(event)
+	}
+
+	return nil
+}
+
+// playAsync plays back the call in the background until the given context is
+// done or the session is disconnected. The returned function stops the
+// playback.
+func (p *player) playAsync(
+	ctx context.Context, call *playerCall, fn func(Entry)) (func(), error) {
+
+	if err := call.err(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		select {
+		case <-p.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	go p.play(ctx, call, fn)
+
+	return cancel, nil
+}
+
+// servers plays back the servers at the given path. The recorded calls are
+// short, so this blocks like the backends usually do.
+func (p *player) servers(path []cchat.ID, container cchat.ServersContainer) error {
+	call := p.next(KindServers, path, "")
+	if call == nil {
+		return errors.New("No servers recorded")
+	}
+
+	p.play(p.ctx, call, func(event Entry) {
+		switch event.Event {
+		case EventSetServers:
+			var servers = make([]cchat.Server, len(event.Servers))
+			for i, info := range event.Servers {
+				servers[i] = &server{p, info, appendPath(path, info.ID)}
+			}
+			container.SetServers(servers)
+
+		case EventUpdateServer:
+			if len(event.Servers) == 0 {
+				return
+			}
+
+			info := event.Servers[0]
+			container.UpdateServer(serverUpdate{
+				server:  &server{p, info, appendPath(path, info.ID)},
+				prevID:  event.ID,
+				replace: event.Replace,
+			})
+		}
+	})
+
+	return call.err()
+}
+
+// server is a recorded server. It implements all the interfaces that the
+// recorded server implements.
+type server struct {
+	player *player
+	info   Server
+	path   []cchat.ID
+}
+
+var (
+	_ cchat.Server          = (*server)(nil)
+	_ cchat.Lister          = (*server)(nil)
+	_ cchat.Messenger       = (*server)(nil)
+	_ cchat.Backlogger      = (*server)(nil)
+	_ cchat.MemberLister    = (*server)(nil)
+	_ cchat.UnreadIndicator = (*server)(nil)
+	_ cchat.TypingIndicator = (*server)(nil)
+)
+
+func (s *server) ID() cchat.ID { return s.info.ID }
+
+func (s *server) Name() text.Rich {
+	return text.Rich{Content: s.info.Name}
+}
+
+func (s *server) AsIconer() cchat.Iconer             { return nil }
+func (s *server) AsCommander() cchat.Commander       { return nil }
+func (s *server) AsConfigurator() cchat.Configurator { return nil }
+func (s *server) AsSender() cchat.Sender             { return nil }
+func (s *server) AsEditor() cchat.Editor             { return nil }
+func (s *server) AsActioner() cchat.Actioner         { return nil }
+func (s *server) AsNicknamer() cchat.Nicknamer       { return nil }
+
+func (s *server) AsLister() cchat.Lister {
+	if s.info.Lister {
+		return s
+	}
+	return nil
+}
+
+func (s *server) AsMessenger() cchat.Messenger {
+	if s.info.Messenger {
+		return s
+	}
+	return nil
+}
+
+func (s *server) AsBacklogger() cchat.Backlogger {
+	if s.info.Backlogger {
+		return s
+	}
+	return nil
+}
+
+func (s *server) AsMemberLister() cchat.MemberLister {
+	if s.info.MemberLister {
+		return s
+	}
+	return nil
+}
+
+func (s *server) AsUnreadIndicator() cchat.UnreadIndicator {
+	if s.info.UnreadIndicator {
+		return s
+	}
+	return nil
+}
+
+func (s *server) AsTypingIndicator() cchat.TypingIndicator {
+	if s.info.TypingIndicator {
+		return s
+	}
+	return nil
+}
+
+func (s *server) Servers(container cchat.ServersContainer) error {
+	return s.player.servers(s.path, container)
+}
+
+// call returns the next recorded call of the given kind on this server.
+func (s *server) call(kind string, id cchat.ID) (*playerCall, error) {
+	call := s.player.next(kind, s.path, id)
+	if call == nil {
+		return nil, errors.Errorf("No %s call recorded for %s", kind, s.info.Name)
+	}
+	return call, nil
+}
+
+func (s *server) JoinServer(ctx context.Context, c cchat.MessagesContainer) (func(), error) {
+	call, err := s.call(KindJoin, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.player.playAsync(ctx, call, playMessages(c))
+}
+
+func (s *server) Backlog(ctx context.Context, before cchat.ID, c cchat.MessagesContainer) error {
+	call, err := s.call(KindBacklog, before)
+	if err != nil {
+		return err
+	}
+
+	if err := s.player.play(ctx, call, playMessages(c)); err != nil {
+		return err
+	}
+
+	return call.err()
+}
+
+func (s *server) ListMembers(ctx context.Context, c cchat.MemberListContainer) (func(), error) {
+	call, err := s.call(KindMembers, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.player.playAsync(ctx, call, func(event Entry) {
+		switch event.Event {
+		case EventSetSections:
+			var sections = make([]cchat.MemberSection, len(event.Sections))
+			for i := range event.Sections {
+				sections[i] = section{&event.Sections[i]}
+			}
+			c.SetSections(sections)
+
+		case EventSetMember:
+			if event.Member != nil {
+				c.SetMember(event.ID, member{event.Member})
+			}
+
+		case EventRemoveMember:
+			if event.Member != nil {
+				c.RemoveMember(event.ID, event.Member.ID)
+			}
+		}
+	})
+}
+
+func (s *server) UnreadIndicate(c cchat.UnreadContainer) (func(), error) {
+	call, err := s.call(KindUnread, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.player.playAsync(s.player.ctx, call, func(event Entry) {
+		if event.Event == EventSetUnread {
+			c.SetUnread(event.Unread, event.Mentioned)
+		}
+	})
+}
+
+func (s *server) TypingTimeout() time.Duration { return s.info.TypingTimeout }
+
+// Typing does nothing, since nobody is listening.
+func (s *server) Typing() error { return nil }
+
+func (s *server) TypingSubscribe(c cchat.TypingContainer) (func(), error) {
+	call, err := s.call(KindTyping, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.player.playAsync(s.player.ctx, call, func(event Entry) {
+		switch event.Event {
+		case EventAddTyper:
+			if event.Typer != nil {
+				// Typers are timed out using their time, so it has to be now.
+				c.AddTyper(author{event.Typer, time.Now()})
+			}
+
+		case EventRemoveTyper:
+			c.RemoveTyper(event.ID)
+		}
+	})
+}
+
+type serverUpdate struct {
+	*server
+	prevID  cchat.ID
+	replace bool
+}
+
+func (u serverUpdate) PreviousID() (cchat.ID, bool) {
+	return u.prevID, u.replace
+}
+
+// playMessages returns a function that plays back message events into the
+// container.
+func playMessages(c cchat.MessagesContainer) func(Entry) {
+	return func(event Entry) {
+		if event.Message == nil {
+			return
+		}
+
+		switch event.Event {
+		case EventCreateMessage:
+			c.CreateMessage(message{event.Message})
+		case EventUpdateMessage:
+			c.UpdateMessage(message{event.Message})
+		case EventDeleteMessage:
+			c.DeleteMessage(message{event.Message})
+		}
+	}
+}
+
+type message struct {
+	msg *Message
+}
+
+var (
+	_ cchat.MessageCreate = message{}
+	_ cchat.MessageUpdate = message{}
+	_ cchat.MessageDelete = message{}
+)
+
+func (m message) ID() cchat.ID       { return m.msg.ID }
+func (m message) Time() time.Time    { return m.msg.Time }
+func (m message) Nonce() string      { return m.msg.Nonce }
+func (m message) Mentioned() bool    { return m.msg.Mentioned }
+func (m message) Content() text.Rich { return richText(m.msg.Content, m.msg.Segments) }
+
+func (m message) Author() cchat.Author {
+	if m.msg.Author == nil {
+		return nil
+	}
+	return author{m.msg.Author, m.msg.Author.Time}
+}
+
+// author is a recorded author. It's also a typer.
+type author struct {
+	author *Author
+	time   time.Time
+}
+
+var _ cchat.Typer = author{}
+
+func (a author) ID() cchat.ID    { return a.author.ID }
+func (a author) Avatar() string  { return a.author.Avatar }
+func (a author) Time() time.Time { return a.time }
+
+func (a author) Name() text.Rich {
+	return text.Rich{Content: a.author.Name}
+}
+
+type section struct {
+	section *Section
+}
+
+func (s section) ID() cchat.ID                                       { return s.section.ID }
+func (s section) Total() int                                         { return s.section.Total }
+func (s section) AsIconer() cchat.Iconer                             { return nil }
+func (s section) AsMemberDynamicSection() cchat.MemberDynamicSection { return nil }
+
+func (s section) Name() text.Rich {
+	return text.Rich{Content: s.section.Name}
+}
+
+type member struct {
+	member *Member
+}
+
+func (m member) ID() cchat.ID           { return m.member.ID }
+func (m member) Status() cchat.Status   { return m.member.Status }
+func (m member) AsIconer() cchat.Iconer { return nil }
+
+func (m member) Name() text.Rich {
+	return text.Rich{Content: m.member.Name}
+}
+
+func (m member) Secondary() text.Rich {
+	return text.Rich{Content: m.member.Secondary}
+}
+
+func richText(content string, segments []Segment) text.Rich {
+	var rich = text.Rich{Content: content}
+	if len(segments) > 0 {
+		rich.Segments = make([]text.Segment, len(segments))
+		for i := range segments {
+			rich.Segments[i] = segment{&segments[i]}
+		}
+	}
+	return rich
+}
+
+// segment is a recorded segment. It implements the formats that the recorded
+// segment has.
+type segment struct {
+	segment *Segment
+}
+
+var (
+	_ text.Segment           = segment{}
+	_ text.Colorer           = segment{}
+	_ text.Linker            = segment{}
+	_ text.Imager            = segment{}
+	_ text.Avatarer          = segment{}
+	_ text.Mentioner         = segment{}
+	_ text.Attributor        = segment{}
+	_ text.Codeblocker       = segment{}
+	_ text.Quoteblocker      = segment{}
+	_ text.MessageReferencer = segment{}
+)
+
+func (s segment) Bounds() (int, int) { return s.segment.Start, s.segment.End }
+
+func (s segment) AsColorer() text.Colorer {
+	if s.segment.Color != nil {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsLinker() text.Linker {
+	if s.segment.Link != "" {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsImager() text.Imager {
+	if s.segment.Image != nil {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsAvatarer() text.Avatarer {
+	if s.segment.Avatar != nil {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsMentioner() text.Mentioner {
+	if s.segment.Mention != nil {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsAttributor() text.Attributor {
+	if s.segment.Attribute != 0 {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsCodeblocker() text.Codeblocker {
+	if s.segment.Codeblock != nil {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsQuoteblocker() text.Quoteblocker {
+	if s.segment.Quote != nil {
+		return s
+	}
+	return nil
+}
+
+func (s segment) AsMessageReferencer() text.MessageReferencer {
+	if s.segment.MessageID != "" {
+		return s
+	}
+	return nil
+}
+
+func (s segment) Color() uint32             { return *s.segment.Color }
+func (s segment) Link() string              { return s.segment.Link }
+func (s segment) Image() string             { return s.segment.Image.URL }
+func (s segment) ImageText() string         { return s.segment.Image.Text }
+func (s segment) ImageSize() (int, int)     { return s.segment.Image.Width, s.segment.Image.Height }
+func (s segment) Avatar() string            { return s.segment.Avatar.URL }
+func (s segment) AvatarText() string        { return s.segment.Avatar.Text }
+func (s segment) AvatarSize() int           { return s.segment.Avatar.Size }
+func (s segment) Attribute() text.Attribute { return s.segment.Attribute }
+func (s segment) CodeblockLanguage() string { return *s.segment.Codeblock }
+func (s segment) QuotePrefix() string       { return *s.segment.Quote }
+func (s segment) MessageID() string         { return s.segment.MessageID }
+
+func (s segment) MentionInfo() text.Rich {
+	return richText(s.segment.Mention.Content, s.segment.Mention.Segments)
+}
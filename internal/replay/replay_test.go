@@ -0,0 +1,209 @@
+package replay
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat/text"
+)
+
+func writeTrace(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "cchat-replay")
+	if err != nil {
+		t.Fatal("Failed to make temp dir:", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "trace.jsonl")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal("Failed to write trace:", err)
+	}
+
+	return path
+}
+
+func TestReadTrace(t *testing.T) {
+	path := writeTrace(t,
+		`{"time":0,"call":1,"kind":"join","path":["svc","ses","srv"]}`,
+		``,
+		`{"time":5,"call":1,"event":"message.create","message":{"id":"1","time":"2021-01-01T00:00:00Z","content":"hi"}}`,
+		`{"time":6,"call":1,"event":"return"}`,
+	)
+
+	entries, err := ReadTrace(path)
+	if err != nil {
+		t.Fatal("Failed to read trace:", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[0].Kind != KindJoin || !reflect.DeepEqual(entries[0].Path, []cchat.ID{"svc", "ses", "srv"}) {
+		t.Fatalf("Unexpected call entry: %+v", entries[0])
+	}
+
+	if msg := entries[1].Message; msg == nil || msg.ID != "1" || msg.Content != "hi" {
+		t.Fatalf("Unexpected message entry: %+v", entries[1])
+	}
+}
+
+func TestReadTraceInvalid(t *testing.T) {
+	path := writeTrace(t,
+		`{"time":0,"call":1,"kind":"join"}`,
+		`{"time":`,
+	)
+
+	_, err := ReadTrace(path)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatal("Expected an error at line 2, got", err)
+	}
+
+	if _, err := ReadTrace(path + ".missing"); err == nil {
+		t.Fatal("Expected an error for a missing trace")
+	}
+}
+
+func TestPlayerNext(t *testing.T) {
+	var path = []cchat.ID{"svc", "ses", "srv"}
+
+	p := newPlayer([]Entry{
+		{Call: 1, Kind: KindJoin, Path: path},
+		{Call: 1, Event: EventReturn},
+		{Call: 2, Kind: KindJoin, Path: path},
+		{Call: 2, Event: EventReturn, Error: "failed"},
+		{Call: 3, Kind: KindBacklog, Path: path, ID: "10"},
+		{Call: 4, Kind: KindBacklog, Path: path, ID: "5"},
+	}, 0)
+	defer p.cancel()
+
+	// Calls play back the recorded calls in order, then the last one again.
+	for i, expect := range []int{1, 2, 2} {
+		if call := p.next(KindJoin, path, ""); call.start.Call != expect {
+			t.Fatalf("Join %d played back call %d, expected %d", i, call.start.Call, expect)
+		}
+	}
+
+	if p.next(KindJoin, path, "").err() == nil {
+		t.Fatal("Expected the recorded error")
+	}
+
+	// Backlogs are told apart by the message ID.
+	if call := p.next(KindBacklog, path, "5"); call == nil || call.start.Call != 4 {
+		t.Fatal("Unexpected backlog call:", call)
+	}
+
+	if call := p.next(KindMembers, path, ""); call != nil {
+		t.Fatal("Unexpected members call:", call)
+	}
+}
+
+func TestPlayAsyncCancel(t *testing.T) {
+	var call = &playerCall{
+		start: Entry{Call: 1, Kind: KindJoin},
+		events: []Entry{
+			{Call: 1, Event: EventCreateMessage, Time: 0},
+			{Call: 1, Event: EventCreateMessage, Time: time.Hour},
+		},
+	}
+
+	var tests = []struct {
+		name string
+		stop func(p *player, cancelCtx, stop func())
+	}{
+		{"context", func(p *player, cancelCtx, stop func()) { cancelCtx() }},
+		{"stop", func(p *player, cancelCtx, stop func()) { stop() }},
+		{"disconnect", func(p *player, cancelCtx, stop func()) { p.cancel() }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newPlayer(nil, 1)
+			defer p.cancel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var played = make(chan struct{}, 2)
+
+			stop, err := p.playAsync(ctx, call, func(Entry) { played <- struct{}{} })
+			if err != nil {
+				t.Fatal("Failed to play:", err)
+			}
+			defer stop()
+
+			select {
+			case <-played:
+			case <-time.After(5 * time.Second):
+				t.Fatal("The first event wasn't played")
+			}
+
+			test.stop(p, cancel, stop)
+
+			// The second event is an hour away, so it's only played if the
+			// delay is skipped.
+			select {
+			case <-played:
+				t.Fatal("The second event was played")
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	var color = uint32(0xFF0000FF)
+	var language = "go"
+
+	var segments = []Segment{{
+		Start: 0,
+		End:   4,
+		Color: &color,
+		Mention: &Rich{
+			Content:  "info",
+			Segments: []Segment{{Start: 0, End: 4, Attribute: text.AttributeBold}},
+		},
+		Avatar: &Avatar{URL: "https://example.com/a.png", Text: "a", Size: 16},
+	}, {
+		Start:     5,
+		End:       9,
+		Link:      "https://example.com",
+		Attribute: text.AttributeItalics | text.AttributeUnderline,
+	}, {
+		Start: 9,
+		End:   9,
+		Image: &Image{URL: "https://example.com/i.png", Width: 10, Height: 20},
+	}, {
+		Start:     10,
+		End:       20,
+		Codeblock: &language,
+	}, {
+		Start:     21,
+		End:       25,
+		MessageID: "123",
+	}}
+
+	rich := richText("text", segments)
+
+	if rich.Segments[1].AsColorer() != nil || rich.Segments[0].AsLinker() != nil {
+		t.Fatal("Segments implement formats that they don't have")
+	}
+
+	if info := rich.Segments[0].AsMentioner().MentionInfo(); info.Content != "info" {
+		t.Fatal("Unexpected mention info:", info)
+	}
+
+	// Recording the played back segments must give the same segments.
+	if recorded := snapshotSegments(rich.Segments); !reflect.DeepEqual(recorded, segments) {
+		t.Fatalf("Unexpected segments: %+v", recorded)
+	}
+}
@@ -0,0 +1,203 @@
+// Package replay records what cchat services do into a trace and plays traces
+// back as a service. This allows UI regressions to be reproduced without a
+// network connection.
+//
+// A trace is a file of JSON entries, one per line. Each call made by the
+// frontend that gives the backend a container starts a call entry, which is
+// followed by the event entries of everything the backend did to the
+// container. Entries carry the time since the start of the recording, so the
+// timing can be reproduced.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat/text"
+	"github.com/pkg/errors"
+)
+
+// Call kinds. Each kind corresponds to a cchat method that the frontend calls.
+const (
+	KindSession    = "session"    // Authenticate or RestoreSession
+	KindServers    = "servers"    // Lister.Servers
+	KindJoin       = "join"       // Messenger.JoinServer
+	KindBacklog    = "backlog"    // Backlogger.Backlog
+	KindTyping     = "typing"     // TypingIndicator.TypingSubscribe
+	KindMembers    = "members"    // MemberLister.ListMembers
+	KindUnread     = "unread"     // UnreadIndicator.UnreadIndicate
+	KindSend       = "send"       // Sender.Send
+	KindEdit       = "edit"       // Editor.Edit
+	KindAction     = "action"     // Actioner.Do
+	KindDisconnect = "disconnect" // Session.Disconnect
+)
+
+// Event types. Each type corresponds to a container method that the backend
+// calls, except for EventReturn, which marks the end of a call.
+const (
+	EventReturn        = "return"
+	EventSetServers    = "servers.set"
+	EventUpdateServer  = "servers.update"
+	EventCreateMessage = "message.create"
+	EventUpdateMessage = "message.update"
+	EventDeleteMessage = "message.delete"
+	EventAddTyper      = "typer.add"
+	EventRemoveTyper   = "typer.remove"
+	EventSetSections   = "members.sections"
+	EventSetMember     = "members.set"
+	EventRemoveMember  = "members.remove"
+	EventSetUnread     = "unread.set"
+)
+
+// Entry is a single line in a trace. Call entries have Kind set, while event
+// entries have Event set. Other fields are only set if they apply.
+type Entry struct {
+	Time  time.Duration `json:"time"`
+	Call  int           `json:"call"`
+	Kind  string        `json:"kind,omitempty"`
+	Event string        `json:"event,omitempty"`
+
+	// Path is the path of IDs of the call, starting from the service name.
+	Path  []cchat.ID `json:"path,omitempty"`
+	Error string     `json:"error,omitempty"`
+
+	// Name is the session name in session calls.
+	Name string `json:"name,omitempty"`
+	// ID is the message ID of edits and actions, the removed typer or member,
+	// the section of members, or the previous server of updates.
+	ID cchat.ID `json:"id,omitempty"`
+	// Content is the content of sends and edits, or the action name.
+	Content string `json:"content,omitempty"`
+
+	Replace   bool `json:"replace,omitempty"`
+	Unread    bool `json:"unread,omitempty"`
+	Mentioned bool `json:"mentioned,omitempty"`
+
+	Servers  []Server  `json:"servers,omitempty"`
+	Message  *Message  `json:"message,omitempty"`
+	Typer    *Author   `json:"typer,omitempty"`
+	Sections []Section `json:"sections,omitempty"`
+	Member   *Member   `json:"member,omitempty"`
+}
+
+// Server is a recorded server along with what it can do.
+type Server struct {
+	ID              cchat.ID      `json:"id"`
+	Name            string        `json:"name"`
+	Lister          bool          `json:"lister,omitempty"`
+	Messenger       bool          `json:"messenger,omitempty"`
+	Backlogger      bool          `json:"backlogger,omitempty"`
+	MemberLister    bool          `json:"member_lister,omitempty"`
+	UnreadIndicator bool          `json:"unread_indicator,omitempty"`
+	TypingIndicator bool          `json:"typing_indicator,omitempty"`
+	TypingTimeout   time.Duration `json:"typing_timeout,omitempty"`
+}
+
+// Author is a recorded message author or typer.
+type Author struct {
+	ID     cchat.ID  `json:"id"`
+	Name   string    `json:"name"`
+	Avatar string    `json:"avatar,omitempty"`
+	Time   time.Time `json:"time,omitempty"`
+}
+
+// Message is a recorded message event. Fields that the event doesn't have are
+// omitted.
+type Message struct {
+	ID        cchat.ID  `json:"id"`
+	Time      time.Time `json:"time"`
+	Nonce     string    `json:"nonce,omitempty"`
+	Author    *Author   `json:"author,omitempty"`
+	Content   string    `json:"content,omitempty"`
+	Segments  []Segment `json:"segments,omitempty"`
+	Mentioned bool      `json:"mentioned,omitempty"`
+}
+
+// Rich is recorded rich text.
+type Rich struct {
+	Content  string    `json:"content"`
+	Segments []Segment `json:"segments,omitempty"`
+}
+
+// Segment is a recorded rich text segment. Only the fields of the formats that
+// the segment has are set.
+type Segment struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	Color     *uint32        `json:"color,omitempty"`
+	Link      string         `json:"link,omitempty"`
+	Image     *Image         `json:"image,omitempty"`
+	Avatar    *Avatar        `json:"avatar,omitempty"`
+	Mention   *Rich          `json:"mention,omitempty"`
+	Attribute text.Attribute `json:"attribute,omitempty"`
+	Codeblock *string        `json:"codeblock,omitempty"` // language
+	Quote     *string        `json:"quote,omitempty"`     // prefix
+	MessageID cchat.ID       `json:"message_id,omitempty"`
+}
+
+// Image is a recorded image segment.
+type Image struct {
+	URL    string `json:"url"`
+	Text   string `json:"text,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Avatar is a recorded avatar segment.
+type Avatar struct {
+	URL  string `json:"url"`
+	Text string `json:"text,omitempty"`
+	Size int    `json:"size,omitempty"`
+}
+
+// Section is a recorded member list section.
+type Section struct {
+	ID    cchat.ID `json:"id"`
+	Name  string   `json:"name"`
+	Total int      `json:"total"`
+}
+
+// Member is a recorded member list entry.
+type Member struct {
+	ID        cchat.ID     `json:"id"`
+	Name      string       `json:"name"`
+	Secondary string       `json:"secondary,omitempty"`
+	Status    cchat.Status `json:"status,omitempty"`
+}
+
+// ReadTrace reads all entries from the trace file at the given path.
+func ReadTrace(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open trace")
+	}
+	defer f.Close()
+
+	var entries []Entry
+
+	var scanner = bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errors.Wrapf(err, "Invalid entry at line %d", line)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "Failed to read trace")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,161 @@
+package ui
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/deeplink"
+	"github.com/diamondburned/cchat-gtk/internal/ui/service"
+	"github.com/diamondburned/cchat-gtk/internal/ui/service/session"
+	"github.com/diamondburned/cchat-gtk/internal/ui/service/session/server"
+	"github.com/pkg/errors"
+)
+
+var _ gts.CommandLiner = (*App)(nil)
+
+// CommandLine handles the command line of this instance or of a later launch,
+// which allows scripts to control the running instance. Paths are
+// slash-separated breadcrumbs from the service down to the server, where each
+// part can either be the name or the ID. Arguments that are cchat:// links are
+// opened.
+func (app *App) CommandLine(cmd *gts.CommandLine) int {
+	args := cmd.Args()
+
+	var output strings.Builder
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.SetOutput(&output)
+
+	var (
+		open = flags.String("open", "", "open the server at the given `path`")
+		send = flags.String("send", "", "send the other arguments as a message to the server at `path`")
+		list = flags.Bool("list-sessions", false, "list all sessions")
+		quit = flags.Bool("quit", false, "quit the running instance")
+	)
+
+	if err := flags.Parse(args[1:]); err != nil {
+		cmd.PrintErr(output.String())
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	switch {
+	case *quit:
+		gts.App.Window.Destroy()
+
+	case *list:
+		cmd.Print(app.listSessions())
+
+	case *open != "":
+		app.cmdlineServer(cmd, *open, func(svc *service.Service, ses *session.Row, srv *server.ServerRow) error {
+			app.Services.SessionSelected(svc, ses)
+			app.Services.MessengerSelected(ses, srv)
+			gts.App.Activate()
+			return nil
+		})
+
+	case *send != "":
+		text := strings.Join(flags.Args(), " ")
+		if text == "" {
+			cmd.PrintErr("Nothing to send.\n")
+			return 2
+		}
+
+		app.cmdlineServer(cmd, *send, func(_ *service.Service, _ *session.Row, srv *server.ServerRow) error {
+			sender := srv.Server.AsMessenger().AsSender()
+			if sender == nil {
+				return errors.New("Server is read-only")
+			}
+
+			cmd.Hold()
+
+			go func() {
+				err := sender.Send(pluginSendable{Text: text})
+				err = errors.Wrap(err, "Failed to send message")
+
+				gts.ExecAsync(func() { cmdlineDone(cmd, err) })
+			}()
+
+			return nil
+		})
+
+	default:
+		gts.App.Activate()
+
+		// Open all cchat:// links, which is how the URI scheme handler calls
+		// us.
+		for _, arg := range flags.Args() {
+			if !deeplink.IsLink(arg) {
+				continue
+			}
+
+			link := arg
+			cmd.Hold()
+			app.OpenLink(link, func(err error) {
+				cmdlineDone(cmd, errors.Wrapf(err, "Failed to open %q", link))
+			})
+		}
+	}
+
+	return 0
+}
+
+// cmdlineServer resolves the server at the given path, then calls fn with it.
+// The command line is held until the path is resolved.
+func (app *App) cmdlineServer(
+	cmd *gts.CommandLine, path string,
+	fn func(*service.Service, *session.Row, *server.ServerRow) error) {
+
+	cmd.Hold()
+
+	app.findServer(strings.Split(path, "/"), true,
+		func(svc *service.Service, ses *session.Row, srv *server.ServerRow, err error) {
+			if err == nil {
+				err = fn(svc, ses, srv)
+			}
+			cmdlineDone(cmd, errors.Wrapf(err, "Failed to open %q", path))
+		},
+	)
+}
+
+// cmdlineDone releases the held command line. The error is printed if it's not
+// nil.
+func cmdlineDone(cmd *gts.CommandLine, err error) {
+	if err != nil {
+		cmd.PrintErr(err.Error() + "\n")
+		log.Error(err)
+		cmd.Done(1)
+		return
+	}
+
+	cmd.Done(0)
+}
+
+// listSessions returns a line for each session with its path, ID and whether or
+// not it's connected.
+func (app *App) listSessions() string {
+	var builder strings.Builder
+
+	for _, svc := range app.Services.Services.Services {
+		for _, ses := range svc.BodyList.Sessions() {
+			name := ses.Breadcrumb()
+			if name == "" {
+				name = ses.ID()
+			}
+
+			state := "disconnected"
+			if ses.Session != nil {
+				state = "connected"
+			}
+
+			fmt.Fprintf(&builder, "%s/%s\t%s\t%s\n", svc.Breadcrumb(), name, ses.ID(), state)
+		}
+	}
+
+	return builder.String()
+}
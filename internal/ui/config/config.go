@@ -16,6 +16,12 @@ type Section uint8
 
 const (
 	Appearance Section = iota
+	Highlights
+	Filters
+	Plugins
+	Developer
+	Media // sections are saved in order, so new ones go last
+	Network
 	sectionLen
 )
 
@@ -23,6 +29,18 @@ func (s Section) String() string {
 	switch s {
 	case Appearance:
 		return "Appearance"
+	case Highlights:
+		return "Highlights"
+	case Filters:
+		return "Filters"
+	case Plugins:
+		return "Plugins"
+	case Developer:
+		return "Developer"
+	case Media:
+		return "Media"
+	case Network:
+		return "Network"
 	default:
 		return "???"
 	}
@@ -53,10 +71,38 @@ func (s SectionEntries) UnmarshalJSON(b []byte) error {
 var sections = [sectionLen]SectionEntries{}
 
 func AppearanceAdd(name string, value EntryValue) {
-	sc := sections[Appearance]
+	sectionAdd(Appearance, name, value)
+}
+
+func HighlightsAdd(name string, value EntryValue) {
+	sectionAdd(Highlights, name, value)
+}
+
+func FiltersAdd(name string, value EntryValue) {
+	sectionAdd(Filters, name, value)
+}
+
+func PluginsAdd(name string, value EntryValue) {
+	sectionAdd(Plugins, name, value)
+}
+
+func DeveloperAdd(name string, value EntryValue) {
+	sectionAdd(Developer, name, value)
+}
+
+func MediaAdd(name string, value EntryValue) {
+	sectionAdd(Media, name, value)
+}
+
+func NetworkAdd(name string, value EntryValue) {
+	sectionAdd(Network, name, value)
+}
+
+func sectionAdd(section Section, name string, value EntryValue) {
+	sc := sections[section]
 	if sc == nil {
 		sc = make(SectionEntries, 1)
-		sections[Appearance] = sc
+		sections[section] = sc
 	}
 
 	sc[name] = value
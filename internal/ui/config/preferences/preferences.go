@@ -47,14 +47,25 @@ func NewDialog() *Dialog {
 func Section(entries []config.Entry) *gtk.Grid {
 	var grid, _ = gtk.GridNew()
 
-	for i, entry := range entries {
+	var row int
+
+	for _, entry := range entries {
 		l, _ := gtk.LabelNew(entry.Name)
 		l.SetHExpand(true)
 		l.SetXAlign(0)
 		l.Show()
 
-		grid.Attach(l, 0, i, 1, 1)
-		grid.Attach(entry.Value.Construct(), 1, i, 1, 1)
+		grid.Attach(l, 0, row, 1, 1)
+
+		// Wide entries take the whole next row.
+		if _, ok := entry.Value.(config.WideEntryValue); ok {
+			row++
+			grid.Attach(entry.Value.Construct(), 0, row, 2, 1)
+		} else {
+			grid.Attach(entry.Value.Construct(), 1, row, 1, 1)
+		}
+
+		row++
 	}
 
 	grid.SetRowSpacing(4)
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// RuleRow is the widget that edits a single rule in a RuleList.
+type RuleRow interface {
+	gtk.IWidget
+	// OnChange sets the function to call after the rule is changed.
+	OnChange(func())
+}
+
+// RuleList is an editor for an ordered list of rules. Each rule is edited in
+// its own row, which has a button to remove it.
+type RuleList struct {
+	// AddLabel is the label of the button that adds a new rule.
+	AddLabel string
+	// Rows returns the rows of the current rules.
+	Rows func() []RuleRow
+	// New returns the row of a new rule.
+	New func() RuleRow
+	// Set is called with the rows in order when the list changes.
+	Set func([]RuleRow)
+}
+
+// Construct creates the editor.
+func (l RuleList) Construct() *gtk.Box {
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	list.Show()
+
+	// rows is used to keep the list of rules in the same order as the rows.
+	var rows []RuleRow
+
+	var update = func() {
+		l.Set(append([]RuleRow(nil), rows...))
+	}
+
+	var addRow = func(row RuleRow) {
+		row.OnChange(update)
+
+		remove, _ := gtk.ButtonNewFromIconName("list-remove-symbolic", gtk.ICON_SIZE_BUTTON)
+		remove.SetRelief(gtk.RELIEF_NONE)
+		remove.SetVAlign(gtk.ALIGN_CENTER)
+		remove.Show()
+
+		box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+		box.PackStart(row, true, true, 0)
+		box.PackStart(remove, false, false, 0)
+		box.Show()
+
+		listRow, _ := gtk.ListBoxRowNew()
+		listRow.SetActivatable(false)
+		listRow.Add(box)
+		listRow.Show()
+
+		remove.Connect("clicked", func(interface{}) {
+			for i, r := range rows {
+				if r == row {
+					rows = append(rows[:i], rows[i+1:]...)
+					break
+				}
+			}
+
+			listRow.Destroy()
+			update()
+		})
+
+		rows = append(rows, row)
+		list.Add(listRow)
+	}
+
+	for _, row := range l.Rows() {
+		addRow(row)
+	}
+
+	add, _ := gtk.ButtonNewFromIconName("list-add-symbolic", gtk.ICON_SIZE_BUTTON)
+	add.SetLabel(l.AddLabel)
+	add.SetAlwaysShowImage(true)
+	add.SetHAlign(gtk.ALIGN_END)
+	add.Show()
+	add.Connect("clicked", func(interface{}) {
+		addRow(l.New())
+		update()
+	})
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 4)
+	box.PackStart(list, false, false, 0)
+	box.PackStart(add, false, false, 0)
+	box.Show()
+
+	return box
+}
@@ -13,6 +13,14 @@ type EntryValue interface {
 	Construct() gtk.IWidget
 }
 
+// WideEntryValue is an optional interface that an EntryValue could implement
+// to have its widget span the whole width below its label instead of sitting
+// next to it. This is useful for larger widgets such as list editors.
+type WideEntryValue interface {
+	EntryValue
+	Wide()
+}
+
 type _combo struct {
 	selected *int
 	options  []string
@@ -53,7 +61,7 @@ func (c *_combo) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &value); err != nil {
 		return err
 	}
-	*c.selected = value
+	c.set(value)
 	return nil
 }
 
@@ -99,10 +107,18 @@ func (s *_switch) UnmarshalJSON(b []byte) error {
 type _inputentry struct {
 	value  *string
 	change func(string) error
+	lazy   bool
 }
 
 func InputEntry(value *string, change func(string) error) EntryValue {
-	return &_inputentry{value, change}
+	return &_inputentry{value, change, false}
+}
+
+// LazyInputEntry is like InputEntry, except the value is only changed once the
+// entry is activated or loses focus. This is for values that are expensive to
+// apply or invalid until they're fully typed.
+func LazyInputEntry(value *string, change func(string) error) EntryValue {
+	return &_inputentry{value, change, true}
 }
 
 func (e *_inputentry) set(v string) error {
@@ -118,19 +134,34 @@ func (e *_inputentry) Construct() gtk.IWidget {
 	entry.SetHExpand(true)
 	entry.SetText(*e.value)
 
-	entry.Connect("changed", func(entry *gtk.Entry) {
+	var apply = func() {
 		v, err := entry.GetText()
 		if err != nil {
 			return
 		}
 
+		// Don't apply the same value again when the entry loses focus.
+		if e.lazy && v == *e.value {
+			return
+		}
+
 		if err := e.set(v); err != nil {
 			entry.SetIconFromIconName(gtk.ENTRY_ICON_SECONDARY, "dialog-error")
 			entry.SetIconTooltipText(gtk.ENTRY_ICON_SECONDARY, err.Error())
 		} else {
 			entry.RemoveIcon(gtk.ENTRY_ICON_SECONDARY)
 		}
-	})
+	}
+
+	if e.lazy {
+		entry.Connect("activate", apply)
+		entry.Connect("focus-out-event", func() bool {
+			apply()
+			return false
+		})
+	} else {
+		entry.Connect("changed", apply)
+	}
 
 	entry.Show()
 
@@ -146,6 +177,5 @@ func (e *_inputentry) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &value); err != nil {
 		return err
 	}
-	e.set(value)
-	return nil
+	return e.set(value)
 }
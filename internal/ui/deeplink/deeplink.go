@@ -0,0 +1,70 @@
+// Package deeplink formats and parses cchat:// links, which point to a server
+// or a message inside a server. A link is the path of IDs from the service
+// down to the server, optionally followed by the message ID:
+//
+//	cchat://<service>/<session>/<server>[/<server>...][/<message>]
+//
+// Each part is path-escaped.
+package deeplink
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/diamondburned/cchat"
+	"github.com/pkg/errors"
+)
+
+// Scheme is the URI scheme of the links.
+const Scheme = "cchat"
+
+// IsLink returns true if the given string is a cchat:// link.
+func IsLink(str string) bool {
+	return strings.HasPrefix(str, Scheme+"://")
+}
+
+// Format formats the given path of IDs into a link. The message ID is omitted
+// if it's empty.
+func Format(path []cchat.ID, msgID cchat.ID) string {
+	var parts = make([]string, 0, len(path)+1)
+	for _, id := range path {
+		parts = append(parts, url.PathEscape(id))
+	}
+	if msgID != "" {
+		parts = append(parts, url.PathEscape(msgID))
+	}
+
+	return Scheme + "://" + strings.Join(parts, "/")
+}
+
+// Parse parses the link into the path of IDs. Whether or not the last ID is a
+// message can only be known by looking it up.
+func Parse(link string) ([]cchat.ID, error) {
+	if !IsLink(link) {
+		return nil, errors.Errorf("Not a %s:// link", Scheme)
+	}
+
+	var parts = strings.Split(strings.TrimPrefix(link, Scheme+"://"), "/")
+	var path = make([]cchat.ID, 0, len(parts))
+
+	for _, part := range parts {
+		// Allow trailing slashes.
+		if part == "" {
+			continue
+		}
+
+		id, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid link")
+		}
+
+		path = append(path, id)
+	}
+
+	// A link needs at least the service, the session and a server.
+	if len(path) < 3 {
+		return nil, errors.New("Link is too short")
+	}
+
+	return path, nil
+}
@@ -0,0 +1,115 @@
+package deeplink
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/diamondburned/cchat"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		name   string
+		link   string
+		expect []cchat.ID
+		err    bool
+	}{{
+		name:   "server",
+		link:   "cchat://discord/1/2",
+		expect: []cchat.ID{"discord", "1", "2"},
+	}, {
+		name:   "nested with message",
+		link:   "cchat://discord/1/2/3/4",
+		expect: []cchat.ID{"discord", "1", "2", "3", "4"},
+	}, {
+		name:   "trailing slash",
+		link:   "cchat://discord/1/2/",
+		expect: []cchat.ID{"discord", "1", "2"},
+	}, {
+		name:   "escaped",
+		link:   "cchat://irc/me%40host/%23go%2Fnuts",
+		expect: []cchat.ID{"irc", "me@host", "#go/nuts"},
+	}, {
+		name: "too short",
+		link: "cchat://discord/1",
+		err:  true,
+	}, {
+		name: "empty",
+		link: "cchat://",
+		err:  true,
+	}, {
+		name: "wrong scheme",
+		link: "https://discord/1/2",
+		err:  true,
+	}, {
+		name: "invalid escape",
+		link: "cchat://discord/1/%zz",
+		err:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, err := Parse(test.link)
+			if test.err {
+				if err == nil {
+					t.Fatalf("Expected an error, got %q", path)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			if !reflect.DeepEqual(path, test.expect) {
+				t.Fatalf("Unexpected path: %q, expected %q", path, test.expect)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	var tests = []struct {
+		name   string
+		path   []cchat.ID
+		msgID  cchat.ID
+		expect string
+	}{{
+		name:   "server",
+		path:   []cchat.ID{"discord", "1", "2"},
+		expect: "cchat://discord/1/2",
+	}, {
+		name:   "message",
+		path:   []cchat.ID{"discord", "1", "2"},
+		msgID:  "3",
+		expect: "cchat://discord/1/2/3",
+	}, {
+		name:   "escaped",
+		path:   []cchat.ID{"irc", "me@host", "#go/nuts"},
+		msgID:  "a b",
+		expect: "cchat://irc/me@host/%23go%2Fnuts/a%20b",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			link := Format(test.path, test.msgID)
+			if link != test.expect {
+				t.Fatalf("Unexpected link: %q, expected %q", link, test.expect)
+			}
+
+			// The link must parse back into the same IDs.
+			var ids = test.path
+			if test.msgID != "" {
+				ids = append(append([]cchat.ID(nil), ids...), test.msgID)
+			}
+
+			path, err := Parse(link)
+			if err != nil {
+				t.Fatal("Failed to parse the link:", err)
+			}
+			if !reflect.DeepEqual(path, ids) {
+				t.Fatalf("Link parses into %q, expected %q", path, ids)
+			}
+		})
+	}
+}
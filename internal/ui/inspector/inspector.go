@@ -0,0 +1,391 @@
+// Package inspector provides a developer window that shows which cchat
+// interfaces the backend implements for the current session and server, a
+// live log of the container callbacks made by backends and the main loop
+// performance. It also serves pprof if enabled.
+package inspector
+
+import (
+	"encoding/json"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/replay"
+	"github.com/diamondburned/cchat-gtk/internal/ui/dialog"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/autoscroll"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// Target is what the inspector shows the interfaces of. Nil fields are shown
+// as not selected.
+type Target struct {
+	Path       []cchat.ID
+	Breadcrumb []string
+
+	Service cchat.Service
+	Session cchat.Session
+	Server  cchat.Server
+}
+
+// maxEvents is the number of events kept in the log.
+const maxEvents = 500
+
+var inspectorCSS = primitives.PrepareClassCSS("inspector", `
+	.inspector row { padding: 2px 8px; }
+	.inspector row.section { padding-top: 8px; }
+	.inspector row.missing { opacity: 0.5; }
+`)
+
+// SpawnWindow shows the inspector window. Current is called for the selected
+// session and server every time the interfaces are refreshed and every time
+// an event is filtered.
+func SpawnWindow(current func() Target) {
+	caps := newCapabilities(current)
+	events := newEvents(current)
+	perf := newPerformance()
+
+	stack, _ := gtk.StackNew()
+	stack.AddTitled(caps, "capabilities", "Interfaces")
+	stack.AddTitled(events, "events", "Events")
+	stack.AddTitled(perf, "performance", "Performance")
+	stack.Show()
+
+	switcher, _ := gtk.StackSwitcherNew()
+	switcher.SetStack(stack)
+	switcher.Show()
+
+	refresh, _ := gtk.ButtonNewFromIconName("view-refresh-symbolic", gtk.ICON_SIZE_BUTTON)
+	refresh.SetTooltipText("Refresh")
+	refresh.Show()
+	refresh.Connect("clicked", func(*gtk.Button) { caps.refresh() })
+
+	header, _ := gtk.HeaderBarNew()
+	header.SetShowCloseButton(true)
+	header.SetCustomTitle(switcher)
+	header.PackStart(refresh)
+	header.PackEnd(events.Controls)
+	header.Show()
+
+	d := dialog.NewCSD(stack, header)
+	d.SetDefaultSize(500, 550)
+	d.SetTitle("Inspector")
+
+	var closed bool
+
+	remove := replay.Default.AddHandler(func(call, entry replay.Entry) {
+		gts.ExecAsync(func() {
+			// Events may still be queued after the window is closed.
+			if !closed {
+				events.add(call, entry)
+			}
+		})
+	})
+
+	d.Connect("destroy", func(interface{}) {
+		closed = true
+		remove()
+		perf.stop()
+	})
+
+	d.Show()
+}
+
+// capability is a single interface and whether or not it's implemented.
+type capability struct {
+	name  string
+	depth int
+	has   bool
+}
+
+type capabilitySection struct {
+	title string
+	caps  []capability
+}
+
+func (s *capabilitySection) add(depth int, name string, has bool) bool {
+	s.caps = append(s.caps, capability{name, depth, has})
+	return has
+}
+
+// listCapabilities lists the interfaces of everything in the target.
+func listCapabilities(t Target) []capabilitySection {
+	var sections []capabilitySection
+
+	if t.Service != nil {
+		var s = capabilitySection{title: "Service " + t.Service.Name().Content}
+		s.add(0, "AsIconer", t.Service.AsIconer() != nil)
+		s.add(0, "AsConfigurator", t.Service.AsConfigurator() != nil)
+		s.add(0, "AsSessionRestorer", t.Service.AsSessionRestorer() != nil)
+		sections = append(sections, s)
+	}
+
+	if t.Session != nil {
+		var s = capabilitySection{
+			title: "Session " + t.Session.Name().Content + " (" + t.Session.ID() + ")",
+		}
+		s.add(0, "AsIconer", t.Session.AsIconer() != nil)
+		addCommander(&s, t.Session.AsCommander())
+		s.add(0, "AsSessionSaver", t.Session.AsSessionSaver() != nil)
+		sections = append(sections, s)
+	}
+
+	if t.Server != nil {
+		var s = capabilitySection{title: "Server " + strings.Join(t.Breadcrumb, " / ")}
+		s.add(0, "AsIconer", t.Server.AsIconer() != nil)
+		s.add(0, "AsLister", t.Server.AsLister() != nil)
+		addCommander(&s, t.Server.AsCommander())
+		s.add(0, "AsConfigurator", t.Server.AsConfigurator() != nil)
+
+		if messenger := t.Server.AsMessenger(); s.add(0, "AsMessenger", messenger != nil) {
+			if sender := messenger.AsSender(); s.add(1, "AsSender", sender != nil) {
+				s.add(2, "CanAttach", sender.CanAttach())
+				s.add(2, "AsCompleter", sender.AsCompleter() != nil)
+			}
+			s.add(1, "AsEditor", messenger.AsEditor() != nil)
+			s.add(1, "AsActioner", messenger.AsActioner() != nil)
+			s.add(1, "AsNicknamer", messenger.AsNicknamer() != nil)
+			s.add(1, "AsBacklogger", messenger.AsBacklogger() != nil)
+			s.add(1, "AsMemberLister", messenger.AsMemberLister() != nil)
+			s.add(1, "AsUnreadIndicator", messenger.AsUnreadIndicator() != nil)
+			s.add(1, "AsTypingIndicator", messenger.AsTypingIndicator() != nil)
+		}
+
+		sections = append(sections, s)
+	}
+
+	return sections
+}
+
+func addCommander(s *capabilitySection, cmder cchat.Commander) {
+	if s.add(0, "AsCommander", cmder != nil) {
+		s.add(1, "AsCompleter", cmder.AsCompleter() != nil)
+	}
+}
+
+type capabilities struct {
+	*gtk.ScrolledWindow
+	list    *gtk.ListBox
+	current func() Target
+}
+
+func newCapabilities(current func() Target) *capabilities {
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	list.Show()
+	inspectorCSS(list)
+
+	placeholder, _ := gtk.LabelNew("No server selected.")
+	placeholder.SetMarginTop(16)
+	placeholder.Show()
+	list.SetPlaceholder(placeholder)
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scroll.Add(list)
+	scroll.Show()
+
+	c := &capabilities{scroll, list, current}
+	c.refresh()
+
+	return c
+}
+
+func (c *capabilities) refresh() {
+	primitives.DestroyChildren(c.list)
+
+	for _, section := range listCapabilities(c.current()) {
+		title, _ := gtk.LabelNew("")
+		title.SetMarkup("<b>" + html.EscapeString(section.title) + "</b>")
+		title.SetXAlign(0)
+		title.SetEllipsize(pango.ELLIPSIZE_END)
+		title.Show()
+
+		row, _ := gtk.ListBoxRowNew()
+		row.SetActivatable(false)
+		row.Add(title)
+		row.Show()
+		primitives.AddClass(row, "section")
+		c.list.Add(row)
+
+		for _, capability := range section.caps {
+			c.list.Add(newCapabilityRow(capability))
+		}
+	}
+}
+
+func newCapabilityRow(c capability) *gtk.ListBoxRow {
+	name, _ := gtk.LabelNew(c.name)
+	name.SetXAlign(0)
+	name.SetHExpand(true)
+	name.SetMarginStart(c.depth * 16)
+	name.Show()
+
+	var icon = "window-close-symbolic"
+	if c.has {
+		icon = "object-select-symbolic"
+	}
+
+	img, _ := gtk.ImageNewFromIconName(icon, gtk.ICON_SIZE_BUTTON)
+	img.Show()
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+	box.PackStart(name, true, true, 0)
+	box.PackStart(img, false, false, 0)
+	box.Show()
+
+	row, _ := gtk.ListBoxRowNew()
+	row.SetActivatable(false)
+	row.Add(box)
+	row.Show()
+
+	if !c.has {
+		primitives.AddClass(row, "missing")
+	}
+
+	return row
+}
+
+type events struct {
+	*autoscroll.ScrolledWindow
+	Controls *gtk.Box
+
+	list    *gtk.ListBox
+	current func() Target
+
+	serverOnly *gtk.CheckButton
+	pause      *gtk.ToggleButton
+}
+
+func newEvents(current func() Target) *events {
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	list.Show()
+	inspectorCSS(list)
+
+	placeholder, _ := gtk.LabelNew("No events yet.")
+	placeholder.SetMarginTop(16)
+	placeholder.Show()
+	list.SetPlaceholder(placeholder)
+
+	scroll := autoscroll.NewScrolledWindow()
+	scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scroll.Add(list)
+	scroll.Show()
+
+	serverOnly, _ := gtk.CheckButtonNewWithLabel("Server Only")
+	serverOnly.SetTooltipText("Only show events of the current server")
+	serverOnly.Show()
+
+	pause, _ := gtk.ToggleButtonNew()
+	pause.SetImage(primitives.NewButtonIcon("media-playback-pause-symbolic"))
+	pause.SetTooltipText("Pause")
+	pause.Show()
+
+	clear, _ := gtk.ButtonNewFromIconName("edit-clear-all-symbolic", gtk.ICON_SIZE_BUTTON)
+	clear.SetTooltipText("Clear")
+	clear.Show()
+	clear.Connect("clicked", func(*gtk.Button) { primitives.DestroyChildren(list) })
+
+	controls, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+	controls.PackStart(serverOnly, false, false, 0)
+	controls.PackStart(pause, false, false, 0)
+	controls.PackStart(clear, false, false, 0)
+	controls.Show()
+
+	return &events{
+		ScrolledWindow: scroll,
+		Controls:       controls,
+		list:           list,
+		current:        current,
+		serverOnly:     serverOnly,
+		pause:          pause,
+	}
+}
+
+func (e *events) add(call, entry replay.Entry) {
+	if e.pause.GetActive() {
+		return
+	}
+
+	if e.serverOnly.GetActive() && !hasPrefix(call.Path, e.current().Path) {
+		return
+	}
+
+	var name = call.Kind
+	if entry.Event != "" {
+		name += " " + entry.Event
+	}
+
+	var markup = rich.Small(time.Now().Format("15:04:05.000")) + " " +
+		"<b>" + html.EscapeString(name) + "</b> " +
+		rich.Small(html.EscapeString(strings.Join(call.Path, " / ")))
+
+	if preview := previewEntry(entry); preview != "" {
+		markup += "\n<tt>" + html.EscapeString(preview) + "</tt>"
+	}
+
+	l, _ := gtk.LabelNew("")
+	l.SetMarkup(markup)
+	l.SetXAlign(0)
+	l.SetEllipsize(pango.ELLIPSIZE_END)
+	l.SetSelectable(true)
+	l.Show()
+
+	e.list.Add(l)
+
+	if primitives.ChildrenLen(e.list) > maxEvents {
+		if row := e.list.GetRowAtIndex(0); row != nil {
+			row.Destroy()
+		}
+	}
+}
+
+// previewLen is the maximum length of an event's payload preview.
+const previewLen = 200
+
+// previewEntry returns the payload of the entry as JSON without the fields
+// that are already shown.
+func previewEntry(entry replay.Entry) string {
+	entry.Time = 0
+	entry.Call = 0
+	entry.Kind = ""
+	entry.Event = ""
+	entry.Path = nil
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return ""
+	}
+
+	// Time and call are always marshaled, so cut them out.
+	var preview = strings.TrimPrefix(string(b), `{"time":0,"call":0`)
+	if preview == "}" {
+		return ""
+	}
+	preview = "{" + strings.TrimPrefix(preview, ",")
+
+	if runes := []rune(preview); len(runes) > previewLen {
+		preview = string(runes[:previewLen]) + "…"
+	}
+
+	return preview
+}
+
+func hasPrefix(path, prefix []cchat.ID) bool {
+	if len(prefix) == 0 || len(path) < len(prefix) {
+		return false
+	}
+
+	for i, id := range prefix {
+		if path[i] != id {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,152 @@
+package inspector
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/gts/httputil"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// perfInterval is how often the performance page is refreshed.
+const perfInterval = time.Second
+
+// performance is the page that shows the main loop callback statistics.
+type performance struct {
+	*gtk.ScrolledWindow
+	box  *gtk.Box
+	stop func()
+}
+
+func newPerformance() *performance {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 4)
+	box.SetMarginStart(12)
+	box.SetMarginEnd(12)
+	box.SetMarginTop(8)
+	box.SetMarginBottom(8)
+	box.Show()
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scroll.Add(box)
+	scroll.Show()
+
+	p := &performance{ScrolledWindow: scroll, box: box}
+	p.refresh()
+	p.stop = gts.AfterFunc(perfInterval, p.refresh)
+
+	return p
+}
+
+func (p *performance) refresh() {
+	primitives.DestroyChildren(p.box)
+
+	var stats = gts.Perf()
+
+	p.addHistogram("Run Time", "How long callbacks block the main loop.", stats.Run)
+	p.addHistogram("Queue Time", "How long callbacks wait before they run.", stats.Wait)
+
+	var mem = httputil.Memory()
+
+	var budget = "unlimited"
+	if mem.Budget > 0 {
+		budget = formatBytes(mem.Budget)
+	}
+
+	p.addHeading("Image Memory", "Decoded images held by widgets.")
+	p.addLabel(fmt.Sprintf(
+		"<small>%s of %s in %d images, %d released, %s cached</small>",
+		formatBytes(mem.Used), budget, mem.Images, mem.Released, formatBytes(mem.Cached),
+	))
+
+	p.addHeading("Slow Callbacks", fmt.Sprintf(
+		"Callers whose callbacks ran longer than %v.", gts.CallbackBudget,
+	))
+
+	if len(stats.Slow) == 0 {
+		p.addLabel("None.")
+	}
+
+	for _, slow := range stats.Slow {
+		p.addLabel(fmt.Sprintf(
+			"<tt>%s</tt> %d× (max %v)",
+			html.EscapeString(slow.Site), slow.Count, slow.Max.Round(time.Millisecond),
+		))
+	}
+}
+
+func (p *performance) addHeading(title, desc string) {
+	p.addLabel(fmt.Sprintf(
+		"<b>%s</b>\n<small>%s</small>", html.EscapeString(title), html.EscapeString(desc),
+	))
+}
+
+func (p *performance) addLabel(markup string) {
+	l, _ := gtk.LabelNew("")
+	l.SetMarkup(markup)
+	l.SetXAlign(0)
+	l.SetEllipsize(pango.ELLIPSIZE_MIDDLE)
+	l.SetSelectable(true)
+	l.Show()
+
+	p.box.PackStart(l, false, false, 0)
+}
+
+func (p *performance) addHistogram(title, desc string, h gts.Histogram) {
+	p.addHeading(title, desc)
+
+	var mean time.Duration
+	if h.Total > 0 {
+		mean = h.Sum / time.Duration(h.Total)
+	}
+
+	p.addLabel(fmt.Sprintf(
+		"<small>%d callbacks, mean %v, max %v</small>",
+		h.Total, mean.Round(time.Microsecond), h.Max.Round(time.Microsecond),
+	))
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(2)
+	grid.SetColumnSpacing(8)
+	grid.SetMarginBottom(8)
+	grid.Show()
+
+	for i, count := range h.Counts {
+		var bucket string
+		if i < len(gts.LatencyBuckets) {
+			bucket = "≤ " + gts.LatencyBuckets[i].String()
+		} else {
+			bucket = "> " + gts.LatencyBuckets[i-1].String()
+		}
+
+		name, _ := gtk.LabelNew(bucket)
+		name.SetXAlign(1)
+		name.Show()
+
+		bar, _ := gtk.LevelBarNew()
+		bar.SetHExpand(true)
+		bar.SetVAlign(gtk.ALIGN_CENTER)
+		if h.Total > 0 {
+			bar.SetValue(float64(count) / float64(h.Total))
+		}
+		bar.Show()
+
+		value, _ := gtk.LabelNew(fmt.Sprint(count))
+		value.SetXAlign(0)
+		value.Show()
+
+		grid.Attach(name, 0, i, 1, 1)
+		grid.Attach(bar, 1, i, 1, 1)
+		grid.Attach(value, 2, i, 1, 1)
+	}
+
+	p.box.PackStart(grid, false, false, 0)
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+}
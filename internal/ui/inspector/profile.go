@@ -0,0 +1,100 @@
+package inspector
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ProfilingEnabled is true if the pprof endpoint is served.
+	ProfilingEnabled bool
+	// ProfilingAddress is the loopback address of the pprof endpoint. Changes
+	// take effect when the endpoint is enabled again.
+	ProfilingAddress = "localhost:6060"
+)
+
+func init() {
+	config.DeveloperAdd("Profiling Address", config.InputEntry(&ProfilingAddress, checkLoopback))
+	config.DeveloperAdd("Enable Profiling Endpoint", config.Switch(&ProfilingEnabled, func(on bool) {
+		if !on {
+			stopProfiling()
+			return
+		}
+
+		if err := startProfiling(); err != nil {
+			log.Error(errors.Wrap(err, "Failed to start profiling endpoint"))
+		}
+	}))
+}
+
+var profiling struct {
+	sync.Mutex
+	server *http.Server
+}
+
+// checkLoopback returns an error if the address isn't on a loopback interface,
+// since the profiles shouldn't be reachable from the network.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return errors.New("Address must be on localhost")
+	}
+
+	return nil
+}
+
+// startProfiling serves pprof on ProfilingAddress under /debug/pprof/.
+func startProfiling() error {
+	if err := checkLoopback(ProfilingAddress); err != nil {
+		return err
+	}
+
+	profiling.Lock()
+	defer profiling.Unlock()
+
+	if profiling.server != nil {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", ProfilingAddress)
+	if err != nil {
+		return errors.Wrap(err, "Failed to listen")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	profiling.server = &http.Server{Handler: mux}
+	go profiling.server.Serve(l)
+
+	log.Printlnf("Profiling endpoint at http://%s/debug/pprof/", l.Addr())
+	return nil
+}
+
+func stopProfiling() {
+	profiling.Lock()
+	defer profiling.Unlock()
+
+	if profiling.server != nil {
+		profiling.server.Close()
+		profiling.server = nil
+	}
+}
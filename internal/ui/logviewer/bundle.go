@@ -0,0 +1,147 @@
+package logviewer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/pkg/errors"
+)
+
+// redacted replaces all strings in redacted config files.
+const redacted = "<redacted>"
+
+// bundledConfig returns true if the config file with the given name goes into
+// the diagnostic bundle. Secrets, bookmarks and mentions are left out.
+func bundledConfig(name string) bool {
+	return name == config.ConfigFile ||
+		(strings.HasPrefix(name, "service-") && strings.HasSuffix(name, ".json"))
+}
+
+// WriteBundle writes a zip file of the log files, the entries in memory and
+// the redacted config files into the given file path.
+func WriteBundle(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create bundle")
+	}
+	defer f.Close()
+
+	z := zip.NewWriter(f)
+
+	if err := writeBundle(z); err != nil {
+		return err
+	}
+
+	return errors.Wrap(z.Close(), "Failed to write bundle")
+}
+
+func writeBundle(z *zip.Writer) error {
+	w, err := z.Create("info.txt")
+	if err != nil {
+		return errors.Wrap(err, "Failed to add info")
+	}
+
+	fmt.Fprintf(w, "Time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(w, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	w, err = z.Create("memory.log")
+	if err != nil {
+		return errors.Wrap(err, "Failed to add entries")
+	}
+
+	for _, entry := range log.Entries() {
+		fmt.Fprintln(w, entry)
+	}
+
+	for _, path := range log.Files() {
+		if err := addFile(z, "logs/"+filepath.Base(path), path); err != nil {
+			return err
+		}
+	}
+
+	files, err := ioutil.ReadDir(config.DirPath())
+	if err != nil {
+		return errors.Wrap(err, "Failed to read config dir")
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !bundledConfig(file.Name()) {
+			continue
+		}
+
+		if err := addRedacted(z, file.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addFile(z *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "Failed to open log file")
+	}
+	defer f.Close()
+
+	w, err := z.Create(name)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to add %s", name)
+	}
+
+	_, err = io.Copy(w, f)
+	return errors.Wrapf(err, "Failed to add %s", name)
+}
+
+// addRedacted adds the config file with all its strings redacted, keeping only
+// the keys, booleans and numbers.
+func addRedacted(z *zip.Writer, name string) error {
+	b, err := ioutil.ReadFile(filepath.Join(config.DirPath(), name))
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read %s", name)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		// Don't risk adding a file that can't be redacted.
+		log.Warn(errors.Wrapf(err, "Skipping invalid config %s", name))
+		return nil
+	}
+
+	w, err := z.Create("config/" + name)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to add %s", name)
+	}
+
+	return errors.Wrapf(config.PrettyMarshal(w, redact(v)), "Failed to add %s", name)
+}
+
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return redacted
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = redact(v[k])
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,225 @@
+// Package logviewer provides a window that tails the log with level and text
+// filters, as well as a way to gather logs for bug reports.
+package logviewer
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/dialog"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/autoscroll"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+	"github.com/pkg/errors"
+)
+
+var logCSS = primitives.PrepareClassCSS("log-viewer", `
+	.log-viewer row { padding: 1px 8px; }
+	.log-viewer row.debug { opacity: 0.6; }
+	.log-viewer row.warn label { color: @warning_color; }
+	.log-viewer row.error label { color: @error_color; }
+`)
+
+// Viewer is the log window.
+type Viewer struct {
+	*dialog.Dialog
+	list   *gtk.ListBox
+	search *gtk.SearchEntry
+	level  *gtk.ComboBoxText
+	bundle *gtk.Button
+
+	minLevel log.Level
+	query    string
+	lastSeq  uint64 // sequence of the last shown entry
+}
+
+// SpawnWindow shows the log window.
+func SpawnWindow() {
+	var v *Viewer
+	var closed bool
+
+	// Add the handler before the entries in memory are shown, so that none are
+	// missed in between. Entries that are already shown are skipped.
+	remove := log.AddEntryHandler(func(entry log.Entry) {
+		gts.ExecAsync(func() {
+			if !closed && entry.Seq > v.lastSeq {
+				v.add(entry)
+			}
+		})
+	})
+
+	v = NewViewer()
+
+	v.Connect("destroy", func(interface{}) {
+		closed = true
+		remove()
+	})
+
+	v.Show()
+}
+
+func NewViewer() *Viewer {
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	list.Show()
+	logCSS(list)
+
+	placeholder, _ := gtk.LabelNew("No log entries.")
+	placeholder.SetMarginTop(16)
+	placeholder.Show()
+	list.SetPlaceholder(placeholder)
+
+	scroll := autoscroll.NewScrolledWindow()
+	scroll.SetPolicy(gtk.POLICY_AUTOMATIC, gtk.POLICY_AUTOMATIC)
+	scroll.Add(list)
+	scroll.Show()
+
+	search, _ := gtk.SearchEntryNew()
+	search.SetPlaceholderText("Search")
+	search.Show()
+
+	level, _ := gtk.ComboBoxTextNew()
+	for _, l := range log.Levels {
+		level.Append(strconv.Itoa(int(l)), l.String())
+	}
+	level.SetActiveID(strconv.Itoa(int(log.LevelInfo)))
+	level.SetTooltipText("Minimum Level")
+	level.Show()
+
+	bundle, _ := gtk.ButtonNewFromIconName("edit-copy-symbolic", gtk.ICON_SIZE_BUTTON)
+	bundle.SetTooltipText("Copy Diagnostic Bundle")
+	bundle.Show()
+
+	header, _ := gtk.HeaderBarNew()
+	header.SetShowCloseButton(true)
+	header.SetCustomTitle(search)
+	header.PackStart(level)
+	header.PackEnd(bundle)
+	header.Show()
+
+	d := dialog.NewCSD(scroll, header)
+	d.SetDefaultSize(700, 500)
+	d.SetTitle("Logs")
+
+	v := &Viewer{
+		Dialog:   d,
+		list:     list,
+		search:   search,
+		level:    level,
+		bundle:   bundle,
+		minLevel: log.LevelInfo,
+	}
+
+	search.Connect("search-changed", func(search *gtk.SearchEntry) {
+		text, _ := search.GetText()
+		v.query = strings.ToLower(text)
+		v.refresh()
+	})
+
+	level.Connect("changed", func(level *gtk.ComboBoxText) {
+		n, _ := strconv.Atoi(level.GetActiveID())
+		v.minLevel = log.Level(n)
+		v.refresh()
+	})
+
+	bundle.Connect("clicked", func(*gtk.Button) { v.copyBundle() })
+
+	v.refresh()
+
+	return v
+}
+
+// refresh shows the entries in memory again with the current filters.
+func (v *Viewer) refresh() {
+	primitives.DestroyChildren(v.list)
+
+	for _, entry := range log.Entries() {
+		v.add(entry)
+	}
+}
+
+func (v *Viewer) add(entry log.Entry) {
+	v.lastSeq = entry.Seq
+
+	if entry.Level < v.minLevel {
+		return
+	}
+
+	if v.query != "" && !strings.Contains(strings.ToLower(entry.Msg), v.query) {
+		return
+	}
+
+	l, _ := gtk.LabelNew("")
+	l.SetMarkup(
+		"<tt>" + entry.Time.Format("15:04:05") + " " +
+			html.EscapeString(entry.Level.String()) + "</tt> " +
+			html.EscapeString(entry.Msg),
+	)
+	l.SetXAlign(0)
+	l.SetLineWrap(true)
+	l.SetLineWrapMode(pango.WRAP_WORD_CHAR)
+	l.SetSelectable(true)
+	l.Show()
+
+	row, _ := gtk.ListBoxRowNew()
+	row.SetActivatable(false)
+	row.Add(l)
+	row.Show()
+	primitives.AddClass(row, strings.ToLower(entry.Level.String()))
+
+	v.list.Add(row)
+
+	if primitives.ChildrenLen(v.list) > log.MaxEntries {
+		if first := v.list.GetRowAtIndex(0); first != nil {
+			first.Destroy()
+		}
+	}
+}
+
+// copyBundle writes the diagnostic bundle into the cache directory and copies
+// its path.
+func (v *Viewer) copyBundle() {
+	v.bundle.SetSensitive(false)
+
+	go func() {
+		path, err := bundlePath()
+		if err == nil {
+			err = WriteBundle(path)
+		}
+
+		gts.ExecAsync(func() {
+			v.bundle.SetSensitive(true)
+
+			if err != nil {
+				log.Error(errors.Wrap(err, "Failed to make diagnostic bundle"))
+				return
+			}
+
+			gts.Clipboard.SetText(path)
+			v.bundle.SetTooltipText("Copied " + path)
+			log.Printlnf("Diagnostic bundle written to %s", path)
+		})
+	}()
+}
+
+func bundlePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to get cache dir")
+	}
+
+	dir = filepath.Join(dir, "cchat-gtk")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "Failed to make cache dir")
+	}
+
+	var name = "diagnostics-" + time.Now().Format("20060102-150405") + ".zip"
+	return filepath.Join(dir, name), nil
+}
@@ -0,0 +1,224 @@
+package messages
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+	"github.com/pkg/errors"
+)
+
+// backlogPrefetch is how many screens away from the top the user has to be for
+// older messages to be fetched.
+const backlogPrefetch = 2
+
+// backlogTimeout is the maximum time to wait for a page of older messages.
+const backlogTimeout = 10 * time.Second
+
+var backlogStatusCSS = primitives.PrepareClassCSS("backlog-status", `
+	.backlog-status {
+		margin: 8px 0;
+		color: alpha(@theme_fg_color, 0.6);
+	}
+`)
+
+// backlogStatus is the row above the messages that shows whether older
+// messages are being loaded, have failed to load or have all been loaded.
+type backlogStatus struct {
+	*gtk.Box
+	spinner *gtk.Spinner
+	label   *gtk.Label
+	retry   *gtk.Button
+}
+
+func newBacklogStatus(retry func()) *backlogStatus {
+	spinner, _ := gtk.SpinnerNew()
+
+	label, _ := gtk.LabelNew("")
+	label.SetEllipsize(pango.ELLIPSIZE_END)
+
+	button, _ := gtk.ButtonNewWithLabel("Retry")
+	button.SetRelief(gtk.RELIEF_NONE)
+	button.Connect("clicked", func(*gtk.Button) { retry() })
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 6)
+	box.SetHAlign(gtk.ALIGN_CENTER)
+	box.PackStart(spinner, false, false, 0)
+	box.PackStart(label, false, false, 0)
+	box.PackStart(button, false, false, 0)
+	backlogStatusCSS(box)
+
+	return &backlogStatus{
+		Box:     box,
+		spinner: spinner,
+		label:   label,
+		retry:   button,
+	}
+}
+
+// Reset hides the status.
+func (s *backlogStatus) Reset() {
+	s.spinner.Stop()
+	s.Hide()
+}
+
+// SetLoading shows a spinner.
+func (s *backlogStatus) SetLoading() {
+	s.spinner.Start()
+	s.spinner.Show()
+	s.label.Hide()
+	s.retry.Hide()
+	s.Show()
+}
+
+// SetEnd shows the beginning of the channel marker.
+func (s *backlogStatus) SetEnd() {
+	s.spinner.Stop()
+	s.spinner.Hide()
+	s.label.SetMarkup("<small>This is the beginning of the channel.</small>")
+	s.label.SetTooltipText("")
+	s.label.Show()
+	s.retry.Hide()
+	s.Show()
+}
+
+// SetError shows the error with a retry button.
+func (s *backlogStatus) SetError(err error) {
+	s.spinner.Stop()
+	s.spinner.Hide()
+	s.label.SetMarkup("<small>Failed to load older messages.</small>")
+	s.label.SetTooltipText(err.Error())
+	s.label.Show()
+	s.retry.Show()
+	s.Show()
+}
+
+// prefetchBacklog fetches older messages if the user has scrolled close enough
+// to the top.
+func (v *View) prefetchBacklog() {
+	// Don't prefetch while bottomed, since the container would clean up the
+	// older messages right away.
+	if v.Scroller.Bottomed {
+		return
+	}
+
+	var adj = v.Scroller.GetVAdjustment()
+
+	if adj.GetValue() < adj.GetPageSize()*backlogPrefetch {
+		v.FetchBacklog()
+	}
+}
+
+// FetchBacklog fetches the page of messages before the first one. It does
+// nothing if a page is already being fetched.
+func (v *View) FetchBacklog() {
+	var backlogger = v.state.Backlogger()
+	if backlogger == nil {
+		return
+	}
+
+	var firstMsg = v.Container.FirstMessage()
+	if firstMsg == nil {
+		return
+	}
+
+	v.state.backlogging = true
+	v.BacklogStatus.SetLoading()
+
+	// Keep the messages the user is reading in place while older ones are
+	// inserted above.
+	v.Scroller.Anchor()
+
+	var generation = v.state.generation
+	var firstID = firstMsg.ID()
+	var list = v.Container
+	var msgc = &backlogTracker{MessagesContainer: v.state.events(list)}
+
+	// Continue from the oldest message fetched so far if the filters hid it.
+	if oldest := v.state.backlogOldest; oldest != nil && oldest.Time().Before(firstMsg.Time()) {
+		firstID = oldest.ID()
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), backlogTimeout)
+		defer cancel()
+
+		err := backlogger.Backlog(ctx, firstID, msgc)
+
+		// Queue this after the fetched messages, so it runs after they're
+		// inserted.
+		list.Queue(func() {
+			// Don't touch the new server if the user has switched away.
+			if v.state.stale(generation) {
+				return
+			}
+
+			v.state.backlogging = false
+
+			// Release the anchor only after the new rows are allocated.
+			gts.ExecLater(v.Scroller.Unanchor)
+
+			if err != nil {
+				log.Error(errors.Wrap(err, "Failed to get messages before ID"))
+				v.state.backlogFailed = true
+				v.BacklogStatus.SetError(err)
+				return
+			}
+
+			// Nothing older came in, so we're at the beginning. The list
+			// isn't checked, since the filters may hide the whole page.
+			oldest := msgc.Oldest()
+			if oldest == nil || oldest.ID() == firstID {
+				v.state.backlogEnd = true
+				v.BacklogStatus.SetEnd()
+				return
+			}
+
+			v.state.backlogOldest = oldest
+			v.BacklogStatus.Reset()
+
+			// Keep going if the user is still close to the top.
+			gts.ExecLater(v.prefetchBacklog)
+		})
+	}()
+}
+
+// backlogTracker is a messages container that remembers the oldest message
+// that a backlog delivers, whether or not it's shown.
+type backlogTracker struct {
+	cchat.MessagesContainer
+
+	mutex  sync.Mutex
+	oldest cchat.MessageHeader
+}
+
+func (c *backlogTracker) CreateMessage(msg cchat.MessageCreate) {
+	c.mutex.Lock()
+	if c.oldest == nil || msg.Time().Before(c.oldest.Time()) {
+		c.oldest = msg
+	}
+	c.mutex.Unlock()
+
+	c.MessagesContainer.CreateMessage(msg)
+}
+
+// Oldest returns the oldest message delivered, or nil if there's none.
+func (c *backlogTracker) Oldest() cchat.MessageHeader {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.oldest
+}
+
+// retryBacklog clears the error and fetches the older messages again.
+func (v *View) retryBacklog() {
+	v.state.backlogFailed = false
+	v.BacklogStatus.Reset()
+	v.FetchBacklog()
+}
@@ -0,0 +1,88 @@
+package messages
+
+import (
+	"context"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/bookmark"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container"
+	"github.com/pkg/errors"
+)
+
+// seekMaxBacklogs is the maximum number of backlog pages to fetch when looking
+// for a message.
+const seekMaxBacklogs = 5
+
+// addBookmark bookmarks the given message in the current server.
+func (v *View) addBookmark(msg container.MessageRow) {
+	var author = msg.Author()
+
+	bookmark.Add(bookmark.Bookmark{
+		Path:       v.state.path,
+		Breadcrumb: v.state.breadcrumb,
+		MessageID:  msg.ID(),
+		AuthorID:   author.ID(),
+		Author:     author.Name().String(),
+		Content:    msg.RichContent().Content,
+		Time:       msg.Time(),
+	})
+}
+
+// SeekMessage scrolls to and highlights the message with the given ID. If the
+// server is still being joined, then the message is sought after joining.
+// Older messages are fetched if the message is not loaded yet.
+func (v *View) SeekMessage(msgID cchat.ID) {
+	if msgID == "" {
+		return
+	}
+
+	// The current callback is only set after joining.
+	if v.state.current == nil {
+		v.seekID = msgID
+		return
+	}
+
+	v.seekMessage(msgID, seekMaxBacklogs)
+}
+
+func (v *View) seekMessage(msgID cchat.ID, tries int) {
+	if msg := v.Container.Message(msgID, ""); msg != nil {
+		v.Container.Highlight(msg)
+		return
+	}
+
+	var backlogger = v.state.backlogger
+	if backlogger == nil || tries <= 0 {
+		return
+	}
+
+	var firstMsg = v.Container.FirstMessage()
+	if firstMsg == nil {
+		return
+	}
+
+	var generation = v.state.generation
+	var list = v.Container
+	var msgc = v.state.events(list)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		if err := backlogger.Backlog(ctx, firstMsg.ID(), msgc); err != nil {
+			log.Error(errors.Wrap(err, "Failed to get messages before ID"))
+			return
+		}
+
+		// Queue this after the fetched messages, so it runs after they're
+		// inserted.
+		list.Queue(func() {
+			// Stop if the user has moved somewhere else.
+			if !v.state.stale(generation) {
+				v.seekMessage(msgID, tries-1)
+			}
+		})
+	}()
+}
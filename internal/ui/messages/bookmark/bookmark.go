@@ -0,0 +1,165 @@
+// Package bookmark provides personal bookmarks of messages across all sessions.
+// Bookmarks keep a snapshot of the message, so they can be read even if the
+// original message is gone.
+package bookmark
+
+import (
+	"strings"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/pkg/errors"
+)
+
+// Bookmark is a single saved message.
+type Bookmark struct {
+	// Path is the list of IDs from the service down to the server, which is
+	// used to navigate back to the message.
+	Path []cchat.ID `json:"path"`
+	// Breadcrumb is the list of names from the service down to the server.
+	Breadcrumb []string `json:"breadcrumb"`
+
+	MessageID cchat.ID  `json:"message_id"`
+	AuthorID  cchat.ID  `json:"author_id"`
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	Time      time.Time `json:"time"`
+
+	Added time.Time `json:"added"`
+	Note  string    `json:"note,omitempty"`
+	Tags  []string  `json:"tags,omitempty"`
+}
+
+// Matches returns true if the bookmark matches the given search query. A query
+// starting with "#" only matches tags.
+func (b Bookmark) Matches(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+
+	if strings.HasPrefix(query, "#") {
+		tag := strings.TrimPrefix(query, "#")
+		for _, t := range b.Tags {
+			if strings.EqualFold(t, tag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, field := range []string{b.Author, b.Content, b.Note, strings.Join(b.Breadcrumb, " ")} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+
+	for _, t := range b.Tags {
+		if strings.Contains(strings.ToLower(t), query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const configName = "bookmarks.json"
+
+// bookmarks is the list of bookmarks from the oldest to the latest. It must
+// only be accessed in the main thread.
+var bookmarks []*Bookmark
+
+func init() {
+	config.RegisterConfig(configName, &bookmarks)
+}
+
+// All returns all bookmarks from the latest to the oldest. It must be called
+// in the main thread.
+func All() []*Bookmark {
+	var all = make([]*Bookmark, len(bookmarks))
+	for i, b := range bookmarks {
+		all[len(bookmarks)-1-i] = b
+	}
+	return all
+}
+
+// Add adds a bookmark, then saves the bookmarks. The snapshot is updated if
+// the message is already bookmarked. It must be called in the main thread.
+func Add(b Bookmark) {
+	if old := find(b.Path, b.MessageID); old != nil {
+		old.Author = b.Author
+		old.Content = b.Content
+		old.Breadcrumb = b.Breadcrumb
+	} else {
+		b.Added = time.Now()
+		bookmarks = append(bookmarks, &b)
+	}
+
+	Save()
+}
+
+// Remove removes the given bookmark, then saves the bookmarks.
+func Remove(b *Bookmark) {
+	for i, bookmark := range bookmarks {
+		if bookmark == b {
+			bookmarks = append(bookmarks[:i], bookmarks[i+1:]...)
+			break
+		}
+	}
+
+	Save()
+}
+
+// IsBookmarked returns true if the message in the given path is bookmarked.
+func IsBookmarked(path []cchat.ID, msgID cchat.ID) bool {
+	return find(path, msgID) != nil
+}
+
+func find(path []cchat.ID, msgID cchat.ID) *Bookmark {
+	for _, b := range bookmarks {
+		if b.MessageID == msgID && pathEqual(b.Path, path) {
+			return b
+		}
+	}
+	return nil
+}
+
+func pathEqual(a, b []cchat.ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveDelay is the delay to wait before saving, which batches consecutive
+// changes such as typing in notes.
+const SaveDelay = time.Second
+
+var saving bool
+
+// Save saves the bookmarks after a delay. It is non-blocking and must be
+// called in the main thread.
+func Save() {
+	if saving {
+		return
+	}
+
+	saving = true
+
+	gts.DoAfter(SaveDelay, func() {
+		saving = false
+
+		// Marshal in the main thread to avoid race conditions.
+		if err := config.MarshalToFile(configName, bookmarks); err != nil {
+			log.Error(errors.Wrap(err, "Failed to save bookmarks"))
+		}
+	})
+}
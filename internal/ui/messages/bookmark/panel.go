@@ -0,0 +1,178 @@
+package bookmark
+
+import (
+	"html"
+	"strings"
+
+	"github.com/diamondburned/cchat-gtk/internal/humanize"
+	"github.com/diamondburned/cchat-gtk/internal/ui/dialog"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+var panelCSS = primitives.PrepareClassCSS("bookmarks", `
+	.bookmarks row { padding: 6px 8px; }
+	.bookmarks .bookmark-content { margin: 2px 0; }
+`)
+
+// SpawnPanel shows a dialog that lists all bookmarks. Open is called when the
+// user wants to go to the bookmarked message.
+func SpawnPanel(open func(*Bookmark)) {
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	list.Show()
+	panelCSS(list)
+
+	placeholder, _ := gtk.LabelNew("No bookmarks.")
+	placeholder.SetMarginTop(16)
+	placeholder.Show()
+	list.SetPlaceholder(placeholder)
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scroll.Add(list)
+	scroll.Show()
+
+	search, _ := gtk.SearchEntryNew()
+	search.SetPlaceholderText("Search or #tag")
+	search.Show()
+
+	header, _ := gtk.HeaderBarNew()
+	header.SetShowCloseButton(true)
+	header.SetTitle("Bookmarks")
+	header.PackStart(search)
+	header.Show()
+
+	d := dialog.NewCSD(scroll, header)
+	d.SetDefaultSize(450, 500)
+	d.SetTitle("Bookmarks")
+
+	var rows []*row
+
+	for _, b := range All() {
+		b := b
+
+		r := newRow(b)
+		r.open = func() {
+			d.Destroy()
+			open(b)
+		}
+		r.remove = func() {
+			Remove(b)
+			r.Destroy()
+		}
+
+		rows = append(rows, r)
+		list.Add(r)
+	}
+
+	search.Connect("search-changed", func(search *gtk.SearchEntry) {
+		query, _ := search.GetText()
+		for _, r := range rows {
+			r.SetVisible(r.bookmark.Matches(query))
+		}
+	})
+
+	d.Show()
+}
+
+type row struct {
+	*gtk.ListBoxRow
+	bookmark *Bookmark
+
+	open   func()
+	remove func()
+}
+
+func newRow(b *Bookmark) *row {
+	r := &row{bookmark: b}
+
+	title, _ := gtk.LabelNew("")
+	title.SetMarkup(
+		"<b>" + html.EscapeString(b.Author) + "</b> " +
+			rich.Small(html.EscapeString(humanize.TimeAgo(b.Time))),
+	)
+	title.SetXAlign(0)
+	title.SetEllipsize(pango.ELLIPSIZE_END)
+	title.SetHExpand(true)
+	title.Show()
+
+	crumb, _ := gtk.LabelNew("")
+	crumb.SetMarkup(rich.Small(html.EscapeString(strings.Join(b.Breadcrumb, " / "))))
+	crumb.SetXAlign(0)
+	crumb.SetEllipsize(pango.ELLIPSIZE_MIDDLE)
+	crumb.Show()
+
+	content, _ := gtk.LabelNew(b.Content)
+	content.SetXAlign(0)
+	content.SetLineWrap(true)
+	content.SetLineWrapMode(pango.WRAP_WORD_CHAR)
+	content.SetEllipsize(pango.ELLIPSIZE_END)
+	content.SetLines(4)
+	content.SetSelectable(true)
+	content.Show()
+	primitives.AddClass(content, "bookmark-content")
+
+	note, _ := gtk.EntryNew()
+	note.SetPlaceholderText("Note")
+	note.SetText(b.Note)
+	note.Show()
+	note.Connect("changed", func(note *gtk.Entry) {
+		b.Note, _ = note.GetText()
+		Save()
+	})
+
+	tags, _ := gtk.EntryNew()
+	tags.SetPlaceholderText("Tags, comma-separated")
+	tags.SetText(strings.Join(b.Tags, ", "))
+	tags.Show()
+	tags.Connect("changed", func(tags *gtk.Entry) {
+		text, _ := tags.GetText()
+		b.Tags = splitTags(text)
+		Save()
+	})
+
+	goTo, _ := gtk.ButtonNewFromIconName("go-jump-symbolic", gtk.ICON_SIZE_BUTTON)
+	goTo.SetTooltipText("Go to Message")
+	goTo.SetRelief(gtk.RELIEF_NONE)
+	goTo.Show()
+	goTo.Connect("clicked", func(*gtk.Button) { r.open() })
+
+	remove, _ := gtk.ButtonNewFromIconName("user-trash-symbolic", gtk.ICON_SIZE_BUTTON)
+	remove.SetTooltipText("Remove Bookmark")
+	remove.SetRelief(gtk.RELIEF_NONE)
+	remove.Show()
+	remove.Connect("clicked", func(*gtk.Button) { r.remove() })
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(2)
+	grid.SetColumnSpacing(4)
+	grid.Attach(title, 0, 0, 1, 1)
+	grid.Attach(goTo, 1, 0, 1, 1)
+	grid.Attach(remove, 2, 0, 1, 1)
+	grid.Attach(crumb, 0, 1, 3, 1)
+	grid.Attach(content, 0, 2, 3, 1)
+	grid.Attach(note, 0, 3, 3, 1)
+	grid.Attach(tags, 0, 4, 3, 1)
+	grid.Show()
+
+	r.ListBoxRow, _ = gtk.ListBoxRowNew()
+	r.ListBoxRow.SetActivatable(false)
+	r.ListBoxRow.Add(grid)
+	r.ListBoxRow.Show()
+
+	return r
+}
+
+// splitTags splits the comma-separated tags, trimming spaces and empty tags.
+func splitTags(text string) []string {
+	var tags []string
+	for _, tag := range strings.Split(text, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
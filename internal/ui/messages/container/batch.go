@@ -0,0 +1,107 @@
+package container
+
+import (
+	"sync"
+	"time"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+)
+
+// FrameBudget is how long queued message events may block the main loop at
+// once. The rest are run in the next loop iteration, after a redraw.
+var FrameBudget = 8 * time.Millisecond
+
+// batch coalesces message events that arrive in bursts, such as backlogs, into
+// as few main loop callbacks as possible. Events and callbacks are run in the
+// order they're added. A zero-value instance is valid.
+type batch struct {
+	mutex     sync.Mutex
+	queue     []batchItem
+	scheduled bool
+}
+
+type batchItem struct {
+	fn    func()
+	event bool // dropped on Clear
+}
+
+// Add queues the callback, which always runs. It is thread-safe.
+func (b *batch) Add(fn func()) {
+	b.add(batchItem{fn, false})
+}
+
+// AddEvent queues the message event, which is dropped if the batch is cleared
+// before it runs. It is thread-safe.
+func (b *batch) AddEvent(fn func()) {
+	b.add(batchItem{fn, true})
+}
+
+func (b *batch) add(item batchItem) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.queue = append(b.queue, item)
+
+	// Events added before the callback runs will go into the same frame.
+	if !b.scheduled {
+		b.scheduled = true
+		gts.ExecLater(b.flush)
+	}
+}
+
+// Clear drops the queued message events. Callbacks are kept, since they may
+// have to clean up after themselves.
+func (b *batch) Clear() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var kept = b.queue[:0]
+	for _, item := range b.queue {
+		if !item.event {
+			kept = append(kept, item)
+		}
+	}
+
+	// Clear the rest for the garbage collector.
+	for i := len(kept); i < len(b.queue); i++ {
+		b.queue[i] = batchItem{}
+	}
+
+	b.queue = kept
+}
+
+func (b *batch) pop() (func(), bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.queue) == 0 {
+		b.queue = nil
+		b.scheduled = false
+		return nil, false
+	}
+
+	fn := b.queue[0].fn
+	b.queue[0] = batchItem{}
+	b.queue = b.queue[1:]
+
+	return fn, true
+}
+
+func (b *batch) flush() {
+	var start = time.Now()
+
+	for {
+		fn, ok := b.pop()
+		if !ok {
+			return
+		}
+
+		fn()
+
+		// Let Gtk draw the rows inserted so far before continuing.
+		if time.Since(start) > FrameBudget {
+			gts.ExecLater(b.flush)
+			return
+		}
+	}
+}
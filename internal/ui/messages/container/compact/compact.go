@@ -2,9 +2,9 @@ package compact
 
 import (
 	"github.com/diamondburned/cchat"
-	"github.com/diamondburned/cchat-gtk/internal/gts"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/input"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/message"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 )
 
@@ -19,18 +19,21 @@ func NewContainer(ctrl container.Controller) *Container {
 }
 
 func (c *Container) CreateMessage(msg cchat.MessageCreate) {
-	gts.ExecAsync(func() {
+	// Render the content here rather than in the main loop.
+	msg = message.Prerender(msg)
+
+	c.QueueEvent(func() {
 		c.ListContainer.CreateMessageUnsafe(msg)
 		c.ListContainer.CleanMessages()
 	})
 }
 
 func (c *Container) UpdateMessage(msg cchat.MessageUpdate) {
-	gts.ExecAsync(func() { c.ListContainer.UpdateMessageUnsafe(msg) })
+	c.QueueEvent(func() { c.ListContainer.UpdateMessageUnsafe(msg) })
 }
 
 func (c *Container) DeleteMessage(msg cchat.MessageDelete) {
-	gts.ExecAsync(func() { c.ListContainer.DeleteMessageUnsafe(msg) })
+	c.QueueEvent(func() { c.ListContainer.DeleteMessageUnsafe(msg) })
 }
 
 var constructors = container.Constructor{
@@ -72,6 +72,7 @@ func wrapMessage(ct *message.GenericContainer) Message {
 	user.SetEllipsize(pango.ELLIPSIZE_NONE)
 	user.SetLineWrap(true)
 	user.SetLineWrapMode(pango.WRAP_WORD_CHAR)
+	user.SetPopoverExtender(ct.ExtendAuthorPopover)
 	user.Show()
 	messageAuthorCSS(user)
 
@@ -1,12 +1,15 @@
 package container
 
 import (
+	"time"
+
 	"github.com/diamondburned/cchat"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/input"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/message"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/menu"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich/labeluri"
+	"github.com/diamondburned/cchat/text"
 	"github.com/diamondburned/handy"
 	"github.com/gotk3/gotk3/gtk"
 )
@@ -25,6 +28,26 @@ type MessageRow interface {
 	MenuItems() []menu.Item
 	// SetReferenceHighlighter sets the reference highlighter into the message.
 	SetReferenceHighlighter(refer labeluri.ReferenceHighlighter)
+	// SetAuthorPopoverExtender sets the callback that extends popovers of the
+	// message's author.
+	SetAuthorPopoverExtender(ext labeluri.PopoverExtender)
+	// SetContentHidden hides the content behind a clickable stub.
+	SetContentHidden(hidden bool)
+	// SetDimmed fades the message out.
+	SetDimmed(dimmed bool)
+	// EditContent updates the content and records the edit history.
+	EditContent(content text.Rich, t time.Time)
+	// RerenderContent renders the content again.
+	RerenderContent()
+	// SetDeleted marks the message as deleted at the given time.
+	SetDeleted(t time.Time)
+	// Deleted returns true if the message is marked as deleted.
+	Deleted() bool
+	// RichContent returns the message's current content.
+	RichContent() text.Rich
+	// SetAnnotation sets a local note below the content, or removes it if the
+	// note is empty.
+	SetAnnotation(annotation string)
 }
 
 type PresendMessageRow interface {
@@ -37,11 +60,13 @@ type PresendMessageRow interface {
 type Container interface {
 	gtk.IWidget
 
-	// Reset resets the message container to its original state.
+	// Reset resets the message container to its original state. Queued
+	// message events are dropped, but queued callbacks still run.
 	Reset()
 
 	// CreateMessageUnsafe creates a new message and returns the index that is
-	// the location the message is added to.
+	// the location the message is added to. A nil MessageRow is returned if the
+	// message is hidden by a filter.
 	CreateMessageUnsafe(cchat.MessageCreate) MessageRow
 	UpdateMessageUnsafe(cchat.MessageUpdate)
 	DeleteMessageUnsafe(cchat.MessageDelete)
@@ -57,10 +82,22 @@ type Container interface {
 	Message(id cchat.ID, nonce string) MessageRow
 	// FindMessage finds a message that satisfies the given callback.
 	FindMessage(isMessage func(MessageRow) bool) MessageRow
+	// RefreshAuthor reapplies the local overrides on all messages from the
+	// given author.
+	RefreshAuthor(authorID cchat.ID)
+	// Refilter applies the filters again on all messages. The hidden messages
+	// that aren't hidden anymore are returned to be created again.
+	Refilter() []cchat.MessageCreate
+	// RerenderContent renders the content of all messages again.
+	RerenderContent()
 
 	// Highlight temporarily highlights the given message for a short while.
 	Highlight(msg MessageRow)
 
+	// Queue runs the callback in the main loop after the message events
+	// received before it are applied. It is thread-safe.
+	Queue(fn func())
+
 	// UI methods.
 
 	SetFocusHAdjustment(*gtk.Adjustment)
@@ -78,8 +115,20 @@ type Controller interface {
 	// AuthorEvent is called on message create/update. This is used to update
 	// the typer state.
 	AuthorEvent(a cchat.Author)
+	// MentionEvent is called when a newly created message either mentions the
+	// user or matches one of the user's keyword highlight rules.
+	MentionEvent(msg cchat.MessageCreate)
+	// ExtendAuthorPopover is called when the popover of a message's author is
+	// shown, allowing the controller to add its own widgets.
+	ExtendAuthorPopover(author cchat.Author, popover *gtk.Popover, box *gtk.Box)
+	// SessionID returns the ID of the current session, which is used for
+	// filtering messages.
+	SessionID() string
 	// SelectMessage is called when a message is selected.
 	SelectMessage(list *ListStore, msg MessageRow)
+	// SelectMessages is called when multiple messages are selected. The
+	// messages are in the order they appear in the list.
+	SelectMessages(list *ListStore, msgs []MessageRow)
 	// UnselectMessage is called when the message selection is cleared.
 	UnselectMessage()
 }
@@ -101,6 +150,8 @@ type ListContainer struct {
 	*ListStore
 
 	Controller
+
+	batch batch
 }
 
 // messageRow w/ required internals
@@ -146,6 +197,25 @@ func NewListContainer(ctrl Controller, constr Constructor) *ListContainer {
 // 	return c.ListStore.CreateMessageUnsafe(msg)
 // }
 
+// Reset drops the queued message events and resets the list.
+func (c *ListContainer) Reset() {
+	c.batch.Clear()
+	c.ListStore.Reset()
+}
+
+// Queue queues the callback along with the message events, which are run in
+// batches to keep the main loop responsive. The callback runs even if the
+// container is reset before then, so it should check if it's stale.
+func (c *ListContainer) Queue(fn func()) {
+	c.batch.Add(fn)
+}
+
+// QueueEvent is like Queue, except the callback applies a message event, which
+// is dropped if the container is reset before it runs.
+func (c *ListContainer) QueueEvent(fn func()) {
+	c.batch.AddEvent(fn)
+}
+
 // CleanMessages cleans up the oldest messages if the user is scrolled to the
 // bottom. True is returned if there were changes.
 func (c *ListContainer) CleanMessages() bool {
@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"github.com/diamondburned/cchat"
-	"github.com/diamondburned/cchat-gtk/internal/gts"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/input"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/message"
@@ -138,7 +137,10 @@ func isCollapsible(lastMsg container.MessageRow, msg authoredMessage) bool {
 }
 
 func (c *Container) CreateMessage(msg cchat.MessageCreate) {
-	gts.ExecAsync(func() {
+	// Render the content here rather than in the main loop.
+	msg = message.Prerender(msg)
+
+	c.QueueEvent(func() {
 		// Create the message in the parent's handler. This handler will also
 		// wipe old messages.
 		row := c.ListContainer.CreateMessageUnsafe(msg)
@@ -177,14 +179,14 @@ func (c *Container) CreateMessage(msg cchat.MessageCreate) {
 }
 
 func (c *Container) UpdateMessage(msg cchat.MessageUpdate) {
-	gts.ExecAsync(func() {
+	c.QueueEvent(func() {
 		c.UpdateMessageUnsafe(msg)
 	})
 }
 
-func (c *Container) DeleteMessage(msg cchat.MessageDelete) {
-	gts.ExecAsync(func() {
-		msgID := msg.ID()
+func (c *Container) DeleteMessage(msgDelete cchat.MessageDelete) {
+	c.QueueEvent(func() {
+		msgID := msgDelete.ID()
 
 		// Get the previous and next message before deleting. We'll need them to
 		// evaluate whether we need to change anything.
@@ -193,8 +195,19 @@ func (c *Container) DeleteMessage(msg cchat.MessageDelete) {
 		// The function doesn't actually try and re-collapse the bottom message
 		// when a sandwiched message is deleted. This is fine.
 
-		// Delete the message off of the parent's container.
-		msg := c.ListStore.PopMessage(msgID)
+		// Get the message before it's deleted off of the parent's container.
+		msg := c.ListStore.Message(msgID, "")
+		if msg == nil {
+			return
+		}
+
+		c.ListStore.DeleteMessageUnsafe(msgDelete)
+
+		// Deleted messages that are shown stay in place, so nothing around
+		// them changes.
+		if container.ShowDeleted {
+			return
+		}
 
 		// Don't calculate if we don't have any messages, or no messages before
 		// and after.
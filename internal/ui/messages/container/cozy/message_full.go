@@ -56,7 +56,7 @@ func NewFullMessage(msg cchat.MessageCreate) *FullMessage {
 	// pixbuf if possible.
 	msgc.UpdateAuthorName(msg.Author().Name())
 	msgc.UpdateTimestamp(msg.Time())
-	msgc.UpdateContent(msg.Content(), false)
+	message.FillContent(msgc, msg)
 	return msgc
 }
 
@@ -64,6 +64,7 @@ func WrapFullMessage(gc *message.GenericContainer) *FullMessage {
 	header := labeluri.NewLabel(text.Rich{})
 	header.SetHAlign(gtk.ALIGN_START) // left-align
 	header.SetMaxWidthChars(100)
+	header.SetPopoverExtender(gc.ExtendAuthorPopover)
 	header.Show()
 
 	avatar := NewAvatar()
@@ -71,7 +72,7 @@ func WrapFullMessage(gc *message.GenericContainer) *FullMessage {
 	avatar.SetMarginStart(container.ColumnSpacing * 2)
 	avatar.Connect("clicked", func(w gtk.IWidget) {
 		if output := header.Output(); len(output.Mentions) > 0 {
-			labeluri.PopoverMentioner(w, output.Input, output.Mentions[0])
+			header.PopoverMentioner(w, output.Mentions[0])
 		}
 	})
 	avatar.Show()
@@ -0,0 +1,162 @@
+package container_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container/compact"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container/cozy"
+	"github.com/diamondburned/cchat/text"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+type controller struct {
+	*gtk.Box
+}
+
+func newController(t *testing.T) controller {
+	box, err := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	if err != nil {
+		t.Fatal("Failed to make box:", err)
+	}
+	return controller{box}
+}
+
+func (controller) BindMenu(container.MessageRow)                               {}
+func (controller) Bottomed() bool                                              { return false }
+func (controller) AuthorEvent(cchat.Author)                                    {}
+func (controller) MentionEvent(cchat.MessageCreate)                            {}
+func (controller) ExtendAuthorPopover(cchat.Author, *gtk.Popover, *gtk.Box)    {}
+func (controller) SessionID() string                                           { return "" }
+func (controller) SelectMessage(*container.ListStore, container.MessageRow)    {}
+func (controller) SelectMessages(*container.ListStore, []container.MessageRow) {}
+func (controller) UnselectMessage()                                            {}
+
+type author string
+
+func (a author) ID() cchat.ID    { return string(a) }
+func (a author) Name() text.Rich { return text.Plain(string(a)) }
+func (a author) Avatar() string  { return "" }
+
+type message struct {
+	id     cchat.ID
+	author author
+	time   time.Time
+}
+
+func (m message) ID() cchat.ID         { return m.id }
+func (m message) Time() time.Time      { return m.time }
+func (m message) Nonce() string        { return "" }
+func (m message) Mentioned() bool      { return false }
+func (m message) Content() text.Rich   { return text.Plain("content " + m.id) }
+func (m message) Author() cchat.Author { return m.author }
+
+type messageContainer interface {
+	container.Container
+	cchat.MessagesContainer
+	MessagesLen() int
+}
+
+var layouts = []struct {
+	name string
+	new  func(container.Controller) messageContainer
+}{
+	{"cozy", func(c container.Controller) messageContainer { return cozy.NewContainer(c) }},
+	{"compact", func(c container.Controller) messageContainer { return compact.NewContainer(c) }},
+}
+
+// flush runs the queued message events.
+func flush() {
+	for gtk.EventsPending() {
+		gtk.MainIteration()
+	}
+}
+
+// addMessages creates messages with the IDs 0 to len(authors)-1 from the given
+// authors.
+func addMessages(c messageContainer, authors ...author) {
+	var start = time.Now()
+	for i, author := range authors {
+		c.CreateMessage(message{
+			id:     string(rune('0' + i)),
+			author: author,
+			time:   start.Add(time.Duration(i) * time.Second),
+		})
+	}
+	flush()
+}
+
+func TestDeleteMessage(t *testing.T) {
+	defer func(show bool) { container.ShowDeleted = show }(container.ShowDeleted)
+
+	for _, layout := range layouts {
+		for _, show := range []bool{false, true} {
+			name := layout.name + "/hidden"
+			if show {
+				name = layout.name + "/shown"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				container.ShowDeleted = show
+
+				c := layout.new(newController(t))
+				addMessages(c, "a", "a", "a")
+
+				c.DeleteMessage(message{id: "1"})
+				flush()
+
+				msg := c.Message("1", "")
+
+				if !show {
+					if msg != nil || c.MessagesLen() != 2 {
+						t.Fatalf("Deleted message kept, %d messages left", c.MessagesLen())
+					}
+					return
+				}
+
+				if msg == nil || !msg.Deleted() || c.MessagesLen() != 3 {
+					t.Fatal("Deleted message isn't kept as a deleted row")
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteMessageCozyUncollapse(t *testing.T) {
+	defer func(show bool) { container.ShowDeleted = show }(container.ShowDeleted)
+
+	var tests = []struct {
+		name      string
+		show      bool
+		collapsed bool
+	}{
+		{"hidden", false, false},
+		{"shown", true, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container.ShowDeleted = test.show
+
+			c := cozy.NewContainer(newController(t))
+			addMessages(c, "b", "a", "a")
+
+			// The last message is collapsed under the one being deleted.
+			if _, ok := c.Message("2", "").(*cozy.CollapsedMessage); !ok {
+				t.Fatal("Message isn't collapsed before the delete")
+			}
+
+			c.DeleteMessage(message{id: "1"})
+			flush()
+
+			// It only needs to be a full message again if the deleted one is
+			// gone.
+			_, collapsed := c.Message("2", "").(*cozy.CollapsedMessage)
+			if collapsed != test.collapsed {
+				t.Fatalf("Message collapsed = %v, expected %v", collapsed, test.collapsed)
+			}
+		})
+	}
+}
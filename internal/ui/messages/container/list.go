@@ -7,9 +7,14 @@ import (
 
 	"github.com/diamondburned/cchat"
 	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/filter"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/input"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/message"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/override"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/keyword"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/markup"
+	"github.com/diamondburned/cchat/text"
 	"github.com/gotk3/gotk3/gtk"
 )
 
@@ -59,6 +64,10 @@ func (key messageKey) name() string {
 // String satisfies the fmt.Stringer interface.
 func (key messageKey) String() string { return key.name() }
 
+// ShowDeleted keeps deleted messages in the list as faded out rows instead of
+// removing them.
+var ShowDeleted = false
+
 var messageListCSS = primitives.PrepareClassCSS("message-list", `
 	.message-list { background: transparent; }
 `)
@@ -72,11 +81,16 @@ type ListStore struct {
 	resetMe bool
 
 	messages map[messageKey]*messageRow
+
+	// hidden is the messages hidden by the filters from the oldest. They're
+	// kept so that they can be shown again if the filters change. There are
+	// at most BacklogLimit of them.
+	hidden []cchat.MessageCreate
 }
 
 func NewListStore(ctrl Controller, constr Constructor) *ListStore {
 	listBox, _ := gtk.ListBoxNew()
-	listBox.SetSelectionMode(gtk.SELECTION_SINGLE)
+	listBox.SetSelectionMode(gtk.SELECTION_MULTIPLE)
 	listBox.Show()
 	messageListCSS(listBox)
 
@@ -87,27 +101,28 @@ func NewListStore(ctrl Controller, constr Constructor) *ListStore {
 		messages:   make(map[messageKey]*messageRow, BacklogLimit+1),
 	}
 
-	var selected bool
+	// changed is true if the selection was changed before a row is activated.
+	// It is used to unselect a single row by clicking on it again.
+	var changed bool
 
-	listBox.Connect("row-selected", func(listBox *gtk.ListBox, r *gtk.ListBoxRow) {
-		if r == nil || selected {
-			if selected {
-				listBox.UnselectAll()
-				selected = false
-			}
+	listBox.Connect("selected-rows-changed", func(listBox *gtk.ListBox) {
+		changed = true
+
+		switch msgs := listStore.SelectedMessages(); len(msgs) {
+		case 0:
 			ctrl.UnselectMessage()
-			return
+		case 1:
+			ctrl.SelectMessage(&listStore, msgs[0])
+		default:
+			ctrl.SelectMessages(&listStore, msgs)
 		}
+	})
 
-		id := parseKeyFromNamer(r)
-
-		msg := listStore.Message(id.expand())
-		if msg == nil {
-			return
+	listBox.Connect("row-activated", func(listBox *gtk.ListBox, r *gtk.ListBoxRow) {
+		if !changed && r.IsSelected() && len(listStore.SelectedMessages()) == 1 {
+			listBox.UnselectAll()
 		}
-
-		selected = true
-		ctrl.SelectMessage(&listStore, msg)
+		changed = false
 	})
 
 	return &listStore
@@ -116,6 +131,7 @@ func NewListStore(ctrl Controller, constr Constructor) *ListStore {
 func (c *ListStore) Reset() {
 	// Delegate removing children to the constructor.
 	c.messages = make(map[messageKey]*messageRow, BacklogLimit+1)
+	c.hidden = nil
 }
 
 func (c *ListStore) MessagesLen() int {
@@ -153,6 +169,27 @@ func (c *ListStore) SwapMessage(msg MessageRow) bool {
 	return true
 }
 
+// SelectedMessages returns the selected messages in the order they appear in
+// the list.
+func (c *ListStore) SelectedMessages() []MessageRow {
+	rows := c.ListBox.GetSelectedRows()
+	if rows == nil {
+		return nil
+	}
+	defer rows.Free()
+
+	var msgs = make([]MessageRow, 0, rows.Length())
+
+	rows.Foreach(func(v interface{}) {
+		key := parseKeyFromNamer(v.(primitives.Namer))
+		if row, ok := c.messages[key]; ok {
+			msgs = append(msgs, row.MessageRow)
+		}
+	})
+
+	return msgs
+}
+
 // Around returns the message before and after the given ID, or nil if none.
 func (c *ListStore) Around(id cchat.ID) (before, after MessageRow) {
 	gridBefore, gridAfter := c.around(id)
@@ -197,7 +234,7 @@ func (c *ListStore) around(aroundID cchat.ID) (before, after *messageRow) {
 func (c *ListStore) LatestMessageFrom(userID string) (msgID string, ok bool) {
 	// FindMessage already looks from the latest messages.
 	var msg = c.FindMessage(func(msg MessageRow) bool {
-		return msg.Author().ID() == userID
+		return msg.Author().ID() == userID && !msg.Deleted()
 	})
 
 	if msg == nil {
@@ -354,6 +391,9 @@ func (c *ListStore) bindMessage(msgc *messageRow) {
 
 	msgc.Row().SetName(key.name())
 	msgc.SetReferenceHighlighter(c)
+	msgc.SetAuthorPopoverExtender(func(p *gtk.Popover, box *gtk.Box) {
+		c.Controller.ExtendAuthorPopover(msgc.Author(), p, box)
+	})
 	c.Controller.BindMenu(msgc.MessageRow)
 }
 
@@ -392,19 +432,34 @@ func (c *ListStore) AddPresendMessage(msg input.PresendMessage) PresendMessageRo
 // rely on it.
 
 func (c *ListStore) CreateMessageUnsafe(msg cchat.MessageCreate) MessageRow {
+	// Keep the message as it is in case it's hidden.
+	var orig = msg
+
+	// Apply the local author overrides. They don't change the content, so the
+	// pre-rendered one is kept.
+	msg = message.KeepRendered(msg, override.WrapMessage(c.Controller.SessionID(), msg))
+
 	// Call the event handler last.
 	defer c.Controller.AuthorEvent(msg.Author())
 
 	// Do not attempt to update before insertion (aka upsert).
 	if msgc := c.message(msg.ID(), msg.Nonce()); msgc != nil {
 		msgc.UpdateAuthor(msg.Author())
-		msgc.UpdateContent(msg.Content(), false)
+		message.FillContent(msgc, msg)
 		msgc.UpdateTimestamp(msg.Time())
 
 		c.bindMessage(msgc)
 		return msgc.MessageRow
 	}
 
+	// Consult the filters before creating the message. Hidden messages are
+	// never added.
+	action, filtered := filter.Match(c.Controller.SessionID(), msg)
+	if filtered && action == filter.Hide {
+		c.hide(orig)
+		return nil
+	}
+
 	c.ensureEmpty()
 
 	msgTime := msg.Time()
@@ -442,6 +497,17 @@ func (c *ListStore) CreateMessageUnsafe(msg cchat.MessageCreate) MessageRow {
 
 	c.bindMessage(msgc)
 
+	if filtered {
+		switch action {
+		case filter.Collapse:
+			msgc.SetContentHidden(true)
+		case filter.Dim:
+			msgc.SetDimmed(true)
+		}
+	} else if msg.Mentioned() || keyword.IsMention(msg.Content().Content) {
+		c.Controller.MentionEvent(msg)
+	}
+
 	return msgc.MessageRow
 }
 
@@ -449,20 +515,146 @@ func (c *ListStore) UpdateMessageUnsafe(msg cchat.MessageUpdate) {
 	// Call the event handler last.
 	defer c.Controller.AuthorEvent(msg.Author())
 
-	if msgc := c.Message(msg.ID(), ""); msgc != nil {
+	if msgc := c.Message(msg.ID(), ""); msgc != nil && !msgc.Deleted() {
 		if author := msg.Author(); author != nil {
-			msgc.UpdateAuthor(author)
+			msgc.UpdateAuthor(override.WrapAuthor(c.Controller.SessionID(), author))
 		}
 		if content := msg.Content(); !content.IsEmpty() {
-			msgc.UpdateContent(content, true)
+			msgc.EditContent(content, msg.Time())
+			c.refilter(msgc)
 		}
 	}
 
 	return
 }
 
+// refilter applies the filters again on an edited message, since the edit may
+// start or stop matching a rule.
+func (c *ListStore) refilter(msgc MessageRow) {
+	var authorID string
+	if author := msgc.Author(); author != nil {
+		authorID = author.ID()
+	}
+
+	action, filtered := filter.MatchContent(
+		c.Controller.SessionID(), authorID, msgc.RichContent(),
+	)
+
+	if filtered && action == filter.Hide {
+		c.PopMessage(msgc.ID())
+		c.hide(rowMessage{msgc})
+		return
+	}
+
+	msgc.SetContentHidden(filtered && action == filter.Collapse)
+	msgc.SetDimmed(filtered && action == filter.Dim)
+}
+
+// RerenderContent renders the content of all messages again, such as after the
+// keyword highlight rules change.
+func (c *ListStore) RerenderContent() {
+	// A row may be under both its nonce and its ID, so only render it once.
+	var rendered = make(map[*messageRow]struct{}, len(c.messages))
+
+	for _, msg := range c.messages {
+		if _, ok := rendered[msg]; ok {
+			continue
+		}
+		rendered[msg] = struct{}{}
+
+		msg.RerenderContent()
+	}
+}
+
+// Refilter applies the filters again on all messages after the rules change.
+// The hidden messages that aren't hidden anymore are returned, so that they
+// can be created again.
+func (c *ListStore) Refilter() []cchat.MessageCreate {
+	sessionID := c.Controller.SessionID()
+
+	var shown []cchat.MessageCreate
+	var hidden = c.hidden[:0]
+
+	for _, msg := range c.hidden {
+		if action, ok := filter.Match(sessionID, msg); ok && action == filter.Hide {
+			hidden = append(hidden, msg)
+		} else {
+			shown = append(shown, msg)
+		}
+	}
+
+	c.hidden = hidden
+
+	// A row may be under both its nonce and its ID, so only refilter it once.
+	// Unsent and deleted messages are left alone.
+	var rows = make(map[*messageRow]struct{}, len(c.messages))
+	for _, msg := range c.messages {
+		if msg.ID() != "" && !msg.Deleted() {
+			rows[msg] = struct{}{}
+		}
+	}
+
+	for msg := range rows {
+		c.refilter(msg.MessageRow)
+	}
+
+	return shown
+}
+
+// hide adds the message to the hidden messages, dropping the oldest one if
+// there are too many.
+func (c *ListStore) hide(msg cchat.MessageCreate) {
+	if len(c.hidden) >= BacklogLimit {
+		c.hidden[0] = nil
+		c.hidden = c.hidden[1:]
+	}
+
+	c.hidden = append(c.hidden, msg)
+}
+
+// rowMessage is a message row as a message, which is used to create a hidden
+// row again.
+type rowMessage struct {
+	MessageRow
+}
+
+func (msg rowMessage) Content() text.Rich { return msg.RichContent() }
+func (msg rowMessage) Mentioned() bool    { return false }
+
+// RefreshAuthor reapplies the local overrides on all messages from the given
+// author.
+func (c *ListStore) RefreshAuthor(authorID cchat.ID) {
+	sessionID := c.Controller.SessionID()
+
+	// A row may be under both its nonce and its ID, so only update it once.
+	var updated = make(map[*messageRow]struct{}, len(c.messages))
+
+	for _, msg := range c.messages {
+		if _, ok := updated[msg]; ok {
+			continue
+		}
+		updated[msg] = struct{}{}
+
+		if author := msg.Author(); author.ID() == authorID {
+			msg.UpdateAuthor(override.WrapAuthor(sessionID, author))
+		}
+	}
+}
+
 func (c *ListStore) DeleteMessageUnsafe(msg cchat.MessageDelete) {
-	c.PopMessage(msg.ID())
+	if !ShowDeleted {
+		c.PopMessage(msg.ID())
+		return
+	}
+
+	if msgc := c.Message(msg.ID(), ""); msgc != nil {
+		msgc.SetDeleted(time.Now())
+
+		// Unselect the row so that the message controls are rebound.
+		if msgc.Row().IsSelected() {
+			c.ListBox.UnselectRow(msgc.Row())
+		}
+	}
 }
 
 // PopMessage deletes a message off of the list and return the deleted message.
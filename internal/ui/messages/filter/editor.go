@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"encoding/json"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// editor is the config entry that edits the global list of filter rules.
+type editor struct{}
+
+var _ config.WideEntryValue = (*editor)(nil)
+
+func newEditor() *editor { return &editor{} }
+
+func (*editor) Wide() {}
+
+func (*editor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Rules())
+}
+
+func (*editor) UnmarshalJSON(b []byte) error {
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return err
+	}
+
+	// Invalid rules are still kept, so the error is not fatal.
+	log.Error(SetRules(rules))
+	return nil
+}
+
+var editorCSS = primitives.PrepareClassCSS("filter-rules", `
+	.filter-rules { margin-bottom: 8px; }
+	.filter-rules row { padding: 4px 0; }
+`)
+
+func (*editor) Construct() gtk.IWidget {
+	box := config.RuleList{
+		AddLabel: "Add Filter",
+		Rows: func() []config.RuleRow {
+			var rows []config.RuleRow
+			for _, rule := range Rules() {
+				rows = append(rows, newRuleRow(rule))
+			}
+			return rows
+		},
+		New: func() config.RuleRow {
+			return newRuleRow(Rule{Action: Collapse})
+		},
+		Set: func(rows []config.RuleRow) {
+			var newRules = make([]Rule, len(rows))
+			for i, row := range rows {
+				newRules[i] = row.(*ruleRow).rule
+			}
+			// Errors are shown on each individual row.
+			SetRules(newRules)
+		},
+	}.Construct()
+
+	editorCSS(box)
+	return box
+}
+
+type ruleRow struct {
+	*gtk.Grid
+	rule    Rule
+	changed func()
+}
+
+func (row *ruleRow) OnChange(changed func()) { row.changed = changed }
+
+func newRuleRow(rule Rule) *ruleRow {
+	row := &ruleRow{rule: rule}
+
+	var newEntry = func(placeholder, value string, set func(string)) *gtk.Entry {
+		entry, _ := gtk.EntryNew()
+		entry.SetPlaceholderText(placeholder)
+		entry.SetText(value)
+		entry.SetHExpand(true)
+		entry.Show()
+		entry.Connect("changed", func(entry *gtk.Entry) {
+			v, _ := entry.GetText()
+			set(v)
+			row.changed()
+		})
+		return entry
+	}
+
+	session := newEntry("All sessions", rule.SessionID, func(v string) {
+		row.rule.SessionID = v
+	})
+	session.SetTooltipText("Session ID")
+
+	author := newEntry("Any author", rule.AuthorID, func(v string) {
+		row.rule.AuthorID = v
+	})
+	author.SetTooltipText("Author ID")
+
+	var content *gtk.Entry
+	content = newEntry("Any content", rule.Content, func(v string) {
+		row.rule.Content = v
+
+		if err := row.rule.Compile(); err != nil {
+			content.SetIconFromIconName(gtk.ENTRY_ICON_SECONDARY, "dialog-error")
+			content.SetIconTooltipText(gtk.ENTRY_ICON_SECONDARY, err.Error())
+		} else {
+			content.RemoveIcon(gtk.ENTRY_ICON_SECONDARY)
+		}
+	})
+	content.SetTooltipText("Content regular expression")
+
+	attachments, _ := gtk.CheckButtonNewWithLabel("Attachments only")
+	attachments.SetActive(rule.AttachmentsOnly)
+	attachments.SetHExpand(true)
+	attachments.Show()
+	attachments.Connect("toggled", func(attachments *gtk.CheckButton) {
+		row.rule.AttachmentsOnly = attachments.GetActive()
+		row.changed()
+	})
+
+	action, _ := gtk.ComboBoxTextNew()
+	for _, name := range Actions() {
+		action.Append(name, name)
+	}
+	action.SetActive(int(rule.Action))
+	action.Show()
+	action.Connect("changed", func(action *gtk.ComboBoxText) {
+		row.rule.Action = Action(action.GetActive())
+		row.changed()
+	})
+
+	row.Grid, _ = gtk.GridNew()
+	row.Grid.SetRowSpacing(4)
+	row.Grid.SetColumnSpacing(4)
+	row.Grid.Attach(session, 0, 0, 1, 1)
+	row.Grid.Attach(author, 1, 0, 1, 1)
+	row.Grid.Attach(content, 0, 1, 2, 1)
+	row.Grid.Attach(attachments, 0, 2, 1, 1)
+	row.Grid.Attach(action, 1, 2, 1, 1)
+	row.Grid.Show()
+
+	return row
+}
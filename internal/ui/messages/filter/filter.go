@@ -0,0 +1,283 @@
+// Package filter provides client-side message filters, which allow hiding
+// authors or content locally regardless of the backend's capabilities.
+package filter
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/diamondburned/cchat/text"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	config.FiltersAdd("Rules", newEditor())
+}
+
+// Action is the action to take on a filtered message.
+type Action uint8
+
+const (
+	// Hide hides the message entirely.
+	Hide Action = iota
+	// Collapse replaces the message's content with a stub that can be
+	// clicked to reveal the content.
+	Collapse
+	// Dim fades the message out.
+	Dim
+	actionLen
+)
+
+// Actions returns the names of all actions in order.
+func Actions() []string {
+	var names = make([]string, actionLen)
+	for i := range names {
+		names[i] = Action(i).String()
+	}
+	return names
+}
+
+func (a Action) String() string {
+	switch a {
+	case Hide:
+		return "Hide"
+	case Collapse:
+		return "Collapse"
+	case Dim:
+		return "Dim"
+	default:
+		return "???"
+	}
+}
+
+// Rule is a single filter rule. All non-empty criteria of a rule must match
+// for the rule to apply. A rule without any criteria never matches.
+type Rule struct {
+	// SessionID is the ID of the session that the rule applies to. An empty
+	// session ID makes the rule global.
+	SessionID string `json:"session_id,omitempty"`
+
+	AuthorID        string `json:"author_id,omitempty"`
+	Content         string `json:"content,omitempty"` // regex
+	AttachmentsOnly bool   `json:"attachments_only,omitempty"`
+
+	Action Action `json:"action"`
+
+	compiled *regexp.Regexp
+}
+
+// Compile compiles the content regular expression of the rule.
+func (r *Rule) Compile() error {
+	r.compiled = nil
+
+	if r.Content == "" {
+		return nil
+	}
+
+	re, err := regexp.Compile(r.Content)
+	if err != nil {
+		return errors.Wrap(err, "Invalid regular expression")
+	}
+
+	r.compiled = re
+	return nil
+}
+
+// IsEmpty returns true if the rule has no criteria.
+func (r Rule) IsEmpty() bool {
+	return r.AuthorID == "" && r.Content == "" && !r.AttachmentsOnly
+}
+
+// Match returns true if the rule matches the given message from the given
+// session.
+func (r Rule) Match(sessionID string, msg cchat.MessageCreate) bool {
+	return r.MatchContent(sessionID, msg.Author().ID(), msg.Content())
+}
+
+// MatchContent returns true if the rule matches the given content from the
+// given author and session.
+func (r Rule) MatchContent(sessionID, authorID string, content text.Rich) bool {
+	if r.IsEmpty() {
+		return false
+	}
+	if r.SessionID != "" && r.SessionID != sessionID {
+		return false
+	}
+	if r.AuthorID != "" && r.AuthorID != authorID {
+		return false
+	}
+	if r.Content != "" {
+		// Never match invalid regular expressions.
+		if r.compiled == nil || !r.compiled.MatchString(content.Content) {
+			return false
+		}
+	}
+	if r.AttachmentsOnly && !isAttachmentsOnly(content) {
+		return false
+	}
+	return true
+}
+
+// isAttachmentsOnly returns true if the given content only has images or
+// links in it.
+func isAttachmentsOnly(content text.Rich) bool {
+	var media bool
+	var rest = []byte(content.Content)
+
+	for _, segment := range content.Segments {
+		if segment.AsImager() == nil && segment.AsLinker() == nil {
+			continue
+		}
+
+		media = true
+
+		start, end := segment.Bounds()
+		for i := start; i >= 0 && i < end && i < len(rest); i++ {
+			rest[i] = ' '
+		}
+	}
+
+	return media && len(bytes.TrimSpace(rest)) == 0
+}
+
+var (
+	rulesMu sync.RWMutex
+	rules   []Rule
+)
+
+var onChange []func()
+
+// OnChange adds a callback that's called when the rules change, so that the
+// existing messages can be filtered again. It returns a callback to remove it.
+// The callback is called in the main thread.
+func OnChange(fn func()) (remove func()) {
+	onChange = append(onChange, fn)
+	i := len(onChange) - 1
+
+	return func() { onChange[i] = nil }
+}
+
+func changed() {
+	for _, fn := range onChange {
+		if fn != nil {
+			fn()
+		}
+	}
+}
+
+// Rules returns a copy of the current list of rules.
+func Rules() []Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	return append([]Rule(nil), rules...)
+}
+
+// SetRules compiles and sets the given rules. The first compile error is
+// returned, if any. It must be called in the main thread.
+func SetRules(newRules []Rule) error {
+	var firstErr error
+
+	for i := range newRules {
+		if err := newRules[i].Compile(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	rulesMu.Lock()
+	rules = newRules
+	rulesMu.Unlock()
+
+	changed()
+
+	return firstErr
+}
+
+// Match returns the action of the first rule that matches the given message.
+// False is returned if no rules match. This function is thread-safe.
+func Match(sessionID string, msg cchat.MessageCreate) (Action, bool) {
+	return MatchContent(sessionID, msg.Author().ID(), msg.Content())
+}
+
+// MatchContent is Match for the content of a message that already exists, such
+// as an edited one. This function is thread-safe.
+func MatchContent(sessionID, authorID string, content text.Rich) (Action, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	for _, rule := range rules {
+		if rule.MatchContent(sessionID, authorID, content) {
+			return rule.Action, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsAuthorIgnored returns true if there's a rule that only filters the given
+// author in the given session scope. An empty sessionID checks for global
+// rules.
+func IsAuthorIgnored(sessionID, authorID string) bool {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	for _, rule := range rules {
+		if isAuthorRule(rule, sessionID, authorID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IgnoreAuthor adds a rule that collapses all messages from the given author
+// in the given session scope, then saves the config. An empty sessionID makes
+// the rule global. It must be called in the main thread.
+func IgnoreAuthor(sessionID, authorID string) {
+	rulesMu.Lock()
+	rules = append(rules, Rule{
+		SessionID: sessionID,
+		AuthorID:  authorID,
+		Action:    Collapse,
+	})
+	rulesMu.Unlock()
+
+	changed()
+	save()
+}
+
+// UnignoreAuthor removes all rules that only filter the given author in the
+// given session scope, then saves the config. It must be called in the main
+// thread.
+func UnignoreAuthor(sessionID, authorID string) {
+	rulesMu.Lock()
+	filtered := rules[:0]
+	for _, rule := range rules {
+		if !isAuthorRule(rule, sessionID, authorID) {
+			filtered = append(filtered, rule)
+		}
+	}
+	rules = filtered
+	rulesMu.Unlock()
+
+	changed()
+	save()
+}
+
+func isAuthorRule(rule Rule, sessionID, authorID string) bool {
+	return true &&
+		rule.SessionID == sessionID &&
+		rule.AuthorID == authorID &&
+		rule.Content == "" &&
+		!rule.AttachmentsOnly
+}
+
+func save() {
+	if err := config.Save(); err != nil {
+		log.Error(errors.Wrap(err, "Failed to save filters"))
+	}
+}
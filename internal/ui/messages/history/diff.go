@@ -0,0 +1,165 @@
+package history
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Op is the type of a diff chunk.
+type Op uint8
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Chunk is a part of a diff.
+type Chunk struct {
+	Op   Op
+	Text string
+}
+
+// maxDiffCells caps the size of the LCS table. Larger inputs are diffed as a
+// whole replacement.
+const maxDiffCells = 512 * 512
+
+// Diff calculates the word-level differences between old and new.
+func Diff(old, new string) []Chunk {
+	a, b := tokenize(old), tokenize(new)
+
+	// Trim the common prefix and suffix to keep the table small.
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var chunks []Chunk
+	chunks = appendChunks(chunks, Equal, a[:prefix])
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(midA)+1)*(len(midB)+1) > maxDiffCells {
+		chunks = appendChunks(chunks, Delete, midA)
+		chunks = appendChunks(chunks, Insert, midB)
+	} else {
+		chunks = appendLCS(chunks, midA, midB)
+	}
+
+	chunks = appendChunks(chunks, Equal, a[len(a)-suffix:])
+	return chunks
+}
+
+// appendLCS appends the diff of a and b using the longest common subsequence.
+func appendLCS(chunks []Chunk, a, b []string) []Chunk {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var i, j int
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			chunks = appendChunk(chunks, Equal, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			chunks = appendChunk(chunks, Delete, a[i])
+			i++
+		default:
+			chunks = appendChunk(chunks, Insert, b[j])
+			j++
+		}
+	}
+
+	chunks = appendChunks(chunks, Delete, a[i:])
+	chunks = appendChunks(chunks, Insert, b[j:])
+	return chunks
+}
+
+func appendChunks(chunks []Chunk, op Op, tokens []string) []Chunk {
+	for _, token := range tokens {
+		chunks = appendChunk(chunks, op, token)
+	}
+	return chunks
+}
+
+// appendChunk appends the token, merging it with the last chunk if they have
+// the same op.
+func appendChunk(chunks []Chunk, op Op, token string) []Chunk {
+	if len(chunks) > 0 && chunks[len(chunks)-1].Op == op {
+		chunks[len(chunks)-1].Text += token
+		return chunks
+	}
+	return append(chunks, Chunk{op, token})
+}
+
+// tokenize splits the string into words and runs of whitespace.
+func tokenize(str string) []string {
+	var tokens []string
+	var start = -1
+	var space bool
+
+	for i, r := range str {
+		isSpace := unicode.IsSpace(r)
+		if start >= 0 && isSpace == space {
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, str[start:i])
+		}
+		start = i
+		space = isSpace
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, str[start:])
+	}
+
+	return tokens
+}
+
+const (
+	insertSpan = `<span background="#2ec27e" bgalpha="25%">`
+	deleteSpan = `<span background="#e01b24" bgalpha="25%" strikethrough="true">`
+)
+
+// DiffMarkup renders the word-level differences between old and new as Pango
+// markup. Insertions are highlighted in green and deletions are struck out in
+// red.
+func DiffMarkup(old, new string) string {
+	var builder strings.Builder
+
+	for _, chunk := range Diff(old, new) {
+		text := html.EscapeString(chunk.Text)
+
+		switch chunk.Op {
+		case Equal:
+			builder.WriteString(text)
+		case Insert:
+			builder.WriteString(insertSpan + text + "</span>")
+		case Delete:
+			builder.WriteString(deleteSpan + text + "</span>")
+		}
+	}
+
+	return builder.String()
+}
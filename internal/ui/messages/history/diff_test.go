@@ -0,0 +1,147 @@
+package history
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	var tests = []struct {
+		name   string
+		old    string
+		new    string
+		expect []Chunk
+	}{{
+		name:   "equal",
+		old:    "hello world",
+		new:    "hello world",
+		expect: []Chunk{{Equal, "hello world"}},
+	}, {
+		name: "insert",
+		old:  "hello world",
+		new:  "hello big world",
+		expect: []Chunk{
+			{Equal, "hello "},
+			{Insert, "big "},
+			{Equal, "world"},
+		},
+	}, {
+		name: "delete",
+		old:  "hello big world",
+		new:  "hello world",
+		expect: []Chunk{
+			{Equal, "hello "},
+			{Delete, "big "},
+			{Equal, "world"},
+		},
+	}, {
+		name: "replace",
+		old:  "the quick fox",
+		new:  "the slow fox",
+		expect: []Chunk{
+			{Equal, "the "},
+			{Delete, "quick"},
+			{Insert, "slow"},
+			{Equal, " fox"},
+		},
+	}, {
+		name:   "empty old",
+		old:    "",
+		new:    "new text",
+		expect: []Chunk{{Insert, "new text"}},
+	}, {
+		name:   "empty new",
+		old:    "old text",
+		new:    "",
+		expect: []Chunk{{Delete, "old text"}},
+	}, {
+		name:   "both empty",
+		old:    "",
+		new:    "",
+		expect: nil,
+	}, {
+		name: "unicode",
+		old:  "café ☕ is nice",
+		new:  "café 🍵 is nice",
+		expect: []Chunk{
+			{Equal, "café "},
+			{Delete, "☕"},
+			{Insert, "🍵"},
+			{Equal, " is nice"},
+		},
+	}, {
+		name: "whitespace",
+		old:  "a b",
+		new:  "a  b",
+		expect: []Chunk{
+			{Equal, "a"},
+			{Delete, " "},
+			{Insert, "  "},
+			{Equal, "b"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := Diff(test.old, test.new)
+			if !reflect.DeepEqual(chunks, test.expect) {
+				t.Fatalf("Unexpected chunks: %q, expected %q", chunks, test.expect)
+			}
+
+			old, new := rebuild(chunks)
+			if old != test.old || new != test.new {
+				t.Fatalf("Chunks rebuild into %q and %q", old, new)
+			}
+		})
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	// Inputs over maxDiffCells are diffed as a whole replacement, but the
+	// common prefix and suffix are still kept.
+	var old, new []string
+	for i := 0; i < 600; i++ {
+		old = append(old, "a")
+		new = append(new, "b")
+	}
+
+	oldText := "start " + strings.Join(old, " ") + " end"
+	newText := "start " + strings.Join(new, " ") + " end"
+
+	chunks := Diff(oldText, newText)
+	if len(chunks) != 4 || chunks[0].Op != Equal || chunks[3].Op != Equal {
+		t.Fatalf("Unexpected chunks: %q", chunks)
+	}
+
+	if o, n := rebuild(chunks); o != oldText || n != newText {
+		t.Fatal("Chunks don't rebuild into the inputs")
+	}
+}
+
+func TestDiffMarkup(t *testing.T) {
+	const expect = `a <span background="#2ec27e" bgalpha="25%">&lt;b&gt; </span>c`
+
+	if markup := DiffMarkup("a c", "a <b> c"); markup != expect {
+		t.Fatal("Unexpected markup:", markup)
+	}
+}
+
+// rebuild returns the old and new strings from the chunks.
+func rebuild(chunks []Chunk) (old, new string) {
+	var o, n strings.Builder
+
+	for _, chunk := range chunks {
+		switch chunk.Op {
+		case Equal:
+			o.WriteString(chunk.Text)
+			n.WriteString(chunk.Text)
+		case Delete:
+			o.WriteString(chunk.Text)
+		case Insert:
+			n.WriteString(chunk.Text)
+		}
+	}
+
+	return o.String(), n.String()
+}
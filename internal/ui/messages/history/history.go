@@ -0,0 +1,55 @@
+// Package history keeps the edit history of messages and renders the
+// differences between revisions.
+package history
+
+import "time"
+
+// MaxRevisions is the maximum number of revisions kept per message. The oldest
+// revisions are dropped when the limit is reached.
+const MaxRevisions = 25
+
+// Revision is a single version of a message's content.
+type Revision struct {
+	Content string
+	Time    time.Time // when this revision became current
+}
+
+// History is a bounded list of revisions of a single message. The zero value is
+// an empty history.
+type History struct {
+	revisions []Revision
+	dropped   bool
+}
+
+// Add appends a new revision. The content is not added if it's the same as the
+// latest revision's.
+func (h *History) Add(content string, t time.Time) {
+	if len(h.revisions) > 0 && h.revisions[len(h.revisions)-1].Content == content {
+		return
+	}
+
+	if len(h.revisions) == MaxRevisions {
+		copy(h.revisions, h.revisions[1:])
+		h.revisions = h.revisions[:MaxRevisions-1]
+		h.dropped = true
+	}
+
+	h.revisions = append(h.revisions, Revision{content, t})
+}
+
+// Len returns the number of revisions.
+func (h *History) Len() int {
+	return len(h.revisions)
+}
+
+// Revisions returns the revisions from the oldest to the latest. The returned
+// slice must not be modified.
+func (h *History) Revisions() []Revision {
+	return h.revisions
+}
+
+// Truncated returns true if older revisions were dropped, meaning that the
+// first revision is not the original content.
+func (h *History) Truncated() bool {
+	return h.dropped
+}
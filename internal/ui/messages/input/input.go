@@ -8,6 +8,7 @@ import (
 	"github.com/diamondburned/cchat-gtk/internal/log"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/input/attachment"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/input/username"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/override"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/completion"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/scrollinput"
@@ -100,7 +101,7 @@ func (v *InputView) SetMessenger(session cchat.Session, messenger cchat.Messenge
 	// TODO: this is possibly racy vs the above SetMessenger.
 	var completer cchat.Completer
 	if sender := messenger.AsSender(); sender != nil {
-		completer = sender.AsCompleter()
+		completer = override.WrapCompleter(session.ID(), sender.AsCompleter())
 	}
 
 	v.Completer.SetCompleter(completer)
@@ -350,6 +351,14 @@ func (f *Field) StartReplyingTo(msgID cchat.ID) {
 	return
 }
 
+// StartQuoting starts replying to the given message with the given quote
+// inserted into the input. The input is focused afterwards.
+func (f *Field) StartQuoting(msgID cchat.ID, quote string) {
+	f.StartReplyingTo(msgID)
+	f.buffer.InsertAtCursor(quote)
+	f.text.GrabFocus()
+}
+
 // Editable returns whether or not the input field can be edited.
 func (f *Field) Editable(msgID cchat.ID) bool {
 	return f.editor != nil && f.editor.IsEditable(msgID)
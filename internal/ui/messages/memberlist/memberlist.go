@@ -7,6 +7,8 @@ import (
 
 	"github.com/diamondburned/cchat"
 	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/gts/httputil"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/override"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/roundimage"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich"
@@ -23,6 +25,9 @@ var MemberListWidth = 250
 
 type Controller interface {
 	MemberListUpdated(c *Container)
+	// SessionID returns the ID of the current session, which is used for
+	// local overrides of member names.
+	SessionID() string
 }
 
 type Container struct {
@@ -157,7 +162,7 @@ func (c *Container) SetSectionsUnsafe(sections []cchat.MemberSection) {
 
 func (c *Container) SetMemberUnsafe(sectionID string, member cchat.ListMember) {
 	if s, ok := c.Sections[sectionID]; ok {
-		s.SetMember(member)
+		s.SetMember(override.WrapMember(c.ctrl.SessionID(), member))
 	}
 }
 
@@ -325,6 +330,7 @@ func NewMember(member cchat.ListMember) *Member {
 	evb.Show()
 
 	img, _ := roundimage.NewStaticImage(evb, 0)
+	img.Priority = httputil.PriorityMemberList
 	img.Show()
 
 	icon := rich.NewCustomIcon(img, AvatarSize)
@@ -0,0 +1,218 @@
+// Package mentions provides the mentions inbox, which collects mentions from
+// all sessions. Mentions come from either the open messenger, which has the
+// message, or from unread indicators, which only have the server.
+package mentions
+
+import (
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/pkg/errors"
+)
+
+// MaxMentions is the maximum number of mentions kept in the inbox. The oldest
+// mentions are dropped first.
+const MaxMentions = 250
+
+// Mention is a single mention in the inbox.
+type Mention struct {
+	// Path is the list of IDs from the service down to the server.
+	Path []cchat.ID `json:"path"`
+	// Breadcrumb is the list of names from the service down to the server.
+	Breadcrumb []string `json:"breadcrumb"`
+
+	// MessageID is empty if the mention came from an unread indicator.
+	MessageID cchat.ID `json:"message_id,omitempty"`
+	Author    string   `json:"author,omitempty"`
+	Snippet   string   `json:"snippet,omitempty"`
+
+	Time time.Time `json:"time"`
+	Done bool      `json:"done,omitempty"`
+}
+
+// MaxDismissed is the maximum number of cleared mentions remembered so that
+// they're not added again. The oldest ones are forgotten first.
+const MaxDismissed = 1000
+
+const (
+	configName    = "mentions.json"
+	dismissedName = "mentions-dismissed.json"
+)
+
+// mentions is the list of mentions from the oldest to the latest. It must only
+// be accessed in the main thread.
+var mentions []*Mention
+
+// dismissed is the list of messages whose mentions were cleared from the
+// oldest to the latest. Backlogs contain the same mentions every time a server
+// is joined, so they're remembered to not add the mentions back.
+var dismissed []dismissal
+
+type dismissal struct {
+	Path      []cchat.ID `json:"path"`
+	MessageID cchat.ID   `json:"message_id"`
+}
+
+var onChange []func()
+
+func init() {
+	config.RegisterConfig(configName, &mentions)
+	config.RegisterConfig(dismissedName, &dismissed)
+}
+
+// OnChange adds a callback that's called when the inbox changes. It returns a
+// callback to remove it.
+func OnChange(fn func()) (remove func()) {
+	onChange = append(onChange, fn)
+	i := len(onChange) - 1
+
+	return func() { onChange[i] = nil }
+}
+
+func changed() {
+	for _, fn := range onChange {
+		if fn != nil {
+			fn()
+		}
+	}
+
+	save()
+}
+
+// All returns all mentions from the latest to the oldest. It must be called in
+// the main thread.
+func All() []*Mention {
+	var all = make([]*Mention, len(mentions))
+	for i, m := range mentions {
+		all[len(mentions)-1-i] = m
+	}
+	return all
+}
+
+// Pending returns the number of mentions that are not done.
+func Pending() (n int) {
+	for _, m := range mentions {
+		if !m.Done {
+			n++
+		}
+	}
+	return
+}
+
+// Add adds the mention into the inbox. Mentions of the same message are only
+// added once, and mentions that were cleared are not added again. Mentions
+// without a message replace the pending mention without a message in the same
+// server. It must be called in the main thread.
+func Add(m Mention) {
+	if m.MessageID != "" && isDismissed(m.Path, m.MessageID) {
+		return
+	}
+
+	for _, old := range mentions {
+		if !pathEqual(old.Path, m.Path) || old.MessageID != m.MessageID {
+			continue
+		}
+
+		if m.MessageID != "" {
+			return
+		}
+
+		// Bump the unread indicator's mention if it's still pending.
+		if !old.Done {
+			old.Time = m.Time
+			changed()
+			return
+		}
+	}
+
+	if len(mentions) >= MaxMentions {
+		mentions = append(mentions[:0], mentions[len(mentions)-MaxMentions+1:]...)
+	}
+
+	mentions = append(mentions, &m)
+	changed()
+}
+
+// SetDone marks the mention as done or not.
+func SetDone(m *Mention, done bool) {
+	m.Done = done
+	changed()
+}
+
+// MarkAllDone marks all mentions as done.
+func MarkAllDone() {
+	for _, m := range mentions {
+		m.Done = true
+	}
+	changed()
+}
+
+// ClearDone removes all mentions that are done.
+func ClearDone() {
+	var pending = mentions[:0]
+	for _, m := range mentions {
+		switch {
+		case !m.Done:
+			pending = append(pending, m)
+		case m.MessageID != "":
+			dismissed = append(dismissed, dismissal{m.Path, m.MessageID})
+		}
+	}
+	mentions = pending
+
+	if len(dismissed) > MaxDismissed {
+		dismissed = append(dismissed[:0], dismissed[len(dismissed)-MaxDismissed:]...)
+	}
+
+	changed()
+}
+
+func isDismissed(path []cchat.ID, messageID cchat.ID) bool {
+	for _, d := range dismissed {
+		if d.MessageID == messageID && pathEqual(d.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func pathEqual(a, b []cchat.ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveDelay is the delay to wait before saving, which batches consecutive
+// changes.
+const SaveDelay = 5 * time.Second
+
+var saving bool
+
+func save() {
+	if saving {
+		return
+	}
+
+	saving = true
+
+	gts.DoAfter(SaveDelay, func() {
+		saving = false
+
+		// Marshal in the main thread to avoid race conditions.
+		if err := config.MarshalToFile(configName, mentions); err != nil {
+			log.Error(errors.Wrap(err, "Failed to save mentions"))
+		}
+		if err := config.MarshalToFile(dismissedName, dismissed); err != nil {
+			log.Error(errors.Wrap(err, "Failed to save dismissed mentions"))
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package mentions
+
+import (
+	"html"
+	"strings"
+
+	"github.com/diamondburned/cchat-gtk/internal/humanize"
+	"github.com/diamondburned/cchat-gtk/internal/ui/dialog"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+var panelCSS = primitives.PrepareClassCSS("mentions", `
+	.mentions row { padding: 6px 8px; }
+	.mentions row.done { opacity: 0.5; }
+`)
+
+// SpawnPanel shows a dialog that lists all mentions. Open is called when the
+// user wants to go to the mention.
+func SpawnPanel(open func(*Mention)) {
+	list, _ := gtk.ListBoxNew()
+	list.SetSelectionMode(gtk.SELECTION_NONE)
+	list.Show()
+	panelCSS(list)
+
+	placeholder, _ := gtk.LabelNew("No mentions.")
+	placeholder.SetMarginTop(16)
+	placeholder.Show()
+	list.SetPlaceholder(placeholder)
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scroll.Add(list)
+	scroll.Show()
+
+	showDone, _ := gtk.CheckButtonNewWithLabel("Show Done")
+	showDone.Show()
+
+	allDone, _ := gtk.ButtonNewFromIconName("object-select-symbolic", gtk.ICON_SIZE_BUTTON)
+	allDone.SetTooltipText("Mark All Done")
+	allDone.Show()
+	allDone.Connect("clicked", func(*gtk.Button) { MarkAllDone() })
+
+	clearDone, _ := gtk.ButtonNewFromIconName("edit-clear-all-symbolic", gtk.ICON_SIZE_BUTTON)
+	clearDone.SetTooltipText("Clear Done")
+	clearDone.Show()
+	clearDone.Connect("clicked", func(*gtk.Button) { ClearDone() })
+
+	header, _ := gtk.HeaderBarNew()
+	header.SetShowCloseButton(true)
+	header.SetTitle("Mentions")
+	header.PackStart(allDone)
+	header.PackStart(clearDone)
+	header.PackEnd(showDone)
+	header.Show()
+
+	d := dialog.NewCSD(scroll, header)
+	d.SetDefaultSize(450, 500)
+	d.SetTitle("Mentions")
+
+	var refresh = func() {
+		primitives.DestroyChildren(list)
+
+		for _, m := range All() {
+			if m.Done && !showDone.GetActive() {
+				continue
+			}
+
+			m := m
+			list.Add(newRow(m, func() {
+				d.Destroy()
+				SetDone(m, true)
+				open(m)
+			}))
+		}
+	}
+
+	showDone.Connect("toggled", func(*gtk.CheckButton) { refresh() })
+
+	remove := OnChange(refresh)
+	d.Connect("destroy", func(interface{}) { remove() })
+
+	refresh()
+	d.Show()
+}
+
+func newRow(m *Mention, open func()) *gtk.ListBoxRow {
+	var title = "Mentioned"
+	if m.Author != "" {
+		title = m.Author
+	}
+
+	header, _ := gtk.LabelNew("")
+	header.SetMarkup(
+		"<b>" + html.EscapeString(title) + "</b> " +
+			rich.Small(html.EscapeString(humanize.TimeAgo(m.Time))),
+	)
+	header.SetXAlign(0)
+	header.SetEllipsize(pango.ELLIPSIZE_END)
+	header.SetHExpand(true)
+	header.Show()
+
+	crumb, _ := gtk.LabelNew("")
+	crumb.SetMarkup(rich.Small(html.EscapeString(strings.Join(m.Breadcrumb, " / "))))
+	crumb.SetXAlign(0)
+	crumb.SetEllipsize(pango.ELLIPSIZE_MIDDLE)
+	crumb.Show()
+
+	done, _ := gtk.CheckButtonNew()
+	done.SetActive(m.Done)
+	done.SetTooltipText("Done")
+	done.Show()
+	done.Connect("toggled", func(done *gtk.CheckButton) { SetDone(m, done.GetActive()) })
+
+	goTo, _ := gtk.ButtonNewFromIconName("go-jump-symbolic", gtk.ICON_SIZE_BUTTON)
+	goTo.SetTooltipText("Go to Message")
+	goTo.SetRelief(gtk.RELIEF_NONE)
+	goTo.Show()
+	goTo.Connect("clicked", func(*gtk.Button) { open() })
+
+	grid, _ := gtk.GridNew()
+	grid.SetRowSpacing(2)
+	grid.SetColumnSpacing(4)
+	grid.Attach(done, 0, 0, 1, 2)
+	grid.Attach(header, 1, 0, 1, 1)
+	grid.Attach(crumb, 1, 1, 1, 1)
+	grid.Attach(goTo, 2, 0, 1, 2)
+
+	if m.Snippet != "" {
+		snippet, _ := gtk.LabelNew(m.Snippet)
+		snippet.SetXAlign(0)
+		snippet.SetLineWrap(true)
+		snippet.SetLineWrapMode(pango.WRAP_WORD_CHAR)
+		snippet.SetEllipsize(pango.ELLIPSIZE_END)
+		snippet.SetLines(3)
+		snippet.Show()
+		grid.Attach(snippet, 1, 2, 2, 1)
+	}
+
+	grid.Show()
+
+	row, _ := gtk.ListBoxRowNew()
+	row.SetActivatable(false)
+	row.Add(grid)
+	row.Show()
+
+	if m.Done {
+		primitives.AddClass(row, "done")
+	}
+
+	return row
+}
@@ -2,6 +2,7 @@ package message
 
 import (
 	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/override"
 	"github.com/diamondburned/cchat/text"
 )
 
@@ -10,10 +11,14 @@ import (
 type Author struct {
 	id        cchat.ID
 	name      text.Rich
+	original  text.Rich
 	avatarURL string
 }
 
-var _ cchat.Author = (*Author)(nil)
+var (
+	_ cchat.Author           = (*Author)(nil)
+	_ override.OriginalNamer = (*Author)(nil)
+)
 
 // NewAuthor creates a new Author that is a copy of the given author.
 func NewAuthor(author cchat.Author) Author {
@@ -27,6 +32,7 @@ func NewCustomAuthor(id cchat.ID, name text.Rich, avatar string) Author {
 	return Author{
 		id,
 		name,
+		name,
 		avatar,
 	}
 }
@@ -35,6 +41,13 @@ func (a *Author) Update(author cchat.Author) {
 	a.id = author.ID()
 	a.name = author.Name()
 	a.avatarURL = author.Avatar()
+
+	// Keep the original name if the author's name is overridden locally.
+	if namer, ok := author.(override.OriginalNamer); ok {
+		a.original = namer.OriginalName()
+	} else {
+		a.original = a.name
+	}
 }
 
 func (a Author) ID() string {
@@ -45,6 +58,11 @@ func (a Author) Name() text.Rich {
 	return a.name
 }
 
+// OriginalName returns the author's name before any local override.
+func (a Author) OriginalName() text.Rich {
+	return a.original
+}
+
 func (a Author) Avatar() string {
 	return a.avatarURL
 }
@@ -0,0 +1,93 @@
+package message
+
+import (
+	"html"
+
+	"github.com/diamondburned/cchat-gtk/internal/humanize"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/history"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/gotk3/gotk3/pango"
+)
+
+// editedURI is the link URI of the edited indicator.
+const editedURI = "cchat-gtk:edited"
+
+var editedLink = `<a href="` + editedURI + `"><span underline="none">` +
+	rich.Small("(edited)") + `</span></a>`
+
+var historyCSS = primitives.PrepareClassCSS("message-history", `
+	.message-history { padding: 6px; }
+	.message-history .revision-time { opacity: 0.6; font-size: 0.8em; }
+`)
+
+// popupHistory shows a popover with the differences between each revision of
+// the message, pointing to the given rectangle.
+func (m *GenericContainer) popupHistory(ptr gdk.Rectangle) {
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 4)
+	box.Show()
+	historyCSS(box)
+
+	revisions := m.history.Revisions()
+
+	if len(revisions) < 2 {
+		empty, _ := gtk.LabelNew("No earlier revisions.")
+		empty.Show()
+		box.PackStart(empty, false, false, 0)
+	}
+
+	// Show the latest revision first.
+	for i := len(revisions) - 1; i >= 0; i-- {
+		var title, markup string
+
+		switch {
+		case i > 0:
+			title = "Edited"
+			markup = history.DiffMarkup(revisions[i-1].Content, revisions[i].Content)
+		case m.history.Truncated():
+			title = "Earliest kept revision"
+			markup = html.EscapeString(revisions[i].Content)
+		default:
+			title = "Original"
+			markup = html.EscapeString(revisions[i].Content)
+		}
+
+		if i < len(revisions)-1 {
+			sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+			sep.Show()
+			box.PackStart(sep, false, false, 0)
+		}
+
+		header, _ := gtk.LabelNew(title + " · " + humanize.TimeAgoLong(revisions[i].Time))
+		header.SetXAlign(0)
+		header.SetTooltipText(revisions[i].Time.Format("Mon, 2 Jan 2006 15:04:05"))
+		header.Show()
+		primitives.AddClass(header, "revision-time")
+
+		content, _ := gtk.LabelNew("")
+		content.SetMarkup(markup)
+		content.SetXAlign(0)
+		content.SetLineWrap(true)
+		content.SetLineWrapMode(pango.WRAP_WORD_CHAR)
+		content.SetMaxWidthChars(60)
+		content.SetSelectable(true)
+		content.Show()
+
+		box.PackStart(header, false, false, 0)
+		box.PackStart(content, false, false, 0)
+	}
+
+	scroll, _ := gtk.ScrolledWindowNew(nil, nil)
+	scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+	scroll.SetProperty("propagate-natural-height", true)
+	scroll.SetProperty("max-content-height", 400)
+	scroll.Add(box)
+	scroll.Show()
+
+	popover, _ := gtk.PopoverNew(m.ContentBody)
+	popover.SetPointingTo(ptr)
+	popover.Add(scroll)
+	popover.Popup()
+}
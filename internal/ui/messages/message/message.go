@@ -4,11 +4,15 @@ import (
 	"time"
 
 	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/humanize"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/history"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/menu"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich/labeluri"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/markup"
 	"github.com/diamondburned/cchat/text"
+	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
 	"github.com/gotk3/gotk3/pango"
 )
@@ -27,7 +31,7 @@ type Container interface {
 // FillContainer sets the container's contents to the one from MessageCreate.
 func FillContainer(c Container, msg cchat.MessageCreate) {
 	c.UpdateAuthor(msg.Author())
-	c.UpdateContent(msg.Content(), false)
+	FillContent(c, msg)
 	c.UpdateTimestamp(msg.Time())
 }
 
@@ -38,6 +42,21 @@ func RefreshContainer(c Container, gc *GenericContainer) {
 	c.UpdateTimestamp(gc.time)
 }
 
+var rowCSS = primitives.PrepareCSS(`
+	.message-row.highlighted {
+		background-color: alpha(@theme_selected_bg_color, 0.15);
+	}
+	.message-row.dimmed,
+	.message-row.deleted {
+		opacity: 0.5;
+	}
+	.message-annotation {
+		font-size: 0.85em;
+		font-style: italic;
+		opacity: 0.75;
+	}
+`)
+
 // GenericContainer provides a single generic message container for subpackages
 // to use.
 type GenericContainer struct {
@@ -54,7 +73,15 @@ type GenericContainer struct {
 	ContentBody      *labeluri.Label
 	ContentBodyStyle *gtk.StyleContext
 
+	content text.Rich // content of the latest revision
+	history history.History
+	deleted time.Time
+
 	menuItems []menu.Item
+
+	hiddenStub     *gtk.Button
+	annotation     *gtk.Label
+	authorExtender labeluri.PopoverExtender
 }
 
 var _ Container = (*GenericContainer)(nil)
@@ -99,6 +126,7 @@ func NewEmptyContainer() *GenericContainer {
 	row.Add(box)
 	row.Show()
 	primitives.AddClass(row, "message-row")
+	primitives.AttachCSS(row, rowCSS)
 
 	gc := &GenericContainer{
 		Box: box,
@@ -113,6 +141,15 @@ func NewEmptyContainer() *GenericContainer {
 		time: time.Now(),
 	}
 
+	// Show the edit history when the edited indicator is clicked.
+	gc.ContentBody.SetLinkActivator(func(uri string, ptr gdk.Rectangle) bool {
+		if uri != editedURI {
+			return false
+		}
+		gc.popupHistory(ptr)
+		return true
+	})
+
 	// Bind the custom popup menu to the content label.
 	gc.ContentBody.Connect("populate-popup", func(l *gtk.Label, m *gtk.Menu) {
 		menu.MenuSeparator(m)
@@ -164,16 +201,177 @@ func (m *GenericContainer) UpdateAuthor(author cchat.Author) {
 	m.author.Update(author)
 }
 
+var contentConfig = markup.RenderConfig{
+	HighlightKeywords: true,
+}
+
 func (m *GenericContainer) UpdateContent(content text.Rich, edited bool) {
-	m.ContentBody.SetLabelUnsafe(content)
+	m.UpdateRenderedContent(content, markup.RenderCmplxWithConfig(content, contentConfig), edited)
+}
+
+// UpdateRenderedContent is UpdateContent with the content already rendered.
+func (m *GenericContainer) UpdateRenderedContent(
+	content text.Rich, output markup.RenderOutput, edited bool) {
+
+	m.content = content
+	m.ContentBody.SetOutput(output)
+
+	// Highlight the whole row if the content matches any of the keywords.
+	if m.Highlighted() {
+		primitives.AddClass(m.row, "highlighted")
+	} else {
+		primitives.RemoveClass(m.row, "highlighted")
+	}
 
 	if edited {
 		markup := m.ContentBody.Output().Markup
-		markup += " " + rich.Small("(edited)")
+		markup += " " + editedLink
 		m.ContentBody.SetMarkup(markup)
 	}
 }
 
+// EditContent updates the content and records the previous content into the
+// message's edit history. The given time is when the edit happened; the
+// current time is used if it's not after the message's time, since some
+// backends only give the creation time.
+func (m *GenericContainer) EditContent(content text.Rich, t time.Time) {
+	// Record the content before the first edit.
+	if m.history.Len() == 0 {
+		m.history.Add(m.content.Content, m.time)
+	}
+
+	if !t.After(m.time) {
+		t = time.Now()
+	}
+
+	m.history.Add(content.Content, t)
+	m.UpdateContent(content, true)
+}
+
+// RerenderContent renders the content again, such as after the keyword
+// highlight rules change. The edited and deleted markers are kept.
+func (m *GenericContainer) RerenderContent() {
+	m.UpdateContent(m.content, m.history.Len() > 0)
+
+	if m.Deleted() {
+		m.SetDeleted(m.deleted)
+	}
+}
+
+// History returns the message's edit history.
+func (m *GenericContainer) History() *history.History {
+	return &m.history
+}
+
+// RichContent returns the message's current content.
+func (m *GenericContainer) RichContent() text.Rich {
+	return m.content
+}
+
+// Highlighted returns true if the message content matches any of the user's
+// keyword highlight rules.
+func (m *GenericContainer) Highlighted() bool {
+	return m.ContentBody.Output().Highlighted
+}
+
+// SetContentHidden sets whether or not the content is hidden behind a stub. The
+// stub reveals the content when clicked.
+func (m *GenericContainer) SetContentHidden(hidden bool) {
+	if !hidden {
+		if m.hiddenStub != nil {
+			m.hiddenStub.Destroy()
+			m.hiddenStub = nil
+		}
+		m.ContentBody.Show()
+		return
+	}
+
+	if m.hiddenStub != nil {
+		return
+	}
+
+	stub, _ := gtk.ButtonNewWithLabel("Hidden message")
+	stub.SetRelief(gtk.RELIEF_NONE)
+	stub.SetHAlign(gtk.ALIGN_START)
+	stub.SetTooltipText("Click to show the message")
+	stub.Connect("clicked", func(*gtk.Button) { m.SetContentHidden(false) })
+	stub.Show()
+	primitives.AddClass(stub, "message-hidden-stub")
+
+	m.hiddenStub = stub
+	m.ContentBody.Hide()
+	m.Content.PackStart(stub, false, false, 0)
+}
+
+// SetDimmed sets whether or not the message row is faded out.
+func (m *GenericContainer) SetDimmed(dimmed bool) {
+	if dimmed {
+		primitives.AddClass(m.row, "dimmed")
+	} else {
+		primitives.RemoveClass(m.row, "dimmed")
+	}
+}
+
+// SetDeleted marks the message as deleted at the given time. The content is
+// struck through and the row is faded out. The menu items are cleared, since
+// nothing can be done on a deleted message.
+func (m *GenericContainer) SetDeleted(t time.Time) {
+	m.deleted = t
+	m.menuItems = nil
+
+	markup := "<s>" + m.ContentBody.Output().Markup + "</s>"
+	markup += " " + rich.Small("(deleted at "+humanize.TimeAgo(t)+")")
+	m.ContentBody.SetMarkup(markup)
+
+	primitives.AddClass(m.row, "deleted")
+}
+
+// Deleted returns true if the message is marked as deleted.
+func (m *GenericContainer) Deleted() bool {
+	return !m.deleted.IsZero()
+}
+
+// SetAnnotation sets a local note that's shown below the content. An empty
+// string removes the note.
+func (m *GenericContainer) SetAnnotation(annotation string) {
+	if annotation == "" {
+		if m.annotation != nil {
+			m.annotation.Destroy()
+			m.annotation = nil
+		}
+		return
+	}
+
+	if m.annotation == nil {
+		m.annotation, _ = gtk.LabelNew("")
+		m.annotation.SetXAlign(0)
+		m.annotation.SetLineWrap(true)
+		m.annotation.SetLineWrapMode(pango.WRAP_WORD_CHAR)
+		m.annotation.SetSelectable(true)
+		m.annotation.Show()
+		primitives.AddClass(m.annotation, "message-annotation")
+
+		m.Content.PackEnd(m.annotation, false, false, 0)
+	}
+
+	m.annotation.SetText(annotation)
+}
+
+// SetAuthorPopoverExtender sets the callback that extends popovers of the
+// message's author.
+func (m *GenericContainer) SetAuthorPopoverExtender(ext labeluri.PopoverExtender) {
+	m.authorExtender = ext
+}
+
+// ExtendAuthorPopover extends the given author popover using the extender set
+// in SetAuthorPopoverExtender. Labels showing the author should have this as
+// their PopoverExtender.
+func (m *GenericContainer) ExtendAuthorPopover(popover *gtk.Popover, box *gtk.Box) {
+	if m.authorExtender != nil {
+		m.authorExtender(popover, box)
+	}
+}
+
 // AttachMenu connects signal handlers to handle a list of menu items from
 // the container.
 func (m *GenericContainer) AttachMenu(newItems []menu.Item) {
@@ -0,0 +1,57 @@
+package message
+
+import (
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/markup"
+	"github.com/diamondburned/cchat/text"
+)
+
+// Prerendered is a message whose content has been rendered ahead of time, so
+// that it doesn't have to be rendered in the main loop.
+type Prerendered interface {
+	cchat.MessageCreate
+	RenderedContent() markup.RenderOutput
+}
+
+type prerendered struct {
+	cchat.MessageCreate
+	output markup.RenderOutput
+}
+
+// Prerender renders the message's content. It is thread-safe and should be
+// called outside the main loop.
+func Prerender(msg cchat.MessageCreate) Prerendered {
+	if pr, ok := msg.(Prerendered); ok {
+		return pr
+	}
+
+	return prerendered{msg, markup.RenderCmplxWithConfig(msg.Content(), contentConfig)}
+}
+
+// KeepRendered returns the wrapped message with the pre-rendered content of the
+// original message, if it has any. The wrapper must not change the content.
+func KeepRendered(orig, wrapped cchat.MessageCreate) cchat.MessageCreate {
+	if pr, ok := orig.(Prerendered); ok {
+		return prerendered{wrapped, pr.RenderedContent()}
+	}
+	return wrapped
+}
+
+func (msg prerendered) RenderedContent() markup.RenderOutput { return msg.output }
+
+type renderedUpdater interface {
+	UpdateRenderedContent(c text.Rich, output markup.RenderOutput, edited bool)
+}
+
+// FillContent sets the container's content to the message's, using the
+// pre-rendered content if there's any.
+func FillContent(c Container, msg cchat.MessageCreate) {
+	if pr, ok := msg.(Prerendered); ok {
+		if updater, ok := c.(renderedUpdater); ok {
+			updater.UpdateRenderedContent(msg.Content(), pr.RenderedContent(), false)
+			return
+		}
+	}
+
+	c.UpdateContent(msg.Content(), false)
+}
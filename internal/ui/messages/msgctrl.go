@@ -53,6 +53,9 @@ type MessageControl struct {
 	Reply  *bindableButton
 	Edit   *bindableButton
 	Delete *bindableButton // Actions "Delete"
+
+	// Selection is the menu button for actions on the selected messages.
+	Selection *gtk.MenuButton
 }
 
 func NewMessageControl() *MessageControl {
@@ -62,10 +65,19 @@ func NewMessageControl() *MessageControl {
 	mc.Edit = newBindableButton("document-edit-symbolic")
 	mc.Delete = newBindableButton("edit-delete-symbolic")
 
+	selIcon, _ := gtk.ImageNewFromIconName("edit-copy-symbolic", iconSize)
+	selIcon.Show()
+
+	mc.Selection, _ = gtk.MenuButtonNew()
+	mc.Selection.SetImage(selIcon)
+	mc.Selection.SetTooltipText("Selected Messages")
+	mc.Selection.Show()
+
 	mc.Box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 2)
 	mc.Box.Add(mc.Reply)
 	mc.Box.Add(mc.Edit)
 	mc.Box.Add(mc.Delete)
+	mc.Box.Add(mc.Selection)
 	mc.Box.Show()
 
 	r, _ := gtk.RevealerNew()
@@ -91,6 +103,26 @@ func (mc *MessageControl) Enable(msg container.MessageRow, names MessageItemName
 	mc.Delete.bind(menu.FindItemFunc(items, names.Delete))
 }
 
+// EnableMultiple enables the MessageControl for multiple selected messages.
+// Only the delete button and the selection menu are shown. The delete button is
+// hidden if deleteAll is nil.
+func (mc *MessageControl) EnableMultiple(deleteAll func()) {
+	mc.SetSensitive(true)
+	mc.SetRevealChild(true && !mc.hide)
+
+	mc.Reply.unbind()
+	mc.Edit.unbind()
+	mc.Delete.unbind()
+	mc.Delete.bind(deleteAll)
+}
+
+// SetSelectionItems sets the menu items of the selection menu button.
+func (mc *MessageControl) SetSelectionItems(items []menu.Item) {
+	m, _ := gtk.MenuNew()
+	menu.MenuItems(m, items)
+	mc.Selection.SetPopup(m)
+}
+
 // SetHidden sets whether or not the control should be hidden.
 func (mc *MessageControl) SetHidden(hidden bool) {
 	mc.hide = hidden
@@ -0,0 +1,209 @@
+// Package namecolor derives stable name colors from author IDs for backends
+// that don't color names.
+package namecolor
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/diamondburned/cchat/text"
+	"github.com/diamondburned/cchat/utils/empty"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	paletteOff int = iota
+	palettePastel
+	paletteHighContrast
+	paletteColorblind
+)
+
+var paletteIndex = paletteOff
+
+func init() {
+	config.AppearanceAdd("Author Name Colors", config.Combo(
+		&paletteIndex,
+		[]string{"Off", "Pastel", "High Contrast", "Colorblind Safe"},
+		func(int) { update() },
+	))
+}
+
+// palettes maps palette indices to lists of 24-bit RGB colors.
+var palettes = map[int][]uint32{
+	palettePastel: {
+		0xFFB3BA, 0xFFDFBA, 0xFFFFBA, 0xBAFFC9, 0xBAE1FF,
+		0xD7BAFF, 0xFFBAF2, 0xC9F0FF, 0xE2F0CB, 0xF3D1DC,
+	},
+	// Sasha Trubetskoy's list of distinct colors.
+	paletteHighContrast: {
+		0xE6194B, 0x3CB44B, 0xFFE119, 0x4363D8, 0xF58231,
+		0x911EB4, 0x42D4F4, 0xF032E6, 0xBFEF45, 0x469990,
+	},
+	// Okabe and Ito's colorblind-safe palette.
+	paletteColorblind: {
+		0xE69F00, 0x56B4E9, 0x009E73, 0xF0E442, 0x0072B2, 0xD55E00, 0xCC79A7,
+	},
+}
+
+// minContrast is the minimum contrast ratio between the name color and the
+// theme's background color. This is WCAG's AA level for normal text.
+const minContrast = 4.5
+
+// colors is what Apply needs to pick a color. It's only changed in the main
+// thread, since the background color comes from the theme, but it's read from
+// any goroutine.
+var colors = struct {
+	sync.Mutex
+	palette []uint32
+	bg      uint32 // 24-bit RGB
+}{
+	bg: 0xFFFFFF,
+}
+
+// Apply adds a generated color to the name if the mode is enabled and the name
+// does not have any color. It may be called from any goroutine.
+func Apply(authorID string, name text.Rich) text.Rich {
+	colors.Lock()
+	palette, bg := colors.palette, colors.bg
+	colors.Unlock()
+
+	if len(palette) == 0 || authorID == "" || name.IsEmpty() || hasColor(name) {
+		return name
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(authorID))
+
+	color := palette[h.Sum32()%uint32(len(palette))]
+	color = ensureContrast(color, bg)
+
+	// Add the color segment last, so it becomes the innermost one. The
+	// capacity is capped to not overwrite the backend's slice.
+	name.Segments = append(
+		name.Segments[:len(name.Segments):len(name.Segments)],
+		segment{end: len(name.Content), color: text.SolidColor(color)},
+	)
+
+	return name
+}
+
+func hasColor(name text.Rich) bool {
+	for _, segment := range name.Segments {
+		if segment.AsColorer() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+type segment struct {
+	empty.TextSegment
+	start, end int
+	color      uint32
+}
+
+func (s segment) Bounds() (int, int)      { return s.start, s.end }
+func (s segment) Color() uint32           { return s.color }
+func (s segment) AsColorer() text.Colorer { return s }
+
+// settingsBound is true if the theme change handlers are connected. It's only
+// used in the main thread.
+var settingsBound bool
+
+// update updates the colors from the chosen palette and the current theme. It
+// must be called in the main thread.
+func update() {
+	palette := palettes[paletteIndex]
+
+	// Only look up the theme if the colors are used.
+	var bg uint32 = 0xFFFFFF
+	if len(palette) > 0 {
+		bg = background()
+	}
+
+	colors.Lock()
+	colors.palette = palette
+	colors.bg = bg
+	colors.Unlock()
+}
+
+// background returns the background color of the current theme. The colors
+// are updated again when the theme changes. It must be called in the main
+// thread.
+func background() uint32 {
+	// Assume a light theme if the window is not yet available.
+	if gts.App.Window == nil {
+		return 0xFFFFFF
+	}
+
+	if !settingsBound {
+		if settings, _ := gtk.SettingsGetDefault(); settings != nil {
+			changed := func(interface{}) { update() }
+			settings.Connect("notify::gtk-theme-name", changed)
+			settings.Connect("notify::gtk-application-prefer-dark-theme", changed)
+			settingsBound = true
+		}
+	}
+
+	style, _ := gts.App.Window.GetStyleContext()
+	if rgba, ok := style.LookupColor("theme_bg_color"); ok {
+		var color uint32
+		for _, v := range rgba.Floats()[:3] { // [0.0, 1.0], skip alpha
+			color = (color << 8) + uint32(v*0xFF)
+		}
+		return color
+	}
+
+	return 0xFFFFFF
+}
+
+// ensureContrast mixes the given color towards black or white, whichever is
+// further from the background, until the contrast is high enough.
+func ensureContrast(rgb, bg uint32) uint32 {
+	var target uint32 = 0x000000
+	if luminance(bg) < 0.5 {
+		target = 0xFFFFFF
+	}
+
+	for i := 0; i < 10 && contrast(rgb, bg) < minContrast; i++ {
+		rgb = mix(rgb, target, 0.2)
+	}
+
+	return rgb
+}
+
+// mix linearly mixes a with b by the given ratio.
+func mix(a, b uint32, ratio float64) uint32 {
+	var out uint32
+	for shift := 16; shift >= 0; shift -= 8 {
+		ac := float64(a >> uint(shift) & 0xFF)
+		bc := float64(b >> uint(shift) & 0xFF)
+		out |= uint32(ac+(bc-ac)*ratio) << uint(shift)
+	}
+	return out
+}
+
+// contrast calculates the WCAG contrast ratio of two colors.
+func contrast(a, b uint32) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// luminance calculates the WCAG relative luminance of an RGB color.
+func luminance(rgb uint32) float64 {
+	var channel = func(c uint32) float64 {
+		v := float64(c&0xFF) / 0xFF
+		if v <= 0.03928 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+
+	return 0.2126*channel(rgb>>16) + 0.7152*channel(rgb>>8) + 0.0722*channel(rgb)
+}
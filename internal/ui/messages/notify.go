@@ -0,0 +1,66 @@
+package messages
+
+import (
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/mentions"
+	"github.com/gotk3/gotk3/glib"
+)
+
+const (
+	// notifyMaxAge is the maximum age of a message for it to be notified. This
+	// prevents old messages from the backlog from sending notifications.
+	notifyMaxAge = time.Minute
+	// notifyMaxBody is the maximum length in runes of the notification body.
+	notifyMaxBody = 200
+)
+
+// MentionEvent is called when a new message mentions the user or matches a
+// keyword highlight rule. The mention is added into the mentions inbox, and a
+// desktop notification is sent if the window is not focused.
+func (v *View) MentionEvent(msg cchat.MessageCreate) {
+	// Don't notify the user of their own messages.
+	author := msg.Author()
+	if author.ID() == v.state.SessionID() {
+		return
+	}
+
+	body := []rune(msg.Content().String())
+	if len(body) > notifyMaxBody {
+		body = append(body[:notifyMaxBody], '…')
+	}
+
+	if len(v.state.path) > 0 {
+		mentions.Add(mentions.Mention{
+			Path:       v.state.path,
+			Breadcrumb: v.state.breadcrumb,
+			MessageID:  msg.ID(),
+			Author:     author.Name().String(),
+			Snippet:    string(body),
+			Time:       msg.Time(),
+		})
+	}
+
+	if gts.App.Window == nil || gts.App.Window.IsActive() {
+		return
+	}
+
+	if time.Since(msg.Time()) > notifyMaxAge {
+		return
+	}
+
+	// Backends only mark other servers as mentioned, so keyword matches in
+	// the current one are marked here.
+	if !msg.Mentioned() {
+		v.ctrl.OnMessageMention()
+	}
+
+	n := glib.NotificationNew(author.Name().String())
+	n.SetBody(string(body))
+
+	// Use the server ID so that newer notifications from the same server
+	// replace older ones.
+	gts.App.SendNotification("mention:"+v.state.ServerID(), n)
+}
@@ -0,0 +1,293 @@
+// Package override provides local nicknames and name colors for authors. The
+// overrides are stored per session in the config directory.
+package override
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/namecolor"
+	"github.com/diamondburned/cchat/text"
+	"github.com/diamondburned/cchat/utils/empty"
+	"github.com/pkg/errors"
+)
+
+// Override is a local override for an author.
+type Override struct {
+	Nickname string `json:"nickname,omitempty"`
+	Color    uint32 `json:"color,omitempty"` // RGBA, 0 for none
+
+	// Name is the author's original name when the override was first set. It
+	// is used to match completion entries, which don't have author IDs.
+	Name string `json:"name,omitempty"`
+}
+
+// IsEmpty returns true if the override does not change anything.
+func (o Override) IsEmpty() bool {
+	return o.Nickname == "" && o.Color == 0
+}
+
+// sessionOverrides maps author IDs to overrides.
+type sessionOverrides map[string]Override
+
+var (
+	mutex    sync.Mutex
+	sessions = map[string]sessionOverrides{}
+)
+
+func filename(sessionID string) string {
+	return "overrides-" + url.PathEscape(sessionID) + ".json"
+}
+
+// load lazily loads the session's overrides. The mutex must be acquired.
+func load(sessionID string) sessionOverrides {
+	if o, ok := sessions[sessionID]; ok {
+		return o
+	}
+
+	var o = sessionOverrides{}
+
+	if err := config.UnmarshalFromFile(filename(sessionID), &o); err != nil {
+		log.Error(errors.Wrap(err, "Failed to load overrides"))
+	}
+
+	sessions[sessionID] = o
+	return o
+}
+
+// Get returns the override for the author in the given session.
+func Get(sessionID, authorID string) (Override, bool) {
+	if sessionID == "" {
+		return Override{}, false
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	o, ok := load(sessionID)[authorID]
+	return o, ok
+}
+
+// Set sets the override for the author in the given session and saves the
+// session's overrides. An empty override removes it.
+func Set(sessionID, authorID string, o Override) {
+	if sessionID == "" {
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	overrides := load(sessionID)
+
+	if o.IsEmpty() {
+		delete(overrides, authorID)
+	} else {
+		// Keep the first original name.
+		if old, ok := overrides[authorID]; ok && old.Name != "" {
+			o.Name = old.Name
+		}
+		overrides[authorID] = o
+	}
+
+	if err := config.MarshalToFile(filename(sessionID), overrides); err != nil {
+		log.Error(errors.Wrap(err, "Failed to save overrides"))
+	}
+}
+
+// Apply applies the override of the author in the given session to the given
+// name. The name is returned as-is if there's no override.
+func Apply(sessionID, authorID string, name text.Rich) text.Rich {
+	o, ok := Get(sessionID, authorID)
+	if !ok {
+		return name
+	}
+	return o.apply(name)
+}
+
+// ApplyByName applies the override with the matching original name in the
+// given session. It is used for things without author IDs, such as completion
+// entries.
+func ApplyByName(sessionID string, name text.Rich) (text.Rich, bool) {
+	if sessionID == "" || name.Content == "" {
+		return name, false
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, o := range load(sessionID) {
+		if o.Name == name.Content {
+			return o.apply(name), true
+		}
+	}
+
+	return name, false
+}
+
+func (o Override) apply(name text.Rich) text.Rich {
+	if o.Nickname == "" {
+		if o.Color != 0 {
+			// Add the color segment last, so it becomes the innermost one.
+			name.Segments = append(name.Segments[:len(name.Segments):len(name.Segments)],
+				segment{end: len(name.Content), color: o.Color},
+			)
+		}
+		return name
+	}
+
+	var seg = segment{end: len(o.Nickname), color: o.Color}
+
+	// Keep the mention, so the author's popover still works.
+	for _, s := range name.Segments {
+		if s.AsMentioner() != nil {
+			seg.mention = s
+			break
+		}
+	}
+
+	return text.Rich{
+		Content:  o.Nickname,
+		Segments: []text.Segment{seg},
+	}
+}
+
+// segment is a segment that covers the whole overridden name.
+type segment struct {
+	empty.TextSegment
+	start, end int
+	color      uint32
+	mention    text.Segment
+}
+
+func (s segment) Bounds() (int, int) { return s.start, s.end }
+
+func (s segment) Color() uint32 { return s.color }
+
+func (s segment) AsColorer() text.Colorer {
+	if s.color == 0 {
+		return nil
+	}
+	return s
+}
+
+func (s segment) AsMentioner() text.Mentioner {
+	if s.mention == nil {
+		return nil
+	}
+	return s.mention.AsMentioner()
+}
+
+func (s segment) AsAvatarer() text.Avatarer {
+	if s.mention == nil {
+		return nil
+	}
+	return s.mention.AsAvatarer()
+}
+
+// OriginalNamer is an interface for authors that keep their original names
+// after being overridden.
+type OriginalNamer interface {
+	OriginalName() text.Rich
+}
+
+// Author wraps a cchat.Author to apply the session's override to its name.
+type Author struct {
+	cchat.Author
+	SessionID string
+}
+
+var (
+	_ cchat.Author  = (*Author)(nil)
+	_ OriginalNamer = (*Author)(nil)
+)
+
+// WrapAuthor wraps the given author. Nil is returned if author is nil.
+func WrapAuthor(sessionID string, author cchat.Author) cchat.Author {
+	if author == nil {
+		return nil
+	}
+	return Author{author, sessionID}
+}
+
+// Name returns the overridden name. A generated color is added if the name
+// has none.
+func (a Author) Name() text.Rich {
+	name := Apply(a.SessionID, a.Author.ID(), a.OriginalName())
+	return namecolor.Apply(a.Author.ID(), name)
+}
+
+// OriginalName returns the name of the wrapped author before being
+// overridden.
+func (a Author) OriginalName() text.Rich {
+	if namer, ok := a.Author.(OriginalNamer); ok {
+		return namer.OriginalName()
+	}
+	return a.Author.Name()
+}
+
+type message struct {
+	cchat.MessageCreate
+	author cchat.Author
+}
+
+// WrapMessage wraps the given message so that its author is overridden.
+func WrapMessage(sessionID string, msg cchat.MessageCreate) cchat.MessageCreate {
+	return message{msg, WrapAuthor(sessionID, msg.Author())}
+}
+
+func (msg message) Author() cchat.Author { return msg.author }
+
+type member struct {
+	cchat.ListMember
+	sessionID string
+}
+
+// WrapMember wraps the given list member so that its name is overridden.
+func WrapMember(sessionID string, m cchat.ListMember) cchat.ListMember {
+	return member{m, sessionID}
+}
+
+func (m member) Name() text.Rich {
+	name := Apply(m.sessionID, m.ListMember.ID(), m.ListMember.Name())
+	return namecolor.Apply(m.ListMember.ID(), name)
+}
+
+type completer struct {
+	cchat.Completer
+	sessionID string
+}
+
+// WrapCompleter wraps the given completer so that the overrides are applied to
+// completion entries whose text matches an author's original name. The original
+// name is shown as the secondary text if there's none. Nil is returned if
+// the completer is nil.
+func WrapCompleter(sessionID string, c cchat.Completer) cchat.Completer {
+	if c == nil {
+		return nil
+	}
+	return completer{c, sessionID}
+}
+
+func (c completer) Complete(words []string, current int64) []cchat.CompletionEntry {
+	entries := c.Completer.Complete(words, current)
+	// Copy the entries, as the backend might reuse the slice.
+	entries = append([]cchat.CompletionEntry(nil), entries...)
+
+	for i, entry := range entries {
+		name, ok := ApplyByName(c.sessionID, entry.Text)
+		if !ok {
+			continue
+		}
+
+		if entry.Secondary.IsEmpty() {
+			entries[i].Secondary = text.Plain(entry.Text.Content)
+		}
+		entries[i].Text = name
+	}
+
+	return entries
+}
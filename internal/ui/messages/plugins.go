@@ -0,0 +1,170 @@
+package messages
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/plugin"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container"
+)
+
+// pluginMessage is the message in message events sent to plugins. Fields that
+// are not known are omitted.
+type pluginMessage struct {
+	Path      []cchat.ID `json:"path"`
+	ID        cchat.ID   `json:"id"`
+	AuthorID  cchat.ID   `json:"author_id,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	Time      time.Time  `json:"time"`
+	Mentioned bool       `json:"mentioned,omitempty"`
+}
+
+// pluginContainer wraps a messages container to publish live message events to
+// plugins. Messages fetched by scrolling up are not published.
+type pluginContainer struct {
+	cchat.MessagesContainer
+	path []cchat.ID
+}
+
+func (c pluginContainer) CreateMessage(msg cchat.MessageCreate) {
+	c.MessagesContainer.CreateMessage(msg)
+
+	if plugin.Connected() {
+		author := msg.Author()
+		plugin.Publish("message.create", pluginMessage{
+			Path:      c.path,
+			ID:        msg.ID(),
+			AuthorID:  author.ID(),
+			Author:    author.Name().String(),
+			Content:   msg.Content().String(),
+			Time:      msg.Time(),
+			Mentioned: msg.Mentioned(),
+		})
+	}
+}
+
+func (c pluginContainer) UpdateMessage(msg cchat.MessageUpdate) {
+	c.MessagesContainer.UpdateMessage(msg)
+
+	if plugin.Connected() {
+		ev := pluginMessage{
+			Path:    c.path,
+			ID:      msg.ID(),
+			Content: msg.Content().String(),
+			Time:    msg.Time(),
+		}
+		if author := msg.Author(); author != nil {
+			ev.AuthorID = author.ID()
+			ev.Author = author.Name().String()
+		}
+		plugin.Publish("message.update", ev)
+	}
+}
+
+func (c pluginContainer) DeleteMessage(msg cchat.MessageDelete) {
+	c.MessagesContainer.DeleteMessage(msg)
+
+	if plugin.Connected() {
+		plugin.Publish("message.delete", pluginMessage{
+			Path: c.path,
+			ID:   msg.ID(),
+			Time: msg.Time(),
+		})
+	}
+}
+
+type pluginTyper struct {
+	ID   cchat.ID `json:"id"`
+	Name string   `json:"name"`
+}
+
+// publishTypers publishes the list of typers in the current server.
+func (v *View) publishTypers(typers []cchat.Typer) {
+	if !plugin.Connected() || len(v.state.path) == 0 {
+		return
+	}
+
+	var ev = struct {
+		Path   []cchat.ID    `json:"path"`
+		Typers []pluginTyper `json:"typers"`
+	}{
+		Path:   v.state.path,
+		Typers: make([]pluginTyper, len(typers)),
+	}
+
+	for i, typer := range typers {
+		ev.Typers[i] = pluginTyper{typer.ID(), typer.Name().String()}
+	}
+
+	plugin.Publish("typing.changed", ev)
+}
+
+// publishNavigation publishes the current server. The path is null if there's
+// no server.
+func (v *View) publishNavigation() {
+	plugin.Publish("navigation.changed", struct {
+		Path       []cchat.ID `json:"path"`
+		Breadcrumb []string   `json:"breadcrumb"`
+	}{
+		Path:       v.state.path,
+		Breadcrumb: v.state.breadcrumb,
+	})
+}
+
+// annotationKey returns the key of the annotation for the given message.
+func annotationKey(path []cchat.ID, msgID cchat.ID) string {
+	return strings.Join(path, "\x00") + "\x00" + msgID
+}
+
+// bindAnnotation restores the plugin annotation of the message, if any.
+func (v *View) bindAnnotation(msg container.MessageRow) {
+	if annotation, ok := v.annotations[annotationKey(v.state.path, msg.ID())]; ok {
+		msg.SetAnnotation(annotation)
+	}
+}
+
+// handleAnnotate handles the messages.annotate plugin method, which sets a
+// local note on a message. The note is kept until the client exits, and an
+// empty text removes it.
+func (v *View) handleAnnotate(_ *plugin.Conn, params json.RawMessage) (interface{}, error) {
+	var annotation struct {
+		Path      []cchat.ID `json:"path"`
+		MessageID cchat.ID   `json:"message_id"`
+		Text      string     `json:"text"`
+	}
+
+	if err := plugin.DecodeParams(params, &annotation); err != nil {
+		return nil, err
+	}
+
+	if len(annotation.Path) == 0 || annotation.MessageID == "" {
+		return nil, &plugin.Error{
+			Code:    plugin.CodeInvalidParams,
+			Message: "missing path or message_id",
+		}
+	}
+
+	gts.ExecAsync(func() {
+		key := annotationKey(annotation.Path, annotation.MessageID)
+
+		if annotation.Text == "" {
+			delete(v.annotations, key)
+		} else {
+			v.annotations[key] = annotation.Text
+		}
+
+		// Update the message if it's shown.
+		if annotationKey(v.state.path, annotation.MessageID) != key {
+			return
+		}
+		if msg := v.Container.Message(annotation.MessageID, ""); msg != nil {
+			msg.SetAnnotation(annotation.Text)
+		}
+	})
+
+	return nil, nil
+}
@@ -0,0 +1,123 @@
+package messages
+
+import (
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/filter"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/override"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// ExtendAuthorPopover adds author actions into the author's popover.
+func (v *View) ExtendAuthorPopover(author cchat.Author, popover *gtk.Popover, box *gtk.Box) {
+	if author == nil {
+		return
+	}
+
+	sep, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
+	sep.Show()
+	box.PackStart(sep, false, false, 0)
+
+	if sessionID := v.state.SessionID(); sessionID != "" {
+		box.PackStart(v.overrideEditor(popover, sessionID, author), false, false, 0)
+
+		// Don't allow ignoring ourselves.
+		if author.ID() != sessionID {
+			box.PackStart(ignoreButton(popover, "This Session", sessionID, author.ID()), false, false, 0)
+			box.PackStart(ignoreButton(popover, "Everywhere", "", author.ID()), false, false, 0)
+		}
+	}
+}
+
+// ignoreButton creates a button that toggles the ignore state of the author in
+// the given scope.
+func ignoreButton(popover *gtk.Popover, scope, sessionID, authorID string) *gtk.Button {
+	var label string
+	var ignored = filter.IsAuthorIgnored(sessionID, authorID)
+
+	if ignored {
+		label = "Unignore " + scope
+	} else {
+		label = "Ignore " + scope
+	}
+
+	btn, _ := gtk.ButtonNewWithLabel(label)
+	btn.SetRelief(gtk.RELIEF_NONE)
+	btn.Show()
+	btn.Connect("clicked", func(*gtk.Button) {
+		if ignored {
+			filter.UnignoreAuthor(sessionID, authorID)
+		} else {
+			filter.IgnoreAuthor(sessionID, authorID)
+		}
+		popover.Popdown()
+	})
+
+	return btn
+}
+
+// overrideEditor creates a small form to set the author's local nickname and
+// name color.
+func (v *View) overrideEditor(popover *gtk.Popover, sessionID string, author cchat.Author) gtk.IWidget {
+	var original = author.Name()
+	if namer, ok := author.(override.OriginalNamer); ok {
+		original = namer.OriginalName()
+	}
+
+	current, _ := override.Get(sessionID, author.ID())
+
+	nickname, _ := gtk.EntryNew()
+	nickname.SetPlaceholderText(original.Content)
+	nickname.SetText(current.Nickname)
+	nickname.SetTooltipText("Local nickname")
+	nickname.SetHExpand(true)
+	nickname.Show()
+
+	var color *gtk.ColorButton
+	if current.Color != 0 {
+		color, _ = gtk.ColorButtonNewWithRGBA(primitives.GdkRGBA(current.Color))
+	} else {
+		color, _ = gtk.ColorButtonNew()
+	}
+	color.SetUseAlpha(false)
+	color.SetTooltipText("Local name color")
+	color.Show()
+
+	var colorSet = current.Color != 0
+	color.Connect("color-set", func(*gtk.ColorButton) { colorSet = true })
+
+	var apply = func(o override.Override) {
+		o.Name = original.Content
+		override.Set(sessionID, author.ID(), o)
+		v.Container.RefreshAuthor(author.ID())
+		popover.Popdown()
+	}
+
+	set, _ := gtk.ButtonNewFromIconName("object-select-symbolic", gtk.ICON_SIZE_BUTTON)
+	set.SetTooltipText("Set")
+	set.Show()
+	set.Connect("clicked", func(*gtk.Button) {
+		var o override.Override
+		o.Nickname, _ = nickname.GetText()
+		if colorSet {
+			o.Color = primitives.RGBA(color.GetRGBA())
+		}
+		apply(o)
+	})
+	nickname.Connect("activate", func(*gtk.Entry) { set.Clicked() })
+
+	reset, _ := gtk.ButtonNewFromIconName("edit-clear-symbolic", gtk.ICON_SIZE_BUTTON)
+	reset.SetTooltipText("Reset")
+	reset.SetSensitive(!current.IsEmpty())
+	reset.Show()
+	reset.Connect("clicked", func(*gtk.Button) { apply(override.Override{}) })
+
+	form, _ := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 2)
+	form.PackStart(nickname, true, true, 0)
+	form.PackStart(color, false, false, 0)
+	form.PackStart(set, false, false, 0)
+	form.PackStart(reset, false, false, 0)
+	form.Show()
+
+	return form
+}
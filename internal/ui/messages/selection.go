@@ -0,0 +1,150 @@
+package messages
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/dialog"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/menu"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/markdown"
+	"github.com/gotk3/gotk3/gtk"
+	"github.com/pkg/errors"
+)
+
+// SelectMessages is called when multiple messages are selected.
+func (v *View) SelectMessages(_ *container.ListStore, msgs []container.MessageRow) {
+	v.Header.MessageCtrl.EnableMultiple(v.deleteAllFunc(msgs))
+	v.Header.MessageCtrl.SetSelectionItems(v.selectionItems(msgs))
+}
+
+// selectionItems returns the menu items for actions on the given selected
+// messages.
+func (v *View) selectionItems(msgs []container.MessageRow) []menu.Item {
+	var items = []menu.Item{
+		menu.SimpleItem("Copy as Plain Text", func() {
+			gts.Clipboard.SetText(formatMessages(msgs, false))
+		}),
+		menu.SimpleItem("Copy as Markdown", func() {
+			gts.Clipboard.SetText(formatMessages(msgs, true))
+		}),
+	}
+
+	// Reply to the latest selected message that's not deleted.
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Deleted() {
+			continue
+		}
+
+		replyID := msgs[i].ID()
+		items = append(items, menu.SimpleItem("Quote in Reply", func() {
+			v.InputView.StartQuoting(replyID, formatQuote(msgs))
+		}))
+
+		break
+	}
+
+	return items
+}
+
+// deleteAllFunc returns a callback that deletes all given messages that can be
+// deleted after confirming. Nil is returned if none of the messages can be
+// deleted.
+func (v *View) deleteAllFunc(msgs []container.MessageRow) func() {
+	if !v.hasActions() {
+		return nil
+	}
+
+	var actioner = v.actioner
+	var ids = make([]cchat.ID, 0, len(msgs))
+
+	for _, msg := range msgs {
+		if msg.Deleted() {
+			continue
+		}
+
+		for _, action := range actioner.Actions(msg.ID()) {
+			if action == messageItemNames.Delete {
+				ids = append(ids, msg.ID())
+				break
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return func() {
+		l, _ := gtk.LabelNew(fmt.Sprintf("Delete %d messages?", len(ids)))
+		l.Show()
+
+		dlg := dialog.NewModal(l, "Delete Messages", "_Delete", func(m *dialog.Modal) {
+			m.Destroy()
+
+			go func() {
+				for _, id := range ids {
+					err := actioner.Do(messageItemNames.Delete, id)
+					log.Error(errors.Wrap(err, "Failed to delete message "+id))
+				}
+			}()
+		})
+		dlg.SetSizeRequest(300, 100)
+		primitives.AddClass(dlg.Action, "destructive-action")
+		dlg.Show()
+	}
+}
+
+// formatMessages formats the messages with a header line of the author and the
+// timestamp for each message. The content is formatted as Markdown if
+// markdown is true.
+func formatMessages(msgs []container.MessageRow, md bool) string {
+	var builder strings.Builder
+
+	for i, msg := range msgs {
+		if i > 0 {
+			builder.WriteString("\n\n")
+		}
+
+		builder.WriteString(msg.Author().Name().String())
+		builder.WriteString(" — ")
+		builder.WriteString(msg.Time().Local().Format("2006-01-02 15:04"))
+		builder.WriteByte('\n')
+
+		if md {
+			builder.WriteString(markdown.Render(msg.RichContent()))
+		} else {
+			builder.WriteString(msg.RichContent().Content)
+		}
+	}
+
+	return builder.String()
+}
+
+// formatQuote formats the messages as a Markdown quote. Author names are only
+// added if there's more than one message.
+func formatQuote(msgs []container.MessageRow) string {
+	var builder strings.Builder
+
+	for _, msg := range msgs {
+		if len(msgs) > 1 {
+			builder.WriteString("> **")
+			builder.WriteString(msg.Author().Name().String())
+			builder.WriteString("**:\n")
+		}
+
+		content := markdown.Render(msg.RichContent())
+		for _, line := range strings.Split(content, "\n") {
+			builder.WriteString("> ")
+			builder.WriteString(line)
+			builder.WriteByte('\n')
+		}
+	}
+
+	builder.WriteByte('\n')
+	return builder.String()
+}
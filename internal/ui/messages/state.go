@@ -1,7 +1,7 @@
 package messages
 
 import (
-	"time"
+	"context"
 
 	"github.com/diamondburned/cchat"
 )
@@ -22,17 +22,91 @@ type state struct {
 	current func() // stop callback
 	author  string
 
-	lastBacklogged time.Time
+	// path and breadcrumb are the ID and name paths from the service down to
+	// the server.
+	path       []cchat.ID
+	breadcrumb []string
+
+	// backlogging is true while older messages are being fetched, and
+	// backlogEnd is true once there are no more of them. backlogFailed is true
+	// if the last fetch failed and hasn't been retried.
+	backlogging   bool
+	backlogEnd    bool
+	backlogFailed bool
+	// backlogOldest is the oldest message fetched from the backlog, which
+	// may be hidden by the filters.
+	backlogOldest cchat.MessageHeader
+
+	// joining cancels the pending JoinServer call, if any.
+	joining context.CancelFunc
+	// generation is bumped on every reset, so that asynchronous callbacks can
+	// tell if the state they started with is stale.
+	generation uint64
+	// live is cancelled on reset. Unlike generation, it can be checked from
+	// any goroutine.
+	live       context.Context
+	cancelLive context.CancelFunc
 }
 
 func (s *state) Reset() {
+	// Cancel the pending join, if any. Its result will be discarded.
+	if s.joining != nil {
+		s.joining()
+	}
+
+	// Drop the events that the backend still sends for this state.
+	if s.cancelLive != nil {
+		s.cancelLive()
+	}
+
 	// If we still have the last server to leave, then leave it.
 	if s.current != nil {
 		s.current()
 	}
 
 	// Lazy way to reset the state.
-	*s = state{}
+	*s = state{generation: s.generation + 1}
+}
+
+// stale returns true if the state has been reset since the given generation.
+func (s *state) stale(generation uint64) bool {
+	return s.generation != generation
+}
+
+// events wraps the container to drop the message events that come after the
+// state is reset. Backends may still send events after the server is left,
+// which would otherwise end up in the next server.
+func (s *state) events(c cchat.MessagesContainer) cchat.MessagesContainer {
+	if s.live == nil {
+		s.live, s.cancelLive = context.WithCancel(context.Background())
+	}
+
+	return liveContainer{c, s.live}
+}
+
+// liveContainer is a messages container that drops events once its context is
+// done.
+type liveContainer struct {
+	cchat.MessagesContainer
+	ctx context.Context
+}
+
+func (c liveContainer) CreateMessage(msg cchat.MessageCreate) {
+	if c.ctx.Err() == nil {
+		c.MessagesContainer.CreateMessage(msg)
+	}
+}
+
+func (c liveContainer) UpdateMessage(msg cchat.MessageUpdate) {
+	if c.ctx.Err() == nil {
+		c.MessagesContainer.UpdateMessage(msg)
+	}
+}
+
+func (c liveContainer) DeleteMessage(msg cchat.MessageDelete) {
+	if c.ctx.Err() == nil {
+		c.MessagesContainer.DeleteMessage(msg)
+	}
 }
 
 func (s *state) hasActions() bool {
@@ -55,22 +129,31 @@ func (s *state) ServerID() string {
 	return ""
 }
 
-const backloggingFreq = time.Second * 3
+// Session returns the current session, or nil if there's none.
+func (s *state) Session() cchat.Session { return s.session }
+
+// Server returns the current server, or nil if there's none.
+func (s *state) Server() cchat.Server { return s.server }
+
+// Path returns the path of IDs from the service down to the current server.
+func (s *state) Path() []cchat.ID { return s.path }
+
+// Breadcrumb returns the path of names from the service down to the current
+// server.
+func (s *state) Breadcrumb() []string { return s.breadcrumb }
 
 // Backlogger returns the backlogger instance if it's allowed to fetch more
-// backlogs.
+// backlogs, that is if the server is joined, nothing is being fetched, the last
+// fetch didn't fail and the beginning of the server hasn't been reached.
 func (s *state) Backlogger() cchat.Backlogger {
 	if s.backlogger == nil || s.current == nil {
 		return nil
 	}
 
-	var now = time.Now()
-
-	if s.lastBacklogged.Add(backloggingFreq).After(now) {
+	if s.backlogging || s.backlogEnd || s.backlogFailed {
 		return nil
 	}
 
-	s.lastBacklogged = now
 	return s.backlogger
 }
 
@@ -82,5 +165,6 @@ func (s *state) bind(session cchat.Session, server cchat.Server, msgr cchat.Mess
 }
 
 func (s *state) setcurrent(fn func()) {
+	s.joining = nil
 	s.current = fn
 }
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/namecolor"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/markup"
 	"github.com/diamondburned/handy"
@@ -52,6 +53,8 @@ type Container struct {
 	borrow bool
 	// markup stores the label if the label view is not borrowed.
 	markup string
+
+	onChanged func(typers []cchat.Typer)
 }
 
 func New() *Container {
@@ -102,6 +105,10 @@ func New() *Container {
 			r.SetRevealChild(!empty)
 			l.SetMarkup(container.markup)
 		}
+
+		if container.onChanged != nil {
+			container.onChanged(s.typers)
+		}
 	})
 
 	// On label destroy, stop the state loop as well.
@@ -110,6 +117,12 @@ func New() *Container {
 	return container
 }
 
+// OnChanged sets the callback that's called when the list of typers changes.
+// The callback must not keep the given slice.
+func (c *Container) OnChanged(fn func(typers []cchat.Typer)) {
+	c.onChanged = fn
+}
+
 func (c *Container) Reset() {
 	c.state.reset()
 	c.SetRevealChild(false)
@@ -164,7 +177,8 @@ func render(typers []cchat.Typer) string {
 	var builder strings.Builder
 
 	for i, typer := range typers {
-		output := markup.RenderCmplxWithConfig(typer.Name(), noMentionLinks)
+		name := namecolor.Apply(typer.ID(), typer.Name())
+		output := markup.RenderCmplxWithConfig(name, noMentionLinks)
 
 		builder.WriteString("<b>")
 		builder.WriteString(output.Markup)
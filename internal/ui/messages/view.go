@@ -2,17 +2,19 @@ package messages
 
 import (
 	"context"
-	"runtime"
-	"time"
 
 	"github.com/diamondburned/cchat"
 	"github.com/diamondburned/cchat-gtk/icons"
 	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/gts/httputil"
 	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/plugin"
 	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/diamondburned/cchat-gtk/internal/ui/deeplink"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container/compact"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/container/cozy"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/filter"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/input"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/memberlist"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages/sadface"
@@ -21,6 +23,7 @@ import (
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/autoscroll"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/drag"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/menu"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/keyword"
 	"github.com/diamondburned/cchat-gtk/internal/ui/service/session/server/traverse"
 	"github.com/diamondburned/handy"
 	"github.com/gotk3/gotk3/gtk"
@@ -40,17 +43,26 @@ func init() {
 		[]string{"Cozy", "Compact"},
 		nil,
 	))
+	config.AppearanceAdd("Show Deleted Messages", config.Switch(
+		&container.ShowDeleted,
+		nil,
+	))
 }
 
 type Controller interface {
 	// GoBack tells the main leaflet to go back to the services list.
 	GoBack()
 	// OnMessageBusy is called when the message buffer is busy. This happens
-	// when it's loading messages.
+	// while it joins a server. The user may still switch to another server
+	// in the meantime.
 	OnMessageBusy()
 	// OnMessageDone is called after OnMessageBusy, when the message buffer is
-	// done with loading.
+	// done with loading or when the loading is cancelled. It may be called
+	// more than once.
 	OnMessageDone()
+	// OnMessageMention is called when a new message in the current server
+	// matches a keyword highlight rule while the window isn't focused.
+	OnMessageMention()
 }
 
 type MessagesContainer interface {
@@ -71,10 +83,11 @@ type View struct {
 	Scroller  *autoscroll.ScrolledWindow
 	InputView *input.InputView
 
-	MsgBox    *gtk.Box
-	Typing    *typing.Container
-	Container MessagesContainer
-	contType  int // msgIndex
+	MsgBox        *gtk.Box
+	BacklogStatus *backlogStatus
+	Typing        *typing.Container
+	Container     MessagesContainer
+	contType      int // msgIndex
 
 	MemberList *memberlist.Container // right box
 
@@ -83,6 +96,12 @@ type View struct {
 
 	ctrl         Controller
 	parentFolded bool // folded state
+
+	// seekID is the ID of the message to scroll to after joining the server.
+	seekID cchat.ID
+
+	// annotations maps message keys from annotationKey to plugin annotations.
+	annotations map[string]string
 }
 
 var messageStack = primitives.PrepareClassCSS("message-stack", `
@@ -95,17 +114,22 @@ var messageScroller = primitives.PrepareClassCSS("message-scroller", ``)
 
 func NewView(c Controller) *View {
 	view := &View{
-		ctrl:     c,
-		contType: -1, // force recreate
+		ctrl:        c,
+		contType:    -1, // force recreate
+		annotations: map[string]string{},
 	}
 
 	view.Typing = typing.New()
+	view.Typing.OnChanged(view.publishTypers)
 	view.Typing.Show()
 
 	view.MemberList = memberlist.New(view)
 	view.MemberList.Show()
 
+	view.BacklogStatus = newBacklogStatus(view.retryBacklog)
+
 	view.MsgBox, _ = gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 2)
+	view.MsgBox.PackStart(view.BacklogStatus, false, false, 0)
 	view.MsgBox.PackEnd(view.Typing, false, false, 0)
 	view.MsgBox.Show()
 
@@ -123,7 +147,16 @@ func NewView(c Controller) *View {
 	// TOP of the typing indicator.
 	view.createMessageContainer()
 
-	// Fetch the message backlog when the user has scrolled to the top.
+	// Apply changed filters and highlight rules on the messages that are
+	// already shown.
+	filter.OnChange(view.refilter)
+	keyword.OnChange(view.rehighlight)
+
+	// Fetch the message backlog when the user has scrolled close to the top.
+	// The edge is still checked for when the messages don't fill the screen.
+	view.Scroller.GetVAdjustment().Connect("value-changed", func(*gtk.Adjustment) {
+		view.prefetchBacklog()
+	})
 	view.Scroller.Connect("edge-reached", func(_ *gtk.ScrolledWindow, p gtk.PositionType) {
 		if p == gtk.POS_TOP {
 			view.FetchBacklog()
@@ -190,9 +223,31 @@ func NewView(c Controller) *View {
 	view.Box.PackStart(view.Header, false, false, 0)
 	view.Box.PackStart(view.FaceView, true, true, 0)
 
+	plugin.Handle("messages.annotate", view.handleAnnotate)
+
 	return view
 }
 
+// refilter applies the filters again on the messages in the container, and
+// creates the ones that were hidden but aren't anymore.
+func (v *View) refilter() {
+	if v.Container == nil {
+		return
+	}
+
+	for _, msg := range v.Container.Refilter() {
+		v.Container.CreateMessage(msg)
+	}
+}
+
+// rehighlight renders the messages in the container again with the current
+// keyword highlight rules.
+func (v *View) rehighlight() {
+	if v.Container != nil {
+		v.Container.RerenderContent()
+	}
+}
+
 func (v *View) createMessageContainer() {
 	// If we still want the same type of message container, then we don't need
 	// to remake a new one.
@@ -228,15 +283,21 @@ func (v *View) Bottomed() bool { return v.Scroller.Bottomed }
 func (v *View) Reset() {
 	v.FaceView.Reset() // Switch back to the main screen.
 	v.reset()
+	v.publishNavigation()
 }
 
 // reset resets the message view, but does not change visible containers.
 func (v *View) reset() {
-	v.state.Reset()      // Reset the state variables.
-	v.Header.Reset()     // Reset the header.
-	v.Typing.Reset()     // Reset the typing state.
-	v.InputView.Reset()  // Reset the input.
-	v.MemberList.Reset() // Reset the member list.
+	v.state.Reset()        // Reset the state variables.
+	v.ctrl.OnMessageDone() // Stop showing the cancelled joins as busy.
+	v.Header.Reset()       // Reset the header.
+	v.Typing.Reset()       // Reset the typing state.
+	v.InputView.Reset()    // Reset the input.
+	v.MemberList.Reset()   // Reset the member list.
+
+	// Hide the older messages status and stop keeping the scroll position.
+	v.BacklogStatus.Reset()
+	v.Scroller.Unanchor()
 
 	// Bring the leaflet view back to the message.
 	v.Leaflet.SetVisibleChild(v.LeftBox)
@@ -280,13 +341,14 @@ func (v *View) MemberListUpdated(c *memberlist.Container) {
 }
 
 // JoinServer is not thread-safe, but it calls backend functions asynchronously.
+// Joining another server or resetting the view cancels the pending join.
 func (v *View) JoinServer(session cchat.Session, server cchat.Server, bc traverse.Breadcrumber) {
+	// Reset before setting. This also cancels the pending join, if any.
+	v.reset()
+	v.seekID = ""
+
 	// Set the screen to loading.
 	v.FaceView.SetLoading()
-	v.ctrl.OnMessageBusy()
-
-	// Reset before setting.
-	v.reset()
 
 	// Get the messenger once.
 	var messenger = server.AsMessenger()
@@ -295,33 +357,54 @@ func (v *View) JoinServer(session cchat.Session, server cchat.Server, bc travers
 		return
 	}
 
+	v.ctrl.OnMessageBusy()
+
 	// Bind the state.
 	v.state.bind(session, server, messenger)
+	v.state.path = traverse.TryID(bc)
+	v.state.breadcrumb = traverse.TryBreadcrumb(bc)
+	v.publishNavigation()
+
+	// Publish live message events to plugins.
+	var list = v.Container
+	var msgc = v.state.events(pluginContainer{list, v.state.path})
 
 	// We're setting this variable before actually calling JoinServer. This is
 	// because new messages created by JoinServer will use this state for things
 	// such as determinining if it's deletable or not.
 	v.InputView.SetMessenger(session, messenger)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	v.state.joining = cancel
+
+	// Remember which join this is, so the result isn't applied if the user has
+	// already switched away.
+	var generation = v.state.generation
+
 	go func() {
-		// We can use a background context here, as the user can't go anywhere
-		// that would require cancellation anyway. This is done in ui.go.
-		s, err := messenger.JoinServer(context.Background(), v.Container)
-		if err != nil {
-			log.Error(errors.Wrap(err, "Failed to join server"))
-			// Even if we're erroring out, we're running the done() callback
-			// anyway.
-			gts.ExecAsync(func() {
-				v.ctrl.OnMessageDone()
-				v.FaceView.SetError(err)
-			})
-			return
-		}
+		s, err := messenger.JoinServer(ctx, msgc)
+
+		// Queue this after the initial messages, so that they're inserted
+		// before the message to seek to is looked up.
+		list.Queue(func() {
+			if v.state.stale(generation) {
+				// Leave the server that we're no longer viewing.
+				if err == nil && s != nil {
+					s()
+				}
+				return
+			}
 
-		gts.ExecAsync(func() {
 			// Run the done() callback.
 			v.ctrl.OnMessageDone()
 
+			if err != nil {
+				v.state.joining = nil
+				log.Error(errors.Wrap(err, "Failed to join server"))
+				v.FaceView.SetError(err)
+				return
+			}
+
 			// Set the screen to the main one.
 			v.FaceView.SetMain()
 
@@ -336,40 +419,17 @@ func (v *View) JoinServer(session cchat.Session, server cchat.Server, bc travers
 
 			// Try and use the list.
 			v.MemberList.TryAsyncList(messenger)
-		})
-
-		// Collect garbage after a channel switch since a lot of images will
-		// need to be freed.
-		runtime.GC()
-	}()
-}
-
-func (v *View) FetchBacklog() {
-	var backlogger = v.state.Backlogger()
-	if backlogger == nil {
-		return
-	}
-
-	var firstMsg = v.Container.FirstMessage()
-	if firstMsg == nil {
-		return
-	}
 
-	// Set the window as busy. TODO: loading circles.
-	v.ctrl.OnMessageBusy()
-
-	var done = func() {
-		v.ctrl.OnMessageDone()
-		v.Container.Highlight(firstMsg)
-	}
-
-	gts.Async(func() (func(), error) {
-		ctx, cancel := context.WithTimeout(context.TODO(), 3*time.Second)
-		defer cancel()
+			// Scroll to the message that's requested before joining, if any.
+			if v.seekID != "" {
+				v.seekMessage(v.seekID, seekMaxBacklogs)
+				v.seekID = ""
+			}
 
-		err := backlogger.Backlog(ctx, firstMsg.ID(), v.Container)
-		return done, errors.Wrap(err, "Failed to get messages before ID")
-	})
+			// Release the images of the views that aren't shown anymore.
+			httputil.ReleaseHidden()
+		})
+	}()
 }
 
 func (v *View) AddPresendMessage(msg input.PresendMessage) func(error) {
@@ -449,6 +509,14 @@ var messageItemNames = MessageItemNames{
 // BindMenu attaches the menu constructor into the message with the needed
 // states and callbacks.
 func (v *View) BindMenu(msg container.MessageRow) {
+	v.bindAnnotation(msg)
+
+	// Deleted messages can't be replied to or edited.
+	if msg.Deleted() {
+		msg.AttachMenu(nil)
+		return
+	}
+
 	// Add 1 for the edit menu item.
 	var mitems = []menu.Item{
 		menu.SimpleItem(
@@ -456,6 +524,16 @@ func (v *View) BindMenu(msg container.MessageRow) {
 		),
 	}
 
+	// Messages can only be bookmarked or linked to if we know where they are.
+	if len(v.state.path) > 0 {
+		var link = deeplink.Format(v.state.path, msg.ID())
+
+		mitems = append(mitems,
+			menu.SimpleItem("Bookmark", func() { v.addBookmark(msg) }),
+			menu.SimpleItem("Copy Link", func() { gts.Clipboard.SetText(link) }),
+		)
+	}
+
 	// Do we have editing capabilities? If yes, append a button to allow it.
 	if v.InputView.Editable(msg.ID()) {
 		mitems = append(mitems, menu.SimpleItem(
@@ -495,6 +573,7 @@ func (v *View) makeActionItem(action, msgID string) menu.Item {
 func (v *View) SelectMessage(_ *container.ListStore, msg container.MessageRow) {
 	// Hijack the message's action list to search for what we have above.
 	v.Header.MessageCtrl.Enable(msg, messageItemNames)
+	v.Header.MessageCtrl.SetSelectionItems(v.selectionItems([]container.MessageRow{msg}))
 }
 
 // UnselectMessage is called when the message selection is cleared.
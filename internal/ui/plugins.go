@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"encoding/json"
+
+	"github.com/diamondburned/cchat"
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/plugin"
+	"github.com/diamondburned/cchat-gtk/internal/ui/service"
+	"github.com/diamondburned/cchat-gtk/internal/ui/service/session"
+	"github.com/diamondburned/cchat-gtk/internal/ui/service/session/server"
+	"github.com/diamondburned/cchat-gtk/internal/ui/service/session/server/commander"
+	"github.com/pkg/errors"
+)
+
+// bindPlugins registers the plugin methods that need the application, as well
+// as the action to show the plugin command prompt.
+func (app *App) bindPlugins() {
+	plugin.Handle("messages.send", app.handleSend)
+	plugin.Handle("navigation.open", app.handleOpen)
+
+	var cmds = commander.NewBuffer("Plugins", plugin.Commander)
+	gts.AddAppAction("plugin-commands", cmds.ShowDialog)
+}
+
+// pluginPath is the params of plugin methods that take a server path.
+type pluginPath struct {
+	Path      []cchat.ID `json:"path"`
+	MessageID cchat.ID   `json:"message_id,omitempty"`
+}
+
+// handleOpen handles the navigation.open plugin method, which opens the server
+// and scrolls to the message if message_id is given.
+func (app *App) handleOpen(_ *plugin.Conn, params json.RawMessage) (interface{}, error) {
+	var p pluginPath
+	if err := plugin.DecodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	gts.ExecAsync(func() { app.OpenMessage(p.Path, p.MessageID) })
+	return nil, nil
+}
+
+// handleSend handles the messages.send plugin method, which sends a message
+// into the server. It returns after the message is sent.
+func (app *App) handleSend(_ *plugin.Conn, params json.RawMessage) (interface{}, error) {
+	var msg pluginSendable
+	if err := plugin.DecodeParams(params, &msg); err != nil {
+		return nil, err
+	}
+
+	var senderCh = make(chan cchat.Sender, 1)
+	var errCh = make(chan error, 1)
+
+	gts.ExecAsync(func() {
+		app.findServer(msg.Path, false, func(_ *service.Service, _ *session.Row, srv *server.ServerRow, err error) {
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			sender := srv.Server.AsMessenger().AsSender()
+			if sender == nil {
+				errCh <- errors.New("Server is read-only")
+				return
+			}
+
+			senderCh <- sender
+		})
+	})
+
+	select {
+	case sender := <-senderCh:
+		return nil, errors.Wrap(sender.Send(msg), "Failed to send message")
+	case err := <-errCh:
+		return nil, &plugin.Error{Code: plugin.CodeInvalidParams, Message: err.Error()}
+	}
+}
+
+// pluginSendable is a message sent by a plugin.
+type pluginSendable struct {
+	Path    []cchat.ID `json:"path"`
+	Text    string     `json:"content"`
+	ReplyTo cchat.ID   `json:"reply_to,omitempty"`
+}
+
+var (
+	_ cchat.SendableMessage = pluginSendable{}
+	_ cchat.Replier         = pluginSendable{}
+)
+
+func (s pluginSendable) Content() string            { return s.Text }
+func (s pluginSendable) AsNoncer() cchat.Noncer     { return nil }
+func (s pluginSendable) AsAttacher() cchat.Attacher { return nil }
+func (s pluginSendable) ReplyingTo() cchat.ID       { return s.ReplyTo }
+
+func (s pluginSendable) AsReplier() cchat.Replier {
+	if s.ReplyTo == "" {
+		return nil
+	}
+	return s
+}
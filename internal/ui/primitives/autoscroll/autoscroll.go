@@ -6,6 +6,12 @@ type ScrolledWindow struct {
 	gtk.ScrolledWindow
 	vadj     *gtk.Adjustment
 	Bottomed bool // :floshed:
+
+	// anchor is the distance from the bottom to keep while anchored, or a
+	// negative number if not anchored.
+	anchor float64
+	// anchoring is true while the value is set to keep the anchor.
+	anchoring bool
 }
 
 func NewScrolledWindow() *ScrolledWindow {
@@ -13,16 +19,30 @@ func NewScrolledWindow() *ScrolledWindow {
 	gtksw.SetProperty("propagate-natural-height", true)
 	gtksw.SetProperty("window-placement", gtk.CORNER_BOTTOM_LEFT)
 
-	sw := &ScrolledWindow{*gtksw, gtksw.GetVAdjustment(), true} // bottomed by default
+	sw := &ScrolledWindow{*gtksw, gtksw.GetVAdjustment(), true, -1, false} // bottomed by default
 	sw.Connect("size-allocate", func(_ *gtk.ScrolledWindow) {
 		// We can't really trust Gtk to be competent.
 		if sw.Bottomed {
 			sw.ScrollToBottom()
 		}
 	})
+	sw.vadj.Connect("changed", func(adj *gtk.Adjustment) {
+		// Keep the same content in view if rows were added above it.
+		if sw.anchor >= 0 && !sw.Bottomed {
+			sw.anchoring = true
+			adj.SetValue(adj.GetUpper() - sw.anchor)
+			sw.anchoring = false
+		}
+	})
 	sw.vadj.Connect("value-changed", func(adj *gtk.Adjustment) {
 		// Manually check if we're anchored on scroll.
 		sw.Bottomed = (adj.GetUpper() - adj.GetPageSize()) <= adj.GetValue()
+
+		// Follow the user's scrolling while anchored, so that the view isn't
+		// thrown back to where it was anchored.
+		if sw.anchor >= 0 && !sw.anchoring {
+			sw.anchor = adj.GetUpper() - adj.GetValue()
+		}
 	})
 
 	return sw
@@ -36,3 +56,14 @@ func (s *ScrolledWindow) GetVAdjustment() *gtk.Adjustment {
 func (s *ScrolledWindow) ScrollToBottom() {
 	s.vadj.SetValue(s.vadj.GetUpper())
 }
+
+// Anchor keeps the view at the same distance from the bottom until Unanchor is
+// called. This is useful when rows are inserted above the visible ones.
+func (s *ScrolledWindow) Anchor() {
+	s.anchor = s.vadj.GetUpper() - s.vadj.GetValue()
+}
+
+// Unanchor stops keeping the view in place.
+func (s *ScrolledWindow) Unanchor() {
+	s.anchor = -1
+}
@@ -7,12 +7,12 @@ import (
 	"github.com/diamondburned/cchat"
 	"github.com/diamondburned/cchat-gtk/internal/gts/httputil"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/roundimage"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/scrollinput"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/markup"
 	"github.com/diamondburned/cchat/text"
 	"github.com/diamondburned/cchat/utils/split"
-	"github.com/diamondburned/imgutil"
 	"github.com/gotk3/gotk3/gtk"
 )
 
@@ -23,7 +23,7 @@ const (
 )
 
 // post-processor icon
-var ppIcon = []imgutil.Processor{imgutil.Round(true)}
+var ppIcon = []httputil.Processor{httputil.Round(true)}
 
 type Completer struct {
 	Input   *gtk.TextView
@@ -214,15 +214,24 @@ func (c *Completer) update() []gtk.IWidget {
 
 		// Do we have an icon?
 		if entry.IconURL != "" {
-			img, _ := gtk.ImageNew()
+			// Use an image that isn't rounded, which still shows a
+			// placeholder while it's blocked.
+			img, _ := roundimage.NewImage(-1)
 			img.SetMarginStart(ImagePadding)
 			img.SetSizeRequest(size, size)
 			img.Show()
 
+			// The image has no window, so right-clicks are taken from an
+			// event box around it.
+			evbox, _ := gtk.EventBoxNew()
+			evbox.Add(img)
+			evbox.Show()
+			img.ConnectHandlers(evbox)
+
 			// Prepend the image into the box.
-			b.PackEnd(img, false, false, 0)
+			b.PackEnd(evbox, false, false, 0)
 
-			var pps []imgutil.Processor
+			var pps []httputil.Processor
 			if !entry.Image {
 				pps = ppIcon
 			}
@@ -371,3 +371,22 @@ func LeafletOnFold(leaflet *handy.Leaflet, foldedFn func(folded bool)) {
 		foldedFn(leaflet.GetFolded())
 	})
 }
+
+// RGBA converts the given Gdk color into a 32-bit RGBA color.
+func RGBA(c *gdk.RGBA) uint32 {
+	var color uint32
+	for _, v := range c.Floats() { // [0.0, 1.0]
+		color = (color << 8) + uint32(v*0xFF)
+	}
+	return color
+}
+
+// GdkRGBA converts the given 32-bit RGBA color into a Gdk color.
+func GdkRGBA(rgba uint32) *gdk.RGBA {
+	return gdk.NewRGBA(
+		float64(rgba>>24&0xFF)/0xFF,
+		float64(rgba>>16&0xFF)/0xFF,
+		float64(rgba>>8&0xFF)/0xFF,
+		float64(rgba&0xFF)/0xFF,
+	)
+}
@@ -28,6 +28,8 @@ type Avatar struct {
 	pixbuf *gdk.Pixbuf
 	url    string
 	size   int
+	// blocked is the URL of the avatar if it's blocked.
+	blocked string
 }
 
 // Make a better API that allows scaling.
@@ -36,6 +38,7 @@ var (
 	_ Imager                  = (*Avatar)(nil)
 	_ TextSetter              = (*Avatar)(nil)
 	_ httputil.ImageContainer = (*Avatar)(nil)
+	_ Connector               = (*Avatar)(nil)
 )
 
 func NewAvatar(size int) *Avatar {
@@ -66,8 +69,9 @@ func (a *Avatar) SetSizeRequest(w, h int) {
 }
 
 func (a *Avatar) loadFunc(size int) *gdk.Pixbuf {
-	// No URL, draw nothing.
-	if a.url == "" {
+	// No URL, draw nothing. Blocked avatars are loaded once they're allowed,
+	// so asking for them again would only loop.
+	if a.url == "" || a.url == a.blocked {
 		return nil
 	}
 
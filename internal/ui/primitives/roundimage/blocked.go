@@ -0,0 +1,89 @@
+package roundimage
+
+import (
+	"fmt"
+
+	"github.com/diamondburned/cchat-gtk/internal/gts"
+	"github.com/diamondburned/cchat-gtk/internal/gts/httputil"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// BlockedIcon is the icon shown in place of blocked images.
+const BlockedIcon = "image-x-generic-symbolic"
+
+var (
+	_ httputil.BlockedContainer = (*Image)(nil)
+	_ httputil.BlockedContainer = (*StaticImage)(nil)
+	_ httputil.BlockedContainer = (*Avatar)(nil)
+)
+
+func setBlockedTooltip(w interface{ SetTooltipText(string) }, url string) {
+	// An empty tooltip unsets it.
+	if url == "" {
+		w.SetTooltipText("")
+		return
+	}
+
+	w.SetTooltipText(fmt.Sprintf(
+		"Media from %s is blocked. Right-click to load it.", httputil.MediaHost(url),
+	))
+}
+
+// bindBlockedMenu shows the actions to load the blocked image when the
+// connector is right-clicked. Blocked returns the blocked URL, if any. The
+// event is stopped when the menu is shown, so the connector's own menu is only
+// shown once the image is loaded.
+func bindBlockedMenu(connector primitives.Connector, blocked func() string) {
+	connector.Connect("button-press-event", func(_ interface{}, ev *gdk.Event) bool {
+		var url = blocked()
+		if url == "" || !gts.EventIsRightClick(ev) {
+			return false
+		}
+
+		menu, _ := gtk.MenuNew()
+		primitives.AppendMenuItems(menu, httputil.BlockedMenuItems(url))
+		menu.PopupAtPointer(ev)
+
+		return true
+	})
+}
+
+// SetBlocked shows a placeholder icon if the URL isn't empty.
+func (i *Image) SetBlocked(url string) {
+	i.blocked = url
+	setBlockedTooltip(i, url)
+
+	if url == "" {
+		return
+	}
+
+	var w, h = i.GetSizeRequest()
+	if h < w {
+		w = h
+	}
+
+	i.Image.SetFromIconName(BlockedIcon, gtk.ICON_SIZE_BUTTON)
+	if w > 0 {
+		i.Image.SetPixelSize(w)
+	}
+}
+
+// ConnectHandlers binds the actions to load the image, if it's blocked, to the
+// connector's right-click menu.
+func (i *Image) ConnectHandlers(connector primitives.Connector) {
+	bindBlockedMenu(connector, func() string { return i.blocked })
+}
+
+// SetBlocked keeps the initials shown if the URL isn't empty.
+func (a *Avatar) SetBlocked(url string) {
+	a.blocked = url
+	setBlockedTooltip(a, url)
+}
+
+// ConnectHandlers binds the actions to load the avatar, if it's blocked, to the
+// connector's right-click menu.
+func (a *Avatar) ConnectHandlers(connector primitives.Connector) {
+	bindBlockedMenu(connector, func() string { return a.blocked })
+}
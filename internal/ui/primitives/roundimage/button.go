@@ -25,6 +25,7 @@ func NewButton() (*Button, error) {
 
 	b := NewEmptyButton()
 	b.SetImage(image)
+	image.ConnectHandlers(b)
 
 	return b, nil
 }
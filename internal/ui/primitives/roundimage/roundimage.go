@@ -6,7 +6,6 @@ import (
 
 	"github.com/diamondburned/cchat-gtk/internal/gts/httputil"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
-	"github.com/diamondburned/imgutil"
 	"github.com/gotk3/gotk3/cairo"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
@@ -42,10 +41,19 @@ type Imager interface {
 type Image struct {
 	*gtk.Image
 	Radius float64
-	procs  []imgutil.Processor
+	procs  []httputil.Processor
+	// blocked is the URL of the image if it's blocked.
+	blocked string
+	// Priority is the download priority of the image while it's not on
+	// screen.
+	Priority httputil.Priority
 }
 
-var _ Imager = (*Image)(nil)
+var (
+	_ Imager                  = (*Image)(nil)
+	_ Connector               = (*Image)(nil)
+	_ httputil.PriorityHinter = (*Image)(nil)
+)
 
 // NewImage creates a new round image. If radius is 0, then it will be half the
 // dimensions. If the radius is less than 0, then nothing is rounded.
@@ -76,7 +84,7 @@ func NewImage(radius float64) (*Image, error) {
 	return image, nil
 }
 
-func (i *Image) AddProcessor(procs ...imgutil.Processor) {
+func (i *Image) AddProcessor(procs ...httputil.Processor) {
 	i.procs = append(i.procs, procs...)
 }
 
@@ -89,6 +97,11 @@ func (i *Image) SetImageURL(url string) {
 	httputil.AsyncImage(context.Background(), i, url, i.procs...)
 }
 
+// DownloadPriority returns the Priority field.
+func (i *Image) DownloadPriority() httputil.Priority {
+	return i.Priority
+}
+
 func (i *Image) SetRadius(r float64) {
 	i.Radius = r
 }
@@ -37,6 +37,8 @@ func NewStaticImage(parent primitives.Connector, radius float64) (*StaticImage,
 }
 
 func (s *StaticImage) ConnectHandlers(connector primitives.Connector) {
+	s.Image.ConnectHandlers(connector)
+
 	connector.Connect("enter-notify-event", func(interface{}) {
 		if s.animation != nil && !s.animating {
 			s.animating = true
@@ -100,11 +100,22 @@ type ReferenceHighlighter interface {
 	HighlightReference(ref markup.ReferenceSegment)
 }
 
+// PopoverExtender is a callback to add extra widgets into the box of a mention
+// popover before it is shown.
+type PopoverExtender func(popover *gtk.Popover, box *gtk.Box)
+
+// LinkActivator is a callback for links that aren't segments of the label's
+// output, such as ones manually appended into the markup. It returns true if
+// the link is handled.
+type LinkActivator func(uri string, ptr gdk.Rectangle) bool
+
 // BoundBox is a box wrapping elements that can be interacted with from the
 // parsed labels.
 type BoundBox struct {
-	label Labeler
-	refer ReferenceHighlighter
+	label  Labeler
+	refer  ReferenceHighlighter
+	extend PopoverExtender
+	link   LinkActivator
 }
 
 func BindRichLabel(label Labeler) *BoundBox {
@@ -118,7 +129,7 @@ func (bound *BoundBox) activate(uri string, ptr gdk.Rectangle) bool {
 
 	switch segment := output.URISegment(uri).(type) {
 	case markup.MentionSegment:
-		popover := NewPopoverMentioner(bound.label, output.Input, segment)
+		popover := bound.NewPopoverMentioner(bound.label, segment)
 		if popover != nil {
 			popover.SetPointingTo(ptr)
 			popover.Popup()
@@ -134,7 +145,7 @@ func (bound *BoundBox) activate(uri string, ptr gdk.Rectangle) bool {
 		return true
 
 	default:
-		return false
+		return bound.link != nil && bound.link(uri, ptr)
 	}
 }
 
@@ -142,6 +153,35 @@ func (bound *BoundBox) SetReferenceHighlighter(refer ReferenceHighlighter) {
 	bound.refer = refer
 }
 
+// SetPopoverExtender sets the callback to extend mention popovers created from
+// this label.
+func (bound *BoundBox) SetPopoverExtender(extend PopoverExtender) {
+	bound.extend = extend
+}
+
+// SetLinkActivator sets the callback for links that aren't segments.
+func (bound *BoundBox) SetLinkActivator(link LinkActivator) {
+	bound.link = link
+}
+
+// PopoverMentioner pops up the mention popover of the given segment from the
+// bound label's output.
+func (bound *BoundBox) PopoverMentioner(rel gtk.IWidget, mention text.Segment) {
+	if p := bound.NewPopoverMentioner(rel, mention); p != nil {
+		p.Popup()
+	}
+}
+
+// NewPopoverMentioner creates a new mention popover from the bound label's
+// output. The popover is extended if the label has a PopoverExtender.
+func (bound *BoundBox) NewPopoverMentioner(rel gtk.IWidget, segment text.Segment) *gtk.Popover {
+	p, box := newPopoverMentioner(rel, bound.label.Output().Input, segment)
+	if p != nil && bound.extend != nil {
+		bound.extend(p, box)
+	}
+	return p
+}
+
 func PopoverMentioner(rel gtk.IWidget, input string, mention text.Segment) {
 	if p := NewPopoverMentioner(rel, input, mention); p != nil {
 		p.Popup()
@@ -149,14 +189,21 @@ func PopoverMentioner(rel gtk.IWidget, input string, mention text.Segment) {
 }
 
 func NewPopoverMentioner(rel gtk.IWidget, input string, segment text.Segment) *gtk.Popover {
+	p, _ := newPopoverMentioner(rel, input, segment)
+	return p
+}
+
+func newPopoverMentioner(
+	rel gtk.IWidget, input string, segment text.Segment) (*gtk.Popover, *gtk.Box) {
+
 	var mention = segment.AsMentioner()
 	if mention == nil {
-		return nil
+		return nil, nil
 	}
 
 	var info = mention.MentionInfo()
 	if info.IsEmpty() {
-		return nil
+		return nil, nil
 	}
 
 	start, end := segment.Bounds()
@@ -217,7 +264,7 @@ func NewPopoverMentioner(rel gtk.IWidget, input string, segment text.Segment) *g
 	p, _ := gtk.PopoverNew(rel)
 	p.Add(box)
 	p.SetSizeRequest(PopoverWidth, -1)
-	return p
+	return p, box
 }
 
 func largeText(text string) string {
@@ -239,10 +286,14 @@ func popoverImg(url string, round bool) gtk.IWidget {
 		idl = b.Image
 		btn = b.Button
 	} else {
-		img, _ = gtk.ImageNew()
+		// Use an image that isn't rounded, which still shows a placeholder
+		// while it's blocked.
+		i, _ := roundimage.NewImage(-1)
+		img = i.Image
 		btn, _ = gtk.ButtonNew()
-		btn.Add(img)
-		idl = img
+		btn.Add(i)
+		i.ConnectHandlers(btn)
+		idl = i
 	}
 
 	img.SetSizeRequest(AvatarSize, AvatarSize)
@@ -289,34 +340,9 @@ func bind(connector WidgetConnector, activator func(uri string, r gdk.Rectangle)
 
 		switch ext(uri) {
 		case ".jpg", ".jpeg", ".png", ".webp", ".gif":
-			// Make a new image that's asynchronously fetched inside a button.
-			// Cap the width and height if requested.
-			var w, h, round = markup.FragmentImageSize(uri, MaxWidth, MaxHeight)
-
-			var img *gtk.Image
-			if !round {
-				img, _ = gtk.ImageNew()
-			} else {
-				r, _ := roundimage.NewImage(0)
-				img = r.Image
-			}
-
-			img.SetSizeRequest(w, h)
-			img.SetFromIconName("image-loading", gtk.ICON_SIZE_BUTTON)
-			img.Show()
-
-			// Asynchronously fetch the image.
-			httputil.AsyncImage(context.Background(), img, uri)
-
-			btn, _ := gtk.ButtonNew()
-			btn.Add(img)
-			btn.SetRelief(gtk.RELIEF_NONE)
-			btn.Connect("clicked", func(*gtk.Button) { PromptOpen(uri) })
-			btn.Show()
-
 			p, _ := gtk.PopoverNew(c)
 			p.SetPointingTo(r)
-			p.Add(btn)
+			p.Add(mediaPreview(uri, func() gtk.IWidget { return imagePreview(uri) }))
 			p.Popup()
 
 			return true
@@ -329,6 +355,85 @@ func bind(connector WidgetConnector, activator func(uri string, r gdk.Rectangle)
 	})
 }
 
+// imagePreview creates a new button with the image at the URL, which is fetched
+// asynchronously.
+func imagePreview(uri string) gtk.IWidget {
+	// Cap the width and height if requested.
+	var w, h, round = markup.FragmentImageSize(uri, MaxWidth, MaxHeight)
+
+	var img *gtk.Image
+	if !round {
+		img, _ = gtk.ImageNew()
+	} else {
+		r, _ := roundimage.NewImage(0)
+		img = r.Image
+	}
+
+	img.SetSizeRequest(w, h)
+	img.SetFromIconName("image-loading", gtk.ICON_SIZE_BUTTON)
+	img.Show()
+
+	// Asynchronously fetch the image.
+	httputil.AsyncImage(context.Background(), img, uri)
+
+	btn, _ := gtk.ButtonNew()
+	btn.Add(img)
+	btn.SetRelief(gtk.RELIEF_NONE)
+	btn.Connect("clicked", func(*gtk.Button) { PromptOpen(uri) })
+	btn.Show()
+
+	return btn
+}
+
+// mediaPreview returns the widget made by preview. If the media at the URL is
+// blocked, a placeholder with the actions to load it is returned instead, and
+// it's replaced once the media is allowed.
+func mediaPreview(uri string, preview func() gtk.IWidget) gtk.IWidget {
+	if httputil.MediaAllowed(uri) {
+		return preview()
+	}
+
+	var host = httputil.MediaHost(uri)
+
+	icon := primitives.NewImageIconPx(roundimage.BlockedIcon, 32)
+	icon.Show()
+
+	label, _ := gtk.LabelNew(fmt.Sprintf("Media from %s is blocked.", host))
+	label.SetLineWrap(true)
+	label.SetLineWrapMode(pango.WRAP_WORD_CHAR)
+	label.Show()
+
+	load, _ := gtk.ButtonNewWithLabel("Load")
+	load.Connect("clicked", func(*gtk.Button) { httputil.LoadBlocked(uri) })
+	load.Show()
+
+	always, _ := gtk.ButtonNewWithLabel(fmt.Sprintf("Always Load from %s", host))
+	always.Connect("clicked", func(*gtk.Button) { httputil.AllowDomain(uri) })
+	always.Show()
+
+	placeholder, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 6)
+	placeholder.SetMarginTop(8)
+	placeholder.SetMarginBottom(8)
+	placeholder.SetMarginStart(8)
+	placeholder.SetMarginEnd(8)
+	placeholder.PackStart(icon, false, false, 0)
+	placeholder.PackStart(label, false, false, 0)
+	placeholder.PackStart(load, false, false, 0)
+	placeholder.PackStart(always, false, false, 0)
+	placeholder.Show()
+
+	box, _ := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 0)
+	box.PackStart(placeholder, false, false, 0)
+	box.Show()
+
+	httputil.OnUnblock(box, uri, func() {
+		placeholder.Destroy()
+		box.PackStart(preview(), false, false, 0)
+	})
+
+	return box
+}
+
 const urlPrompt = `This link leads to the following URL:
 <span weight="bold" insert_hyphens="false"><a href="%[1]s">%[1]s</a></span>
 Click <b>Open</b> to proceed.`
@@ -0,0 +1,123 @@
+package keyword
+
+import (
+	"encoding/json"
+
+	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// editor is the config entry that edits the global list of rules.
+type editor struct{}
+
+var _ config.WideEntryValue = (*editor)(nil)
+
+func newEditor() *editor { return &editor{} }
+
+func (*editor) Wide() {}
+
+func (*editor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Rules())
+}
+
+func (*editor) UnmarshalJSON(b []byte) error {
+	var rules []Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return err
+	}
+
+	// Invalid rules are still kept, so the error is not fatal.
+	log.Error(SetRules(rules))
+	return nil
+}
+
+var editorCSS = primitives.PrepareClassCSS("highlight-rules", `
+	.highlight-rules { margin-bottom: 8px; }
+`)
+
+func (*editor) Construct() gtk.IWidget {
+	box := config.RuleList{
+		AddLabel: "Add Rule",
+		Rows: func() []config.RuleRow {
+			var rows []config.RuleRow
+			for _, rule := range Rules() {
+				rows = append(rows, newRuleRow(rule))
+			}
+			return rows
+		},
+		New: func() config.RuleRow {
+			return newRuleRow(Rule{Color: DefaultColor})
+		},
+		Set: func(rows []config.RuleRow) {
+			var newRules = make([]Rule, len(rows))
+			for i, row := range rows {
+				newRules[i] = row.(*ruleRow).rule
+			}
+			// Errors are shown on each individual row.
+			SetRules(newRules)
+		},
+	}.Construct()
+
+	editorCSS(box)
+	return box
+}
+
+type ruleRow struct {
+	*gtk.Box
+	rule    Rule
+	changed func()
+}
+
+func (row *ruleRow) OnChange(changed func()) { row.changed = changed }
+
+func newRuleRow(rule Rule) *ruleRow {
+	row := &ruleRow{rule: rule}
+
+	pattern, _ := gtk.EntryNew()
+	pattern.SetPlaceholderText("Word or regular expression")
+	pattern.SetText(rule.Pattern)
+	pattern.SetHExpand(true)
+	pattern.Show()
+
+	regex, _ := gtk.CheckButtonNewWithLabel("Regex")
+	regex.SetActive(rule.Regex)
+	regex.Show()
+
+	color, _ := gtk.ColorButtonNewWithRGBA(primitives.GdkRGBA(rule.Color))
+	color.SetUseAlpha(false)
+	color.Show()
+
+	var validate = func() {
+		if err := row.rule.Compile(); err != nil {
+			pattern.SetIconFromIconName(gtk.ENTRY_ICON_SECONDARY, "dialog-error")
+			pattern.SetIconTooltipText(gtk.ENTRY_ICON_SECONDARY, err.Error())
+		} else {
+			pattern.RemoveIcon(gtk.ENTRY_ICON_SECONDARY)
+		}
+
+		row.changed()
+	}
+
+	pattern.Connect("changed", func(pattern *gtk.Entry) {
+		row.rule.Pattern, _ = pattern.GetText()
+		validate()
+	})
+	regex.Connect("toggled", func(regex *gtk.CheckButton) {
+		row.rule.Regex = regex.GetActive()
+		validate()
+	})
+	color.Connect("color-set", func(color *gtk.ColorButton) {
+		row.rule.Color = primitives.RGBA(color.GetRGBA())
+		row.changed()
+	})
+
+	row.Box, _ = gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 4)
+	row.Box.PackStart(pattern, true, true, 0)
+	row.Box.PackStart(regex, false, false, 0)
+	row.Box.PackStart(color, false, false, 0)
+	row.Box.Show()
+
+	return row
+}
@@ -0,0 +1,191 @@
+// Package keyword provides user-defined highlight rules that are matched
+// against message contents on top of the backend's own mentions.
+package keyword
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/diamondburned/cchat-gtk/internal/ui/config"
+	"github.com/pkg/errors"
+)
+
+// DefaultColor is the color used for new rules.
+const DefaultColor uint32 = 0xFFA500FF // orange
+
+// AsMentions, if true, will make messages matching a rule count as mentions.
+var AsMentions = true
+
+func init() {
+	config.HighlightsAdd("Rules", newEditor())
+	config.HighlightsAdd("Count Matches as Mentions", config.Switch(&AsMentions, nil))
+}
+
+// Rule is a single highlight rule. A rule either matches a whole word
+// case-insensitively or a regular expression.
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Regex   bool   `json:"regex"`
+	Color   uint32 `json:"color"` // RGBA
+
+	compiled *regexp.Regexp
+}
+
+// Compile compiles the rule's pattern. It returns an error if the rule is a
+// regular expression and is invalid.
+func (r *Rule) Compile() error {
+	r.compiled = nil
+
+	if r.Pattern == "" {
+		return nil
+	}
+
+	var pattern = r.Pattern
+	if !r.Regex {
+		pattern = `(?i)\b` + regexp.QuoteMeta(pattern) + `\b`
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.Wrap(err, "Invalid regular expression")
+	}
+
+	r.compiled = re
+	return nil
+}
+
+// Match is a single highlighted span in a string.
+type Match struct {
+	Start int
+	End   int
+	Color uint32
+}
+
+var (
+	rulesMu sync.RWMutex
+	rules   []Rule
+)
+
+var onChange []func()
+
+// OnChange adds a callback that's called when the rules change, so that the
+// existing messages can be highlighted again. It returns a callback to remove
+// it. The callback is called in the main thread.
+func OnChange(fn func()) (remove func()) {
+	onChange = append(onChange, fn)
+	i := len(onChange) - 1
+
+	return func() { onChange[i] = nil }
+}
+
+func changed() {
+	for _, fn := range onChange {
+		if fn != nil {
+			fn()
+		}
+	}
+}
+
+// Rules returns a copy of the current list of rules.
+func Rules() []Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	return append([]Rule(nil), rules...)
+}
+
+// SetRules compiles and sets the given rules. Rules that fail to compile are
+// kept but never match. The first compile error is returned, if any. It must be
+// called in the main thread.
+func SetRules(newRules []Rule) error {
+	var firstErr error
+
+	for i := range newRules {
+		if err := newRules[i].Compile(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	rulesMu.Lock()
+	rules = newRules
+	rulesMu.Unlock()
+
+	changed()
+
+	return firstErr
+}
+
+// Find returns all non-overlapping matches in the given content sorted by
+// their starting points. When two rules overlap, the earlier rule wins. This
+// function is thread-safe.
+func Find(content string) []Match {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	if len(rules) == 0 || content == "" {
+		return nil
+	}
+
+	var matches []Match
+
+	for _, rule := range rules {
+		if rule.compiled == nil {
+			continue
+		}
+
+	SearchLoop:
+		for _, ix := range rule.compiled.FindAllStringIndex(content, -1) {
+			// Skip empty matches, as they can't be highlighted.
+			if ix[0] == ix[1] {
+				continue
+			}
+
+			for _, match := range matches {
+				if ix[0] < match.End && match.Start < ix[1] {
+					continue SearchLoop
+				}
+			}
+
+			matches = append(matches, Match{
+				Start: ix[0],
+				End:   ix[1],
+				Color: rule.Color,
+			})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+
+	return matches
+}
+
+// Matches returns true if any of the rules matches the given content. This
+// function is thread-safe.
+func Matches(content string) bool {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	for _, rule := range rules {
+		if rule.compiled == nil {
+			continue
+		}
+
+		// Skip empty matches like Find does, so that patterns such as "x*"
+		// don't match everything.
+		for _, ix := range rule.compiled.FindAllStringIndex(content, -1) {
+			if ix[0] != ix[1] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsMention returns true if the given content should count as a mention.
+func IsMention(content string) bool {
+	return AsMentions && Matches(content)
+}
@@ -0,0 +1,88 @@
+package keyword
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	var tests = []struct {
+		name    string
+		rules   []Rule
+		content string
+		expect  []Match
+	}{{
+		name:    "word",
+		rules:   []Rule{{Pattern: "cchat", Color: 1}},
+		content: "I like CChat a lot",
+		expect:  []Match{{7, 12, 1}},
+	}, {
+		name:    "word boundary",
+		rules:   []Rule{{Pattern: "chat", Color: 1}},
+		content: "cchat chat chatter",
+		expect:  []Match{{6, 10, 1}},
+	}, {
+		name:    "regex",
+		rules:   []Rule{{Pattern: `on-?call`, Regex: true, Color: 2}},
+		content: "oncall, then on-call",
+		expect:  []Match{{0, 6, 2}, {13, 20, 2}},
+	}, {
+		name: "overlap keeps earlier rule",
+		rules: []Rule{
+			{Pattern: "gtk", Color: 1},
+			{Pattern: `c?gtk\w*`, Regex: true, Color: 2},
+		},
+		content: "cchat-gtk and gtk3",
+		expect:  []Match{{6, 9, 1}, {14, 18, 2}},
+	}, {
+		name:    "sorted",
+		rules:   []Rule{{Pattern: "b", Color: 1}, {Pattern: "a", Color: 2}},
+		content: "b a",
+		expect:  []Match{{0, 1, 1}, {2, 3, 2}},
+	}, {
+		name:    "empty matches",
+		rules:   []Rule{{Pattern: `x*`, Regex: true, Color: 1}},
+		content: "abc",
+		expect:  nil,
+	}, {
+		name:    "invalid regex",
+		rules:   []Rule{{Pattern: `(`, Regex: true, Color: 1}},
+		content: "(",
+		expect:  nil,
+	}, {
+		name:    "empty content",
+		rules:   []Rule{{Pattern: "a", Color: 1}},
+		content: "",
+		expect:  nil,
+	}}
+
+	defer SetRules(nil)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetRules(test.rules)
+
+			if matches := Find(test.content); !reflect.DeepEqual(matches, test.expect) {
+				t.Fatalf("Unexpected matches: %v, expected %v", matches, test.expect)
+			}
+
+			if Matches(test.content) != (len(test.expect) > 0) {
+				t.Fatalf("Matches disagrees with Find")
+			}
+		})
+	}
+}
+
+func TestSetRulesError(t *testing.T) {
+	defer SetRules(nil)
+
+	err := SetRules([]Rule{{Pattern: "ok"}, {Pattern: `[`, Regex: true}})
+	if err == nil {
+		t.Fatal("Expected an error for the invalid regex")
+	}
+
+	// The valid rule is still used.
+	if !Matches("ok") {
+		t.Fatal("Valid rule doesn't match")
+	}
+}
@@ -0,0 +1,172 @@
+// Package markdown reconstructs Markdown from rich text segments. The output
+// follows the common chat flavor of Markdown, such as the one used by Discord.
+package markdown
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/cchat/text"
+)
+
+// insertion is a string to be inserted at a position of the content.
+type insertion struct {
+	pos   int
+	str   string
+	open  bool
+	start int // start of the segment, used for nesting order
+	end   int // end of the segment, used for nesting order
+}
+
+// Render renders the rich text as Markdown. Characters that Markdown would
+// format are escaped, except in code and bare links.
+func Render(rich text.Rich) string {
+	var inserts []insertion
+
+	// raw marks the bytes that are written as-is.
+	var raw = make([]bool, len(rich.Content))
+	var markRaw = func(start, end int) {
+		for i := start; i < end; i++ {
+			raw[i] = true
+		}
+	}
+
+	var add = func(start, end int, open, close string) {
+		if open != "" {
+			inserts = append(inserts, insertion{start, open, true, start, end})
+		}
+		if close != "" {
+			inserts = append(inserts, insertion{end, close, false, start, end})
+		}
+	}
+
+	for _, segment := range rich.Segments {
+		start, end := segment.Bounds()
+		if start < 0 || end > len(rich.Content) || start > end {
+			continue
+		}
+
+		if attr := segment.AsAttributor(); attr != nil {
+			var tag string
+			for _, pair := range attributeTags {
+				if attr.Attribute().Has(pair.attr) {
+					tag += pair.tag
+				}
+			}
+			add(start, end, tag, reverse(tag))
+
+			if attr.Attribute().Has(text.AttributeMonospace) {
+				markRaw(start, end)
+			}
+		}
+
+		if linker := segment.AsLinker(); linker != nil {
+			link := linker.Link()
+			if rich.Content[start:end] != link {
+				add(start, end, "[", "]("+link+")")
+			} else {
+				markRaw(start, end)
+			}
+		}
+
+		if imager := segment.AsImager(); imager != nil {
+			if start == end {
+				add(start, end, "!["+imager.ImageText()+"]("+imager.Image()+")", "")
+			} else {
+				add(start, end, "![", "]("+imager.Image()+")")
+			}
+		}
+
+		if codeblocker := segment.AsCodeblocker(); codeblocker != nil {
+			add(start, end, "```"+codeblocker.CodeblockLanguage()+"\n", "\n```")
+			markRaw(start, end)
+		}
+
+		if quoteblocker := segment.AsQuoteblocker(); quoteblocker != nil {
+			add(start, end, "> ", "")
+
+			// Prefix every line in the quote.
+			for i := start; i < end-1; i++ {
+				if rich.Content[i] == '\n' {
+					add(i+1, end, "> ", "")
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(inserts, func(i, j int) bool {
+		a, b := inserts[i], inserts[j]
+		if a.pos != b.pos {
+			return a.pos < b.pos
+		}
+		// Close tags before opening new ones.
+		if a.open != b.open {
+			return !a.open
+		}
+		if a.open {
+			// Open outer segments first.
+			return a.end > b.end
+		}
+		// Close inner segments first.
+		return a.start > b.start
+	})
+
+	var builder strings.Builder
+	var last int
+
+	for _, insert := range inserts {
+		writeEscaped(&builder, rich.Content, raw, last, insert.pos)
+		builder.WriteString(insert.str)
+		last = insert.pos
+	}
+
+	writeEscaped(&builder, rich.Content, raw, last, len(rich.Content))
+	return builder.String()
+}
+
+// writeEscaped writes content[start:end], escaping the characters that aren't
+// raw.
+func writeEscaped(builder *strings.Builder, content string, raw []bool, start, end int) {
+	for i := start; i < end; i++ {
+		if !raw[i] && needsEscape(content, i) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(content[i])
+	}
+}
+
+// needsEscape returns true if the character at i would be formatted by
+// Markdown. A ">" is only escaped at the start of a line, where it would start
+// a quote.
+func needsEscape(content string, i int) bool {
+	switch content[i] {
+	case '\\', '*', '_', '~', '|', '`':
+		return true
+	case '>':
+		return i == 0 || content[i-1] == '\n'
+	default:
+		return false
+	}
+}
+
+var attributeTags = []struct {
+	attr text.Attribute
+	tag  string
+}{
+	{text.AttributeBold, "**"},
+	{text.AttributeItalics, "*"},
+	{text.AttributeUnderline, "__"},
+	{text.AttributeStrikethrough, "~~"},
+	{text.AttributeSpoiler, "||"},
+	{text.AttributeMonospace, "`"},
+}
+
+// reverse reverses an ASCII string, which is used to close tags in the right
+// order.
+func reverse(str string) string {
+	b := []byte(str)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
@@ -0,0 +1,154 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/diamondburned/cchat/text"
+	"github.com/diamondburned/cchat/utils/empty"
+)
+
+type bounds struct {
+	empty.TextSegment
+	start, end int
+}
+
+func (b bounds) Bounds() (int, int) { return b.start, b.end }
+
+type attrSegment struct {
+	bounds
+	attr text.Attribute
+}
+
+func (s attrSegment) Attribute() text.Attribute     { return s.attr }
+func (s attrSegment) AsAttributor() text.Attributor { return s }
+
+type linkSegment struct {
+	bounds
+	link string
+}
+
+func (s linkSegment) Link() string          { return s.link }
+func (s linkSegment) AsLinker() text.Linker { return s }
+
+type quoteSegment struct{ bounds }
+
+func (s quoteSegment) QuotePrefix() string               { return ">" }
+func (s quoteSegment) AsQuoteblocker() text.Quoteblocker { return s }
+
+type codeSegment struct {
+	bounds
+	language string
+}
+
+func (s codeSegment) CodeblockLanguage() string       { return s.language }
+func (s codeSegment) AsCodeblocker() text.Codeblocker { return s }
+
+type mentionSegment struct{ bounds }
+
+func (s mentionSegment) MentionInfo() text.Rich      { return text.Plain("info") }
+func (s mentionSegment) AsMentioner() text.Mentioner { return s }
+
+func TestRender(t *testing.T) {
+	var tests = []struct {
+		name     string
+		content  string
+		segments []text.Segment
+		expect   string
+	}{{
+		name:    "plain",
+		content: "hello world",
+		expect:  "hello world",
+	}, {
+		name:    "escaped",
+		content: `2*3*4 snake_case ~x~ a|b \ ` + "`x`",
+		expect:  `2\*3\*4 snake\_case \~x\~ a\|b \\ ` + "\\`x\\`",
+	}, {
+		name:    "escaped quote",
+		content: "> not a quote\na > b",
+		expect:  "\\> not a quote\na > b",
+	}, {
+		name:    "attributes",
+		content: "bold and italics",
+		segments: []text.Segment{
+			attrSegment{bounds{start: 0, end: 4}, text.AttributeBold},
+			attrSegment{bounds{start: 9, end: 16}, text.AttributeItalics | text.AttributeStrikethrough},
+		},
+		expect: "**bold** and *~~italics~~*",
+	}, {
+		name:    "nested",
+		content: "outer inner",
+		segments: []text.Segment{
+			attrSegment{bounds{start: 6, end: 11}, text.AttributeItalics},
+			attrSegment{bounds{start: 0, end: 11}, text.AttributeBold},
+		},
+		expect: "**outer *inner***",
+	}, {
+		name:    "monospace isn't escaped",
+		content: "use a_b",
+		segments: []text.Segment{
+			attrSegment{bounds{start: 4, end: 7}, text.AttributeMonospace},
+		},
+		expect: "use `a_b`",
+	}, {
+		name:    "links",
+		content: "site https://example.com/a_b",
+		segments: []text.Segment{
+			linkSegment{bounds{start: 0, end: 4}, "https://example.com"},
+			linkSegment{bounds{start: 5, end: 28}, "https://example.com/a_b"},
+		},
+		expect: "[site](https://example.com) https://example.com/a_b",
+	}, {
+		name:    "multi-line quote",
+		content: "first\nsecond *\nthird\nafter",
+		segments: []text.Segment{
+			quoteSegment{bounds{start: 0, end: 21}},
+		},
+		expect: "> first\n> second \\*\n> third\nafter",
+	}, {
+		name:    "quote ending in newline",
+		content: "quoted\nafter",
+		segments: []text.Segment{
+			quoteSegment{bounds{start: 0, end: 7}},
+		},
+		expect: "> quoted\nafter",
+	}, {
+		name:    "codeblock",
+		content: "code:\nx := *p",
+		segments: []text.Segment{
+			codeSegment{bounds{start: 6, end: 13}, "go"},
+		},
+		expect: "code:\n```go\nx := *p\n```",
+	}, {
+		name:    "mention",
+		content: "hi @some_user!",
+		segments: []text.Segment{
+			mentionSegment{bounds{start: 3, end: 13}},
+		},
+		expect: `hi @some\_user!`,
+	}, {
+		name:    "bold mention",
+		content: "hi @user",
+		segments: []text.Segment{
+			mentionSegment{bounds{start: 3, end: 8}},
+			attrSegment{bounds{start: 3, end: 8}, text.AttributeBold},
+		},
+		expect: "hi **@user**",
+	}, {
+		name:    "out of bounds",
+		content: "short",
+		segments: []text.Segment{
+			attrSegment{bounds{start: 2, end: 10}, text.AttributeBold},
+		},
+		expect: "short",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rich := text.Rich{Content: test.content, Segments: test.segments}
+
+			if output := Render(rich); output != test.expect {
+				t.Fatalf("Unexpected output:\n%q\nexpected:\n%q", output, test.expect)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/attrmap"
 	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/hl"
+	"github.com/diamondburned/cchat-gtk/internal/ui/rich/parser/keyword"
 	"github.com/diamondburned/cchat/text"
 	"github.com/diamondburned/imgutil"
 	"github.com/gotk3/gotk3/gtk"
@@ -52,6 +53,10 @@ type RenderOutput struct {
 	Input      string // useless to keep parts, as Go will keep all alive anyway
 	Mentions   []MentionSegment
 	References []ReferenceSegment
+
+	// Highlighted is true if any of the keyword highlight rules matched the
+	// input. It is only set if HighlightKeywords is true.
+	Highlighted bool
 }
 
 // MentionSegment is a type that satisfies both Segment and Mentioner.
@@ -130,6 +135,10 @@ type RenderConfig struct {
 		uint32
 		bool
 	}
+
+	// HighlightKeywords, if true, will color all spans that match the user's
+	// keyword highlight rules.
+	HighlightKeywords bool
 }
 
 // SetForegroundAnchor sets the AnchorColor of the render config to be that of
@@ -150,8 +159,13 @@ func (c *RenderConfig) SetForegroundAnchor(ctx *gtk.StyleContext) {
 }
 
 func RenderCmplxWithConfig(content text.Rich, cfg RenderConfig) RenderOutput {
+	var keywords []keyword.Match
+	if cfg.HighlightKeywords {
+		keywords = keyword.Find(content.Content)
+	}
+
 	// Fast path.
-	if len(content.Segments) == 0 {
+	if len(content.Segments) == 0 && len(keywords) == 0 {
 		return RenderOutput{
 			Markup: hyphenate(html.EscapeString(content.Content)),
 			Input:  content.Content,
@@ -271,6 +285,15 @@ func RenderCmplxWithConfig(content text.Rich, cfg RenderConfig) RenderOutput {
 		}
 	}
 
+	// Keyword spans are added last, which makes them the innermost tags. Only
+	// add the ones that are fully within all overlapping segments, so the tags
+	// are always properly nested.
+	for _, match := range keywords {
+		if nestsWithin(content.Segments, match.Start, match.End) {
+			appended.Span(match.Start, match.End, colorAttrs(match.Color, true)...)
+		}
+	}
+
 	var lastIndex = 0
 
 	for _, index := range appended.Finalize(len(content.Content)) {
@@ -288,11 +311,25 @@ func RenderCmplxWithConfig(content text.Rich, cfg RenderConfig) RenderOutput {
 	}
 
 	return RenderOutput{
-		Markup:     hyphenate(buf.String()),
-		Input:      content.Content,
-		Mentions:   mentions,
-		References: references,
+		Markup:      hyphenate(buf.String()),
+		Input:       content.Content,
+		Mentions:    mentions,
+		References:  references,
+		Highlighted: len(keywords) > 0,
+	}
+}
+
+// nestsWithin returns true if the given bounds are fully within every segment
+// that it overlaps.
+func nestsWithin(segments []text.Segment, start, end int) bool {
+	for _, segment := range segments {
+		i, j := segment.Bounds()
+		// Check if the segment overlaps the bounds at all.
+		if i < end && start < j && (start < i || j < end) {
+			return false
+		}
 	}
+	return true
 }
 
 // splitRGBA splits the given rgba integer into rgb and a.
@@ -64,6 +64,11 @@ var sessionMenuCSS = primitives.PrepareClassCSS("session-menu", `
 
 func NewHeader() *Header {
 	menu := glib.MenuNew()
+	menu.Append("Mentions", "app.mentions")
+	menu.Append("Bookmarks", "app.bookmarks")
+	menu.Append("Plugin Commands", "app.plugin-commands")
+	menu.Append("Logs", "app.logs")
+	menu.Append("Inspector", "app.inspector")
 	menu.Append("Preferences", "app.preferences")
 	menu.Append("Quit", "app.quit")
 
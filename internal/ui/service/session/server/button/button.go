@@ -22,6 +22,8 @@ type ToggleButtonImage struct {
 	err    error
 	icon   string // whether or not the button has an icon
 	iconSz int
+
+	spinner *gtk.Spinner // lazily created
 }
 
 var _ cchat.IconContainer = (*ToggleButtonImage)(nil)
@@ -121,6 +123,27 @@ func (b *ToggleButtonImage) SetLoading() {
 	}
 }
 
+// SetBusy shows or hides a spinner after the label without making the button
+// insensitive.
+func (b *ToggleButtonImage) SetBusy(busy bool) {
+	if b.spinner == nil {
+		if !busy {
+			return
+		}
+
+		b.spinner, _ = gtk.SpinnerNew()
+		b.Box.PackEnd(b.spinner, false, false, 5)
+	}
+
+	if busy {
+		b.spinner.Start()
+		b.spinner.Show()
+	} else {
+		b.spinner.Stop()
+		b.spinner.Hide()
+	}
+}
+
 func (b *ToggleButtonImage) SetFailed(err error, retry func()) {
 	b.Label.SetMarkup(rich.MakeRed(b.GetLabel()))
 
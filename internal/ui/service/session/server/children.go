@@ -186,6 +186,64 @@ func (c *Children) findID(id cchat.ID) (int, *ServerRow) {
 	return -1, nil
 }
 
+// FindPath descends into the rows with the given server IDs, expanding and
+// loading each server list along the way. Found is called with the row of the
+// last ID, or nil if the path can't be found.
+func (c *Children) FindPath(ids []cchat.ID, found func(*ServerRow)) {
+	c.findPath(ids, found, func(row *ServerRow, id string) bool {
+		return row.ID() == id
+	})
+}
+
+// FindBreadcrumb is similar to FindPath, except each server is matched by
+// either its breadcrumb name or its ID.
+func (c *Children) FindBreadcrumb(crumbs []string, found func(*ServerRow)) {
+	c.findPath(crumbs, found, func(row *ServerRow, crumb string) bool {
+		return row.Breadcrumb() == crumb || row.ID() == crumb
+	})
+}
+
+func (c *Children) findPath(
+	path []string, found func(*ServerRow), match func(*ServerRow, string) bool) {
+
+	if len(path) == 0 || c.IsHollow() {
+		found(nil)
+		return
+	}
+
+	var row *ServerRow
+	for _, r := range c.Rows {
+		if match(r, path[0]) {
+			row = r
+			break
+		}
+	}
+
+	if row == nil || row.IsHollow() {
+		found(nil)
+		return
+	}
+
+	if len(path) == 1 {
+		found(row)
+		return
+	}
+
+	// The row must be a server list to have more children.
+	if row.children == nil {
+		found(nil)
+		return
+	}
+
+	row.setRevealChild(true, func(err error) {
+		if err != nil {
+			found(nil)
+			return
+		}
+		row.children.findPath(path[1:], found, match)
+	})
+}
+
 func (c *Children) insertAt(row *ServerRow, i int) {
 	c.Rows = append(c.Rows[:i], append([]*ServerRow{row}, c.Rows[i:]...)...)
 
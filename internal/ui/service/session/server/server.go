@@ -1,9 +1,12 @@
 package server
 
 import (
+	"time"
+
 	"github.com/diamondburned/cchat"
 	"github.com/diamondburned/cchat-gtk/internal/gts"
 	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/mentions"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/actions"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives/roundimage"
@@ -200,6 +203,11 @@ func (r *ServerRow) SetUnreadUnsafe(unread, mentioned bool) {
 		unread, mentioned = false, false
 	}
 
+	// Add new mentions into the inbox.
+	if mentioned && !r.mentioned {
+		r.addMention()
+	}
+
 	// Update the local state.
 	r.unread = unread
 	r.mentioned = mentioned
@@ -214,6 +222,37 @@ func (r *ServerRow) SetUnreadUnsafe(unread, mentioned bool) {
 	traverse.TrySetUnread(r.parentcrumb, r.Server.ID(), r.unread, r.mentioned)
 }
 
+// MarkMentioned marks the row as mentioned even if it's being read. This is used
+// for keyword matches while the window isn't focused. The mark is cleared by the
+// next SetUnreadUnsafe call.
+func (r *ServerRow) MarkMentioned() {
+	r.unread = true
+	r.mentioned = true
+
+	if r.Button != nil {
+		r.Button.SetUnreadUnsafe(true, true)
+	}
+
+	traverse.TrySetUnread(r.parentcrumb, r.Server.ID(), true, true)
+}
+
+// addMention adds the server into the mentions inbox. Parent rows may be hollow,
+// so their empty names are skipped.
+func (r *ServerRow) addMention() {
+	var breadcrumb []string
+	for _, crumb := range traverse.TryBreadcrumb(r.parentcrumb) {
+		if crumb != "" {
+			breadcrumb = append(breadcrumb, crumb)
+		}
+	}
+
+	mentions.Add(mentions.Mention{
+		Path:       traverse.TryID(r),
+		Breadcrumb: append(breadcrumb, r.Server.Name().String()),
+		Time:       time.Now(),
+	})
+}
+
 func (r *ServerRow) IsHollow() bool {
 	return r.Box == nil
 }
@@ -348,6 +387,14 @@ func (r *ServerRow) SetLoading() {
 	r.Button.SetLoading()
 }
 
+// SetBusy shows an inline spinner while the server's messages are loading. The
+// row stays clickable.
+func (r *ServerRow) SetBusy(busy bool) {
+	AssertUnhollow(r)
+
+	r.Button.SetBusy(busy)
+}
+
 // SetFailed is shared between the parent struct and the children list. This is
 // because both of those errors share the same appearance, just different
 // callbacks.
@@ -398,6 +445,12 @@ func (r *ServerRow) GetActive() bool {
 // SetRevealChild reveals the list of servers. It does nothing if there are no
 // servers, meaning if Row does not represent a ServerList.
 func (r *ServerRow) SetRevealChild(reveal bool) {
+	r.setRevealChild(reveal, func(error) {})
+}
+
+// setRevealChild reveals the list of servers. If reveal is true, done is
+// called after the children are loaded.
+func (r *ServerRow) setRevealChild(reveal bool, done func(error)) {
 	AssertUnhollow(r)
 
 	// Do the above noop check.
@@ -424,6 +477,8 @@ func (r *ServerRow) SetRevealChild(reveal bool) {
 			// are hollow servers in the children container.
 			r.children.LoadAll()
 		}
+
+		done(err)
 	})
 }
 
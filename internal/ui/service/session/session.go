@@ -64,6 +64,7 @@ type Row struct {
 
 	Session   cchat.Session // state; nilable
 	sessionID string
+	loading   bool
 
 	Servers *Servers // accessed by View for the right view
 
@@ -293,6 +294,13 @@ func (r *Row) SetLoading() {
 
 	r.Add(spin)
 	r.SetSensitive(false) // no activate
+
+	r.loading = true
+}
+
+// IsLoading returns true if the session is still connecting.
+func (r *Row) IsLoading() bool {
+	return r.loading
 }
 
 // SetFailed sets the initial connect status to failed. Do note that session can
@@ -301,6 +309,7 @@ func (r *Row) SetLoading() {
 func (r *Row) SetFailed(err error) {
 	// Make sure that Session is still nil.
 	r.Session = nil
+	r.loading = false
 	// Re-enable the row.
 	r.SetSensitive(true)
 	// Remove everything off the row.
@@ -338,6 +347,7 @@ func (r *Row) SetSession(ses cchat.Session) {
 	// Set the states.
 	r.Session = ses
 	r.sessionID = ses.ID()
+	r.loading = false
 	r.SetTooltipMarkup(markup.Render(ses.Name()))
 	r.avatar.SetText(ses.Name().Content)
 
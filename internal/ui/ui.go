@@ -1,12 +1,20 @@
 package ui
 
 import (
+	"time"
+
 	"github.com/diamondburned/cchat"
 	"github.com/diamondburned/cchat-gtk/icons"
 	"github.com/diamondburned/cchat-gtk/internal/gts"
 	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/plugin"
 	"github.com/diamondburned/cchat-gtk/internal/ui/config/preferences"
+	"github.com/diamondburned/cchat-gtk/internal/ui/deeplink"
+	"github.com/diamondburned/cchat-gtk/internal/ui/inspector"
+	"github.com/diamondburned/cchat-gtk/internal/ui/logviewer"
 	"github.com/diamondburned/cchat-gtk/internal/ui/messages"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/bookmark"
+	"github.com/diamondburned/cchat-gtk/internal/ui/messages/mentions"
 	"github.com/diamondburned/cchat-gtk/internal/ui/primitives"
 	"github.com/diamondburned/cchat-gtk/internal/ui/service"
 	"github.com/diamondburned/cchat-gtk/internal/ui/service/auth"
@@ -59,7 +67,10 @@ type App struct {
 	MessageView *messages.View
 
 	// used to keep track of what row to disconnect before switching
-	lastSelector func(bool)
+	lastSelected *server.ServerRow
+	// mentionBound is true if the window's focus is watched to clear mentions
+	// from keyword matches.
+	mentionBound bool
 }
 
 var (
@@ -102,6 +113,11 @@ func NewApplication() *App {
 	// Bind the preferences action for our GAction button in the header popover.
 	// The action name for this is "app.preferences".
 	gts.AddAppAction("preferences", preferences.SpawnPreferenceDialog)
+	gts.AddAppAction("bookmarks", func() { bookmark.SpawnPanel(app.OpenBookmark) })
+	gts.AddAppAction("mentions", func() { mentions.SpawnPanel(app.OpenMention) })
+	gts.AddAppAction("logs", logviewer.SpawnWindow)
+	gts.AddAppAction("inspector", func() { inspector.SpawnWindow(app.inspectorTarget) })
+	app.bindPlugins()
 
 	// We should assert folded state based on the window's width instead of the
 	// leaflet's state, since doing that might cause a feedback loop.
@@ -146,9 +162,10 @@ func (app *App) OnSessionDisconnect(s *service.Service, r *session.Row) {
 
 func (app *App) SessionSelected(svc *service.Service, ses *session.Row) {
 	// Is there an old row that we should deactivate?
-	if app.lastSelector != nil {
-		app.lastSelector(false)
-		app.lastSelector = nil
+	if app.lastSelected != nil {
+		app.lastSelected.SetSelected(false)
+		app.lastSelected.SetBusy(false)
+		app.lastSelected = nil
 	}
 
 	// TODO
@@ -178,17 +195,226 @@ func (app *App) MessengerSelected(ses *session.Row, srv *server.ServerRow) {
 	}
 
 	// Is there an old row that we should deactivate?
-	if app.lastSelector != nil {
-		app.lastSelector(false)
+	if app.lastSelected != nil {
+		app.lastSelected.SetSelected(false)
+		app.lastSelected.SetBusy(false)
 	}
 
 	// Set the new row.
-	app.lastSelector = srv.SetSelected
-	app.lastSelector(true)
+	app.lastSelected = srv
+	app.lastSelected.SetSelected(true)
 
 	app.MessageView.JoinServer(ses.Session, srv.Server, srv)
 }
 
+// OpenBookmark navigates to the bookmarked message.
+func (app *App) OpenBookmark(b *bookmark.Bookmark) {
+	app.OpenMessage(b.Path, b.MessageID)
+}
+
+// OpenMention navigates to the mentioned message, or only to the server if the
+// mention has no message.
+func (app *App) OpenMention(m *mentions.Mention) {
+	app.OpenMessage(m.Path, m.MessageID)
+}
+
+// OpenMessage navigates to the server with the given path of IDs from the
+// service, then scrolls to the message with the given ID if it's not empty.
+// The session must be connected.
+func (app *App) OpenMessage(path []cchat.ID, msgID cchat.ID) {
+	app.openMessage(path, msgID, log.Error)
+}
+
+// openMessage is OpenMessage with a callback that's called after the server is
+// selected, or with an error if it can't be found.
+func (app *App) openMessage(path []cchat.ID, msgID cchat.ID, done func(error)) {
+	// Skip navigating if we're already in the server.
+	if len(path) >= 3 &&
+		app.MessageView.SessionID() == path[1] &&
+		app.MessageView.ServerID() == path[len(path)-1] {
+
+		app.Leaflet.SetVisibleChild(app.MessageView)
+		app.MessageView.SeekMessage(msgID)
+		done(nil)
+		return
+	}
+
+	app.findServer(path, false, func(svc *service.Service, ses *session.Row, srv *server.ServerRow, err error) {
+		if err != nil {
+			done(err)
+			return
+		}
+
+		app.Services.SessionSelected(svc, ses)
+		app.Services.MessengerSelected(ses, srv)
+		app.MessageView.SeekMessage(msgID)
+		done(nil)
+	})
+}
+
+// linkRetries is the number of times to retry opening a link every second
+// while sessions are still connecting, which happens when a link starts the
+// application.
+const linkRetries = 30
+
+// OpenLink opens the server or the message of the given cchat:// link. The
+// last ID in the link is looked up as a message if it's not a server.
+func (app *App) OpenLink(link string, done func(error)) {
+	path, err := deeplink.Parse(link)
+	if err != nil {
+		done(err)
+		return
+	}
+
+	app.openLink(path, linkRetries, done)
+}
+
+func (app *App) openLink(path []cchat.ID, retries int, done func(error)) {
+	var retry = func(err error) bool {
+		if retries == 0 {
+			return false
+		}
+
+		switch errors.Cause(err) {
+		case errNotConnected:
+		case errSessionNotFound, errServerNotFound:
+			// The session or its servers may not be restored yet.
+			if !app.restoring() {
+				return false
+			}
+		default:
+			return false
+		}
+
+		gts.DoAfter(time.Second, func() { app.openLink(path, retries-1, done) })
+		return true
+	}
+
+	app.openMessage(path, "", func(err error) {
+		// Try the last ID as the message if it's not a server.
+		if errors.Cause(err) == errServerNotFound && len(path) > 3 {
+			app.openMessage(path[:len(path)-1], path[len(path)-1], func(err error) {
+				if err == nil || !retry(err) {
+					done(err)
+				}
+			})
+			return
+		}
+
+		if err == nil || !retry(err) {
+			done(err)
+		}
+	})
+}
+
+// inspectorTarget returns the current session and server for the inspector.
+func (app *App) inspectorTarget() inspector.Target {
+	var target = inspector.Target{
+		Path:       app.MessageView.Path(),
+		Breadcrumb: app.MessageView.Breadcrumb(),
+		Session:    app.MessageView.Session(),
+		Server:     app.MessageView.Server(),
+	}
+
+	if len(target.Path) > 0 {
+		for _, svc := range app.Services.Services.Services {
+			if svc.ID() == target.Path[0] {
+				target.Service = svc.Service()
+				break
+			}
+		}
+	}
+
+	return target
+}
+
+var (
+	errNotConnected    = errors.New("session is not connected")
+	errSessionNotFound = errors.New("session not found")
+	errServerNotFound  = errors.New("server not found")
+)
+
+// restoring returns true if any session or its server list is still loading.
+func (app *App) restoring() bool {
+	for _, svc := range app.Services.Services.Services {
+		for _, ses := range svc.BodyList.Sessions() {
+			if ses.IsLoading() || ses.Servers.IsLoading() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serverFoundFunc is called with the found server, or with an error if the
+// server can't be found.
+type serverFoundFunc func(*service.Service, *session.Row, *server.ServerRow, error)
+
+// findServer finds the messenger server with the given path of IDs from the
+// service, expanding the server list if needed. If crumbs is true, each part
+// of the path can also be the breadcrumb name. The session must be connected.
+func (app *App) findServer(path []string, crumbs bool, found serverFoundFunc) {
+	if len(path) < 3 {
+		found(nil, nil, nil, errors.New("Path is too short"))
+		return
+	}
+
+	var svc *service.Service
+	for _, s := range app.Services.Services.Services {
+		if matchCrumb(s, path[0], crumbs) {
+			svc = s
+			break
+		}
+	}
+
+	if svc == nil {
+		found(nil, nil, nil, errors.Errorf("Service %q not found", path[0]))
+		return
+	}
+
+	var ses *session.Row
+	for _, s := range svc.BodyList.Sessions() {
+		if matchCrumb(s, path[1], crumbs) {
+			ses = s
+			break
+		}
+	}
+
+	if ses == nil {
+		found(nil, nil, nil, errors.Wrapf(errSessionNotFound, "Failed to open session %q", path[1]))
+		return
+	}
+
+	if ses.Session == nil {
+		found(nil, nil, nil, errors.Wrapf(errNotConnected, "Failed to open session %q", path[1]))
+		return
+	}
+
+	var onFound = func(srv *server.ServerRow) {
+		if srv == nil || srv.Server.AsMessenger() == nil {
+			found(nil, nil, nil, errors.Wrap(errServerNotFound, "Failed to open server"))
+			return
+		}
+
+		found(svc, ses, srv, nil)
+	}
+
+	if crumbs {
+		ses.Servers.Children.FindBreadcrumb(path[2:], onFound)
+	} else {
+		ses.Servers.Children.FindPath(path[2:], onFound)
+	}
+}
+
+type crumbIdentifier interface {
+	ID() string
+	Breadcrumb() string
+}
+
+func matchCrumb(v crumbIdentifier, part string, crumbs bool) bool {
+	return v.ID() == part || (crumbs && v.Breadcrumb() == part)
+}
+
 // MessageView methods.
 
 func (app *App) GoBack() {
@@ -196,14 +422,35 @@ func (app *App) GoBack() {
 }
 
 func (app *App) OnMessageBusy() {
-	// Disable the server list because we don't want the user to switch around
-	// while we're loading.
-	app.Services.SetSensitive(false)
+	// Show a spinner on the selected row only. The server list stays usable,
+	// since switching away cancels the pending join.
+	if app.lastSelected != nil {
+		app.lastSelected.SetBusy(true)
+	}
 }
 
 func (app *App) OnMessageDone() {
-	// Re-enable the server list.
-	app.Services.SetSensitive(true)
+	if app.lastSelected != nil {
+		app.lastSelected.SetBusy(false)
+	}
+}
+
+func (app *App) OnMessageMention() {
+	if app.lastSelected == nil {
+		return
+	}
+
+	app.lastSelected.MarkMentioned()
+
+	// Clear the mark once the user is back, since the server is being read.
+	if !app.mentionBound {
+		app.mentionBound = true
+		gts.App.Window.Window.Connect("notify::is-active", func(interface{}) {
+			if gts.App.Window.IsActive() && app.lastSelected != nil {
+				app.lastSelected.SetUnreadUnsafe(false, false)
+			}
+		})
+	}
 }
 
 func (app *App) AuthenticateSession(list *service.List, ssvc *service.Service) {
@@ -215,6 +462,9 @@ func (app *App) AuthenticateSession(list *service.List, ssvc *service.Service) {
 
 // Close is called when the application finishes gracefully.
 func (app *App) Close() {
+	// Stop the plugins before the sessions are gone.
+	plugin.Stop()
+
 	// Disconnect everything. This blocks the main thread, so by the time we're
 	// done, the application would exit immediately. There's no need to update
 	// the GUI.
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"runtime"
-	"time"
+	"os"
 
 	"github.com/diamondburned/cchat-gtk/internal/gts"
 	"github.com/diamondburned/cchat-gtk/internal/log"
+	"github.com/diamondburned/cchat-gtk/internal/replay"
 	"github.com/diamondburned/cchat-gtk/internal/ui"
 	"github.com/diamondburned/cchat-gtk/internal/ui/config"
 	"github.com/diamondburned/cchat/services"
@@ -17,16 +17,11 @@ import (
 	_ "github.com/diamondburned/cchat-mock"
 )
 
-func init() {
-	go func() {
-		// If you GC more, you have shorter STWs. Easy.
-		for range time.Tick(time.Minute) {
-			runtime.GC()
-		}
-	}()
-}
-
 func main() {
+	if err := log.StartFile(); err != nil {
+		log.Error(err)
+	}
+
 	gts.Main(func() gts.MainApplication {
 		var app = ui.NewApplication()
 
@@ -38,6 +33,18 @@ func main() {
 			}
 		}
 
+		// Record the services into a trace if asked to. They're always wrapped
+		// so that the inspector can show their events.
+		if path := os.Getenv("CCHAT_RECORD"); path != "" {
+			if err := replay.Default.Open(path); err != nil {
+				log.Error(err)
+			}
+		}
+
+		for i, srvc := range srvcs {
+			srvcs[i] = replay.Default.Wrap(srvc)
+		}
+
 		// Add the services.
 		for _, srvc := range srvcs {
 			app.AddService(srvc)